@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"log"
 	httpO "net/http"
@@ -10,14 +11,22 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
 	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/application/commands"
 	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/application/queries" // 添加这一行
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/application/services"
 	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/document"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/llm"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/infrastructure/archive"
 	config "github.com/redhander/AIKnowledgeBaseMiddleware/internal/infrastructure/config"
 	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/infrastructure/embedding"
 	deepseek "github.com/redhander/AIKnowledgeBaseMiddleware/internal/infrastructure/llm" // 添加deepseek包导入
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/infrastructure/llm/fake"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/infrastructure/llm/ollama"
 	logger "github.com/redhander/AIKnowledgeBaseMiddleware/internal/infrastructure/logger"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/infrastructure/persistence/memory"
 	milvus "github.com/redhander/AIKnowledgeBaseMiddleware/internal/infrastructure/persistence/milvus" // 添加milvus包导入
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/infrastructure/tokenizer"
 	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/interfaces/http"
 	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/interfaces/http/handler"
 )
@@ -38,53 +47,168 @@ func main() {
 	logger.Infof("Loaded configuration: %+v", cfg.Sanitized()) // 确保敏感配置被过滤
 
 	// 3. 初始化基础设施组件
-	milvusClient, err := initMilvus(rootCtx, cfg.Milvus)
+	embedder, err := embedding.NewEmbedder(
+		cfg.Embedding.Provider,
+		cfg.Embedding.ApiURL,
+		cfg.Embedding.APIKey,
+		cfg.Embedding.ModelName,
+		embedding.WithBatchSize(cfg.Embedding.BatchSize),
+		embedding.WithConcurrency(cfg.Embedding.Concurrency),
+		embedding.WithRetry(cfg.Embedding.MaxRetries, 0),
+		embedding.WithTimeout(cfg.Embedding.Timeout),
+	)
 	if err != nil {
-		logger.Errorf("Failed to initialize Milvus: %v", err)
+		logger.Errorf("Failed to initialize embedder: %v", err)
 		return
 	}
-	if milvusClient == nil {
-		logger.Errorf("Failed to initialize Milvus: %v", err)
-		return
+	var embeddingCache *embedding.CachedEmbedder
+	if cacheCfg := cfg.Embedding.Cache; cacheCfg.Enabled {
+		embeddingCache, err = embedding.NewCachedEmbedder(
+			embedder,
+			cfg.Embedding.ModelName,
+			cacheCfg.Dir,
+			cacheCfg.MaxEntries,
+			int64(cacheCfg.MaxSizeMB)*1024*1024,
+		)
+		if err != nil {
+			logger.Errorf("Failed to initialize embedding cache: %v", err)
+			return
+		}
+		embedder = embeddingCache
+		logger.Infof("Embedding cache enabled at %s: %+v", cacheCfg.Dir, embeddingCache.Stats())
 	}
-	embedder := embedding.NewHuggingFaceEmbedder(cfg.Embedding.ApiURL, cfg.Embedding.APIKey, cfg.Embedding.ModelName)
-	if embedder == nil {
-		logger.Fatalf("Failed to initialize embedder")
+	logger.Infof("Embedder initialized successfully with model: %s", cfg.Embedding.ModelName)
+
+	// 探测嵌入向量维度，用于创建/校验向量集合
+	dimension, err := embedding.ProbeDimension(rootCtx, embedder)
+	if err != nil {
+		logger.Errorf("Failed to detect embedding dimension: %v", err)
+		return
 	}
-	logger.Infof("Embedder initialized successfully with model: %s", embedder)
-	deepseekClient := initDeepSeek(cfg.DeepSeek)
-	if deepseekClient == nil {
-		logger.Fatalf("Failed to initialize DeepSeek client")
+	logger.Infof("Detected embedding dimension: %d", dimension)
+	models, err := initLLMRegistry(cfg.LLM)
+	if err != nil {
+		logger.Errorf("Failed to initialize LLM models: %v", err)
+		return
 	}
-	logger.Infof("DeepSeek client initialized successfully with model: %s", deepseekClient.Model())
+	logger.Infof("LLM models initialized: %+v (default: %s)", models.Models(), models.Default())
 	// 4. 初始化解析器工厂
+	factoryOpts := []document.FactoryOption{
+		document.WithLimits(document.Limits{
+			MaxPages:  cfg.Document.MaxPages,
+			MaxSheets: cfg.Document.MaxSheets,
+			MaxChunks: cfg.Document.MaxChunks,
+		}),
+		document.WithSplitMode(document.SplitMode(cfg.Document.SplitMode)),
+		document.WithCJKChunkSize(cfg.Document.CJKChunkSize, cfg.Document.CJKChunkOverlap),
+		document.WithTableFormat(document.TableFormat(cfg.Document.SpreadsheetFormat)),
+		document.WithSemanticSplitter(
+			embedder,
+			cfg.Document.Semantic.BreakpointPercentile,
+			cfg.Document.Semantic.MinChunkSize,
+			cfg.Document.Semantic.MaxChunkSize,
+		),
+	}
+	if cfg.Document.Tokenizer != "" {
+		tok, err := tokenizer.Load(cfg.Document.Tokenizer)
+		if err != nil {
+			logger.Errorf("Failed to load tokenizer: %v", err)
+			return
+		}
+		factoryOpts = append(factoryOpts, document.WithTokenizer(tok))
+		logger.Infof("Chunking by tokens using tokenizer %s", cfg.Document.Tokenizer)
+	}
 	parserFactory := document.NewParserFactory(
 		cfg.Document.ChunkSize,
 		cfg.Document.ChunkOverlap,
+		factoryOpts...,
 	)
 
 	// 5. 初始化存储库
-	docRepo := milvus.NewMilvusDocumentRepository(
-		milvusClient,
-		cfg.Milvus.CollectionName,
-	)
+	docRepo, err := initDocumentRepository(rootCtx, cfg, dimension)
+	if err != nil {
+		logger.Errorf("Failed to initialize document repository: %v", err)
+		return
+	}
+	logger.Infof("Document repository initialized with backend: %s", cfg.Storage.Backend)
+
+	knowledgeRepo, err := initKnowledgeRepository(cfg.Storage)
+	if err != nil {
+		logger.Errorf("Failed to initialize knowledge base repository: %v", err)
+		return
+	}
 
 	// 6. 初始化应用层
 	uploadHandler := commands.NewUploadDocumentHandler(
 		parserFactory,
 		embedder,
 		docRepo,
+		knowledgeRepo,
 	)
 
 	queryHandler := queries.NewQueryKnowledgeHandler(
 		embedder,
 		docRepo,
-		deepseekClient,
+		knowledgeRepo,
+		models,
 	)
 
+	jobRepo, err := initJobRepository(cfg.Ingestion)
+	if err != nil {
+		logger.Errorf("Failed to initialize job repository: %v", err)
+		return
+	}
+	maxFileSize, err := cfg.Document.GetMaxFileSizeBytes()
+	if err != nil {
+		logger.Errorf("Invalid max_file_size: %v", err)
+		return
+	}
+	maxArchiveSize, err := cfg.Ingestion.Archive.GetMaxTotalSizeBytes()
+	if err != nil {
+		logger.Errorf("Invalid archive max_total_size: %v", err)
+		return
+	}
+	ingestion := services.NewIngestionService(
+		uploadHandler,
+		jobRepo,
+		cfg.Ingestion.StagingDir,
+		cfg.Ingestion.Workers,
+		cfg.Ingestion.QueueSize,
+		services.WithArchiveLimits(archive.Limits{
+			MaxEntries:   cfg.Ingestion.Archive.MaxEntries,
+			MaxTotalSize: maxArchiveSize,
+			MaxEntrySize: maxFileSize,
+			MaxDepth:     cfg.Ingestion.Archive.MaxDepth,
+			MaxRatio:     cfg.Ingestion.Archive.MaxRatio,
+		}),
+	)
+	// 删除知识库时需先停止其入库任务
+	knowledgeService := services.NewKnowledgeService(docRepo, knowledgeRepo, services.WithKnowledgeJobs(ingestion))
+	if err := knowledgeService.EnsureDefault(rootCtx); err != nil {
+		logger.Errorf("Failed to initialize default knowledge base: %v", err)
+		return
+	}
+	if err := ingestion.Start(rootCtx); err != nil {
+		logger.Errorf("Failed to start ingestion workers: %v", err)
+		return
+	}
+
+	deleteHandler := commands.NewDeleteDocumentHandler(docRepo, knowledgeRepo)
+	documentQueryHandler := queries.NewDocumentQueryHandler(docRepo, knowledgeRepo)
+
 	// 7. 初始化HTTP服务
-	httpHandler := handler.NewKnowledgeHandler(uploadHandler, queryHandler)
-	router := http.NewRouter(httpHandler, logger)
+	extensionLimits, err := cfg.Document.GetExtensionLimitBytes()
+	if err != nil {
+		logger.Errorf("Invalid extension_limits: %v", err)
+		return
+	}
+	uploadLimits := handler.UploadLimits{MaxBytes: maxFileSize, PerExtension: extensionLimits}
+
+	httpHandler := handler.NewKnowledgeHandler(ingestion, queryHandler, uploadLimits)
+	knowledgeBaseHandler := handler.NewKnowledgeBaseHandler(knowledgeService)
+	documentHandler := handler.NewDocumentHandler(ingestion, deleteHandler, documentQueryHandler, uploadLimits)
+	jobHandler := handler.NewJobHandler(ingestion)
+	router := http.NewRouter(httpHandler, knowledgeBaseHandler, documentHandler, jobHandler, logger)
 
 	srv := &httpO.Server{
 		Addr:         cfg.Server.Address,
@@ -115,7 +239,13 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		logger.Errorf("Server shutdown error: %v", err)
 	}
+	if err := ingestion.Shutdown(ctx); err != nil {
+		logger.Errorf("Ingestion shutdown error: %v", err)
+	}
 
+	if embeddingCache != nil {
+		logger.Infof("Embedding cache stats: %+v", embeddingCache.Stats())
+	}
 	logger.Info("Server exited properly")
 }
 
@@ -142,8 +272,51 @@ func initLogger(cfg config.LoggingConfig) logger.Logger {
 	})
 }
 
+// 根据 storage.backend 初始化文档存储库
+func initDocumentRepository(ctx context.Context, cfg *config.Config, dimension int) (document.DocumentRepository, error) {
+	switch cfg.Storage.Backend {
+	case config.StorageBackendMemory:
+		metric := memory.Metric(cfg.Storage.Metric)
+		if cfg.Storage.FilePath == "" {
+			return memory.NewMemoryDocumentRepository(metric), nil
+		}
+		return memory.NewFileDocumentRepository(cfg.Storage.FilePath, metric)
+	default:
+		milvusClient, err := initMilvus(ctx, cfg.Milvus, milvus.CollectionSpec{
+			Dimension: dimension,
+			ModelName: cfg.Embedding.ModelName,
+			Metric:    entity.MetricType(cfg.Milvus.Metric),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return milvus.NewMilvusDocumentRepository(milvusClient, cfg.Milvus.CollectionName), nil
+	}
+}
+
+// 初始化知识库元数据存储
+func initKnowledgeRepository(cfg config.StorageConfig) (*memory.KnowledgeRepository, error) {
+	if cfg.KnowledgeFile == "" {
+		return memory.NewKnowledgeRepository(), nil
+	}
+	return memory.NewFileKnowledgeRepository(cfg.KnowledgeFile)
+}
+
+// 初始化入库任务存储
+func initJobRepository(cfg config.IngestionConfig) (*memory.JobRepository, error) {
+	repo := memory.NewJobRepository()
+	if cfg.JobFile != "" {
+		var err error
+		if repo, err = memory.NewFileJobRepository(cfg.JobFile); err != nil {
+			return nil, err
+		}
+	}
+	repo.MaxFinished = cfg.MaxJobs
+	return repo, nil
+}
+
 // 初始化Milvus客户端
-func initMilvus(ctx context.Context, cfg config.MilvusConfig) (*milvus.MilvusClient, error) {
+func initMilvus(ctx context.Context, cfg config.MilvusConfig, spec milvus.CollectionSpec) (*milvus.MilvusClient, error) {
 	options := []milvus.Option{
 		milvus.WithAddress(cfg.Address),
 	}
@@ -152,14 +325,51 @@ func initMilvus(ctx context.Context, cfg config.MilvusConfig) (*milvus.MilvusCli
 		options = append(options, milvus.WithAuth(cfg.Username, cfg.Password))
 	}
 
-	return milvus.NewMilvusClient(ctx, cfg)
+	return milvus.NewMilvusClient(ctx, cfg, spec)
+}
+
+// 按 llm.models 配置初始化各提供方的模型
+func initLLMRegistry(cfg config.LLMConfig) (*llm.Registry, error) {
+	registry := llm.NewRegistry(cfg.Default)
+	for _, m := range cfg.Models {
+		switch m.Provider {
+		case config.LLMProviderOpenAI:
+			registry.Register(m.Name, initOpenAIModel(m))
+		case config.LLMProviderOllama:
+			registry.Register(m.Name, initOllamaModel(m))
+		case config.LLMProviderEcho:
+			registry.Register(m.Name, fake.NewEchoModel(m.Name, ""))
+		default:
+			return nil, fmt.Errorf("unsupported llm provider %s for model %s", m.Provider, m.Name)
+		}
+	}
+	return registry, nil
 }
 
-// 初始化DeepSeek客户端
-func initDeepSeek(cfg config.DeepSeekConfig) *deepseek.Client {
-	return deepseek.NewClient(
-		cfg.BaseURL,
+// 初始化 OpenAI 兼容客户端(DeepSeek API、Ollama /v1 等)
+func initOpenAIModel(cfg config.LLMModelConfig) *deepseek.Client {
+	opts := []deepseek.ClientOption{
+		deepseek.WithName(cfg.Name),
 		deepseek.WithAPIKey(cfg.APIKey),
 		deepseek.WithModel(cfg.Model),
-	)
+		deepseek.WithTimeout(cfg.Timeout),
+		deepseek.WithMaxTokens(cfg.MaxTokens),
+	}
+	if cfg.Temperature != nil {
+		opts = append(opts, deepseek.WithTemperature(*cfg.Temperature))
+	}
+	return deepseek.NewClient(cfg.BaseURL, opts...)
+}
+
+// 初始化 Ollama 原生客户端
+func initOllamaModel(cfg config.LLMModelConfig) *ollama.Client {
+	opts := []ollama.ClientOption{
+		ollama.WithName(cfg.Name),
+		ollama.WithTimeout(cfg.Timeout),
+		ollama.WithMaxTokens(cfg.MaxTokens),
+	}
+	if cfg.Temperature != nil {
+		opts = append(opts, ollama.WithTemperature(*cfg.Temperature))
+	}
+	return ollama.NewClient(cfg.BaseURL, cfg.Model, opts...)
 }
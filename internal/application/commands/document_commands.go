@@ -1,9 +1,13 @@
 package commands
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -11,6 +15,8 @@ import (
 
 	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/document"
 	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/embedding"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/job"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/knowledge"
 	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/infrastructure/logger"
 )
 
@@ -42,24 +48,43 @@ type UploadDocumentCommand struct {
 	Filename    string   // 原始文件名
 	UserID      string   // 上传用户标识（可选）
 	Attributes  Metadata // 自定义属性（可选）
+	// KnowledgeBaseID 目标知识库，为空时写入默认知识库
+	KnowledgeBaseID string
+	// DocumentID 文档ID，为空时自动生成
+	DocumentID string
+	// Replace 为 true 时替换 DocumentID 对应文档的全部分块，知识库沿用原文档
+	Replace bool
+	// FilePath 已落盘的上传文件，非空时直接解析该文件而不再写临时文件
+	FilePath string
+	// ContentType 客户端声明的 MIME 类型(可选)，Prepare 后为按文件内容识别的类型
+	ContentType string
+	// Archive、ArchivePath 从归档中展开的文件所属的归档文件名及其在归档内的路径
+	Archive     string
+	ArchivePath string
+	// Prepared 为 true 时表示已由 Prepare 校验并补全(如排队前)，Handle 不再重复执行
+	Prepared bool
+	// OnProgress 处理阶段变化时回调(可选)，chunks 为已知的分块数
+	OnProgress func(state job.State, chunks int)
 }
+
+// UploadResult 上传处理结果
+type UploadResult struct {
+	Document *document.DocumentInfo
+	Summary  job.Summary
+}
+
 type UploadDocumentHandler struct {
 	parserFactory *document.ParserFactory
 	embedder      embedding.Embedder
 	docRepo       document.DocumentRepository
+	knowledgeRepo knowledge.KnowledgeRepository
 }
 
-//	type UploadDocumentHandler struct {
-//		docParser     document.DocumentParser
-//		docSplitter   document.DocumentSplitter
-//		embedder      embedding.Embedder
-//		docRepo       document.DocumentRepository
-//		knowledgeRepo knowledge.KnowledgeRepository
-//	}
 func NewUploadDocumentHandler(
 	factory *document.ParserFactory,
 	embedder embedding.Embedder,
 	repo document.DocumentRepository,
+	knowledgeRepo knowledge.KnowledgeRepository,
 ) *UploadDocumentHandler {
 	if factory == nil {
 		panic("parserFactory cannot be nil")
@@ -70,114 +95,386 @@ func NewUploadDocumentHandler(
 	if repo == nil {
 		panic("docRepo cannot be nil")
 	}
+	if knowledgeRepo == nil {
+		panic("knowledgeRepo cannot be nil")
+	}
 	return &UploadDocumentHandler{
 		parserFactory: factory,
 		embedder:      embedder,
 		docRepo:       repo,
+		knowledgeRepo: knowledgeRepo,
+	}
+}
+
+// Prepare 校验上传目标并补全知识库和文档ID，可在排队前调用以尽早返回错误
+func (h *UploadDocumentHandler) Prepare(ctx context.Context, cmd *UploadDocumentCommand) error {
+	// 按文件内容识别类型，与扩展名和声明的 Content-Type 交叉校验
+	contentType, err := h.resolveType(cmd)
+	if err != nil {
+		return err
+	}
+	cmd.ContentType = contentType
+	// 页数等可快速读取的上限在排队前校验，分块数只能在处理时校验
+	if cmd.FilePath != "" {
+		if err := h.parserFactory.CheckLimits(contentType, cmd.FilePath); err != nil {
+			return err
+		}
+	}
+
+	// 替换时沿用原文档的知识库，否则生成新ID
+	if cmd.Replace {
+		existing, err := h.docRepo.FindChunks(ctx, cmd.DocumentID)
+		if err != nil {
+			return fmt.Errorf("failed to load document %s: %w", cmd.DocumentID, err)
+		}
+		if len(existing) == 0 {
+			return fmt.Errorf("document %s: %w", cmd.DocumentID, document.ErrNotFound)
+		}
+		if existingKB := existing[0].Metadata.KnowledgeBaseID; existingKB != "" {
+			cmd.KnowledgeBaseID = existingKB
+		}
+	} else if cmd.DocumentID == "" {
+		id, err := document.NewID()
+		if err != nil {
+			return fmt.Errorf("failed to generate document id: %w", err)
+		}
+		cmd.DocumentID = id
+	}
+
+	return h.ResolveKnowledgeBase(cmd)
+}
+
+// ResolveKnowledgeBase 未指定知识库时使用默认知识库，并校验目标知识库存在
+func (h *UploadDocumentHandler) ResolveKnowledgeBase(cmd *UploadDocumentCommand) error {
+	if cmd.KnowledgeBaseID == "" {
+		cmd.KnowledgeBaseID = knowledge.DefaultID
+	}
+	if _, err := h.knowledgeRepo.FindByID(cmd.KnowledgeBaseID); err != nil {
+		return fmt.Errorf("knowledge base %s: %w", cmd.KnowledgeBaseID, err)
 	}
+	return nil
 }
 
-// type UploadDocumentCommand struct {
-// 	FileContent []byte
-// 	Filename    string
-// }
+// resolveType 识别 cmd.FilePath 或 cmd.FileContent 的 MIME 类型
+func (h *UploadDocumentHandler) resolveType(cmd *UploadDocumentCommand) (string, error) {
+	ext := filepath.Ext(cmd.Filename)
+	if cmd.FilePath == "" {
+		return h.parserFactory.ResolveType(bytes.NewReader(cmd.FileContent), int64(len(cmd.FileContent)), ext, cmd.ContentType)
+	}
+	f, err := os.Open(cmd.FilePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open upload: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to stat upload: %w", err)
+	}
+	return h.parserFactory.ResolveType(f, info.Size(), ext, cmd.ContentType)
+}
 
-func (h *UploadDocumentHandler) Handle(ctx context.Context, cmd UploadDocumentCommand) error {
+// Handle 解析、嵌入并存储上传的文档，返回文档概要信息及新增/未变/删除的分块数
+func (h *UploadDocumentHandler) Handle(ctx context.Context, cmd UploadDocumentCommand) (*UploadResult, error) {
 	startTime := time.Now()
+	if !cmd.Prepared {
+		if err := h.Prepare(ctx, &cmd); err != nil {
+			return nil, err
+		}
+	}
+	kbID, docID := cmd.KnowledgeBaseID, cmd.DocumentID
+	progress := func(state job.State, chunks int) {
+		if cmd.OnProgress != nil {
+			cmd.OnProgress(state, chunks)
+		}
+	}
+
 	log := logger.FromContext(ctx).WithFields(map[string]interface{}{
-		"filename": cmd.Filename,
-		"size":     len(cmd.FileContent),
+		"filename":    cmd.Filename,
+		"kb_id":       kbID,
+		"document_id": docID,
 	})
 
-	// 1. 验证文件扩展名
+	// 1. 获取 Prepare 识别的类型对应的解析器
+	parser, err := h.parserFactory.ParserForType(cmd.ContentType)
+	if err != nil {
+		return nil, err
+	}
 	ext := strings.ToLower(filepath.Ext(cmd.Filename))
-	if ext == "" {
-		log.Warn("File has no extension")
-		return fmt.Errorf("file must have an extension")
+
+	// 2. 未提供文件路径时将内容写入临时文件
+	filePath := cmd.FilePath
+	if filePath == "" {
+		// 3. 创建临时文件（带随机后缀防止冲突）
+		tmpFile, err := os.CreateTemp(os.TempDir(), fmt.Sprintf("upload_*%s", ext))
+		if err != nil {
+			log.Errorf("Failed to create temp file: %v", err)
+			return nil, fmt.Errorf("failed to create temporary storage")
+		}
+		tmpPath := tmpFile.Name()
+
+		// 4. 确保清理临时文件
+		defer func() {
+			if err := os.Remove(tmpPath); err != nil {
+				log.Warnf("Failed to remove temp file %s: %v", tmpPath, err)
+			}
+		}()
+
+		// 5. 写入临时文件
+		if _, err := tmpFile.Write(cmd.FileContent); err != nil {
+			tmpFile.Close()
+			log.Errorf("Failed to write temp file: %v", err)
+			return nil, fmt.Errorf("failed to prepare document for processing")
+		}
+		if err := tmpFile.Close(); err != nil {
+			log.Warnf("Failed to close temp file: %v", err)
+		}
+		filePath = tmpPath
 	}
 
-	// 2. 获取对应解析器
-	parser, err := h.parserFactory.GetParser(ext)
+	// 6. 计算文件哈希，内容未变化或知识库中已有相同文件时不再入库
+	fileHash, err := hashFile(filePath)
 	if err != nil {
-		log.Warnf("Unsupported file type: %s", ext)
-		return errors.New("unsupported file type")
+		log.Errorf("Failed to hash file: %v", err)
+		return nil, fmt.Errorf("failed to hash file: %w", err)
 	}
-
-	// 3. 创建临时文件（带随机后缀防止冲突）
-	tmpDir := os.TempDir()
-	tmpFile, err := os.CreateTemp(tmpDir, fmt.Sprintf("upload_*%s", ext))
+	info, err := os.Stat(filePath)
 	if err != nil {
-		log.Errorf("Failed to create temp file: %v", err)
-		return fmt.Errorf("failed to create temporary storage")
+		return nil, fmt.Errorf("failed to stat file: %w", err)
 	}
-	tmpPath := tmpFile.Name()
-
-	// 4. 确保清理临时文件
-	defer func() {
-		if err := os.Remove(tmpPath); err != nil {
-			log.Warnf("Failed to remove temp file %s: %v", tmpPath, err)
+	var existing []*document.Document
+	if cmd.Replace {
+		existing, err = h.docRepo.FindChunks(ctx, docID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load document %s: %w", docID, err)
+		}
+		// 排队期间文档可能已被删除
+		if len(existing) == 0 {
+			return nil, fmt.Errorf("document %s: %w", docID, document.ErrNotFound)
+		}
+		if existing[0].Metadata.FileHash == fileHash {
+			log.Info("File unchanged, skipping re-ingestion")
+			return &UploadResult{
+				Document: document.InfoFromChunk(existing[0]),
+				Summary:  job.Summary{Unchanged: len(existing)},
+			}, nil
 		}
-	}()
+	} else {
+		duplicate, err := h.docRepo.FindDocumentByHash(ctx, kbID, fileHash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check duplicate document: %w", err)
+		}
+		if duplicate != nil {
+			log.Infof("File already ingested as document %s, skipping", duplicate.ID)
+			return &UploadResult{
+				Document: duplicate,
+				Summary:  job.Summary{Unchanged: duplicate.ChunkCount, Duplicate: true},
+			}, nil
+		}
+	}
 
-	// 5. 写入临时文件
-	if _, err := tmpFile.Write(cmd.FileContent); err != nil {
-		log.Errorf("Failed to write temp file: %v", err)
-		return fmt.Errorf("failed to prepare document for processing")
+	// 7. 按知识库配置的分块器解析文档内容
+	kb, err := h.knowledgeRepo.FindByID(kbID)
+	if err != nil {
+		return nil, fmt.Errorf("knowledge base %s: %w", kbID, err)
 	}
-	if err := tmpFile.Close(); err != nil {
-		log.Warnf("Failed to close temp file: %v", err)
+	splitterName := kb.Splitter
+	if splitterName == "" {
+		splitterName = document.SplitterFixed
 	}
-
-	// 6. 解析文档内容
-	log.Info("Start parsing document")
-	docs, err := parser.Parse(tmpPath)
+	splitter, err := h.parserFactory.Splitter(splitterName)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Start parsing document (splitter: %s)", splitterName)
+	progress(job.StateParsing, 0)
+	docs, err := document.ParseWith(ctx, parser, splitter, filePath)
 	if err != nil {
 		log.Errorf("Failed to parse document: %v", err)
-		return fmt.Errorf("document parsing failed: %w", err)
+		return nil, fmt.Errorf("document parsing failed: %w", err)
+	}
+	if len(docs) == 0 {
+		log.Error("No content extracted from document")
+		return nil, fmt.Errorf("document has no extractable content")
+	}
+	if err := h.parserFactory.Limits().CheckChunks(len(docs)); err != nil {
+		log.Warnf("Document rejected: %v", err)
+		return nil, err
 	}
 	log.Infof("Parsed into %d chunks", len(docs))
-	// 7. 生成向量嵌入
-	vectorsGenerated := 0
+
+	uploadTime := time.Now()
+	for i, doc := range docs {
+		// 设置文档元数据，所有分块共享文档ID
+		doc.Metadata.KnowledgeBaseID = kbID
+		doc.Metadata.DocumentID = docID
+		doc.Metadata.ChunkIndex = i
+		doc.Metadata.ChunkCount = len(docs)
+		doc.Metadata.Uploader = cmd.UserID
+		doc.Metadata.UploadTime = uploadTime
+		doc.Metadata.OriginalFile = cmd.Filename
+		doc.Metadata.FileHash = fileHash
+		doc.Metadata.Size = info.Size()
+		if cmd.Archive != "" {
+			if doc.Metadata.Custom == nil {
+				doc.Metadata.Custom = make(map[string]interface{})
+			}
+			doc.Metadata.Custom[document.ArchiveKey] = cmd.Archive
+			doc.Metadata.Custom[document.ArchivePathKey] = cmd.ArchivePath
+		}
+		// 标题路径参与生成向量，标题变化时也需要重新嵌入
+		doc.Metadata.ContentHash = hashContent(doc.TextWithHeadings())
+	}
+
+	// 8. 替换时沿用内容未变化分块的ID和向量，只为新分块生成嵌入
+	reusable := make(map[string][]*document.Document)
+	for _, chunk := range existing {
+		if chunk.Metadata.ContentHash != "" && len(chunk.Vector) > 0 {
+			reusable[chunk.Metadata.ContentHash] = append(reusable[chunk.Metadata.ContentHash], chunk)
+		}
+	}
+	var pending []*document.Document
 	for _, doc := range docs {
-		select {
-		case <-ctx.Done():
+		if olds := reusable[doc.Metadata.ContentHash]; len(olds) > 0 {
+			doc.ID = olds[0].ID
+			doc.Vector = olds[0].Vector
+			reusable[doc.Metadata.ContentHash] = olds[1:]
+			continue
+		}
+		pending = append(pending, doc)
+	}
+	summary := job.Summary{
+		Added:     len(pending),
+		Unchanged: len(docs) - len(pending),
+		Removed:   len(existing) - (len(docs) - len(pending)),
+	}
+
+	progress(job.StateEmbedding, len(docs))
+	if err := h.embed(ctx, log, pending); err != nil {
+		return nil, err
+	}
+
+	// 9. 存储到向量数据库，替换时旧分块在新分块写入成功后删除
+	progress(job.StateStoring, len(docs))
+	if cmd.Replace {
+		err = h.docRepo.ReplaceDocument(ctx, docID, docs)
+	} else {
+		err = h.docRepo.StoreBatch(ctx, docs)
+	}
+	if err != nil {
+		log.Errorf("Failed to store documents: %v", err)
+		return nil, fmt.Errorf("failed to save document knowledge: %w", err)
+	}
+	if err := h.knowledgeRepo.AddDocument(kbID, docID); err != nil {
+		log.Warnf("Failed to record document in knowledge base: %v", err)
+	}
+
+	// 10. 记录处理指标
+	duration := time.Since(startTime)
+	log.Infof("Successfully processed document in %v (chunks: %d, added: %d, unchanged: %d, removed: %d)",
+		duration, len(docs), summary.Added, summary.Unchanged, summary.Removed)
+
+	return &UploadResult{
+		Document: document.InfoFromChunk(docs[0]),
+		Summary:  summary,
+	}, nil
+}
+
+// embed 批量生成向量嵌入并写回分块
+func (h *UploadDocumentHandler) embed(ctx context.Context, log logger.Logger, docs []*document.Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+
+	texts := make([]string, len(docs))
+	for i, doc := range docs {
+		texts[i] = doc.TextWithHeadings()
+	}
+	embeddings, err := h.embedder.EmbedBatch(ctx, texts)
+	if err != nil {
+		if ctx.Err() != nil {
 			log.Info("Processing cancelled by context")
 			return ctx.Err()
-		default:
-			// 设置文档元数据
-			doc.Metadata.UploadTime = time.Now()
-			doc.Metadata.OriginalFile = cmd.Filename
-
-			// 生成嵌入向量
-			embedding, err := h.embedder.Embed(ctx, doc.Content)
-			if err != nil {
-				log.Warnf("Failed to generate embedding for chunk: %v", err)
-				continue // 跳过失败的分块或 return err 根据业务需求
-			}
-			if embedding == nil {
-				log.Warn("Received nil embedding")
-				continue
+		}
+		var batchErr *embedding.BatchError
+		if errors.As(err, &batchErr) {
+			failed := make([]int, 0, len(batchErr.Failures))
+			for _, idx := range batchErr.FailedIndexes() {
+				chunkIndex := docs[idx].Metadata.ChunkIndex
+				log.Warnf("Failed to generate embedding for chunk %d: %v", chunkIndex, batchErr.Failures[idx])
+				failed = append(failed, chunkIndex)
 			}
-			doc.Vector = embedding.Vector
-			vectorsGenerated++
+			return fmt.Errorf("failed to generate embeddings for chunks %v: %w", failed, err)
 		}
+		log.Errorf("Failed to generate embeddings: %v", err)
+		return fmt.Errorf("failed to generate embeddings: %w", err)
 	}
+	if len(embeddings) != len(docs) {
+		return fmt.Errorf("embedder returned %d vectors for %d chunks", len(embeddings), len(docs))
+	}
+	for i, emb := range embeddings {
+		if emb == nil || len(emb.Vector) == 0 {
+			return fmt.Errorf("received empty embedding for chunk %d", docs[i].Metadata.ChunkIndex)
+		}
+		docs[i].Vector = emb.Vector
+	}
+	return nil
+}
 
-	if vectorsGenerated == 0 {
-		log.Error("No vectors generated for document")
-		return fmt.Errorf("failed to generate any embeddings")
+// hashFile 计算文件内容的 SHA-256
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
 	}
+	defer f.Close()
 
-	// 8. 存储到向量数据库
-	if err := h.docRepo.StoreBatch(ctx, docs); err != nil {
-		log.Errorf("Failed to store documents: %v", err)
-		return fmt.Errorf("failed to save document knowledge: %w", err)
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
 	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
 
-	// 9. 记录处理指标
-	duration := time.Since(startTime)
-	log.Infof("Successfully processed document in %v (chunks: %d, vectors: %d)",
-		duration, len(docs), vectorsGenerated)
+// hashContent 计算分块文本的 SHA-256
+func hashContent(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
 
+// DeleteDocumentHandler 删除文档及其全部向量
+type DeleteDocumentHandler struct {
+	docRepo       document.DocumentRepository
+	knowledgeRepo knowledge.KnowledgeRepository
+}
+
+func NewDeleteDocumentHandler(repo document.DocumentRepository, knowledgeRepo knowledge.KnowledgeRepository) *DeleteDocumentHandler {
+	return &DeleteDocumentHandler{
+		docRepo:       repo,
+		knowledgeRepo: knowledgeRepo,
+	}
+}
+
+// Handle 删除文档，文档不存在时返回 document.ErrNotFound
+func (h *DeleteDocumentHandler) Handle(ctx context.Context, documentID string) error {
+	chunks, err := h.docRepo.FindChunks(ctx, documentID)
+	if err != nil {
+		return fmt.Errorf("failed to load document %s: %w", documentID, err)
+	}
+	if len(chunks) == 0 {
+		return fmt.Errorf("document %s: %w", documentID, document.ErrNotFound)
+	}
+
+	if err := h.docRepo.DeleteDocument(ctx, documentID); err != nil {
+		return fmt.Errorf("failed to delete document %s: %w", documentID, err)
+	}
+
+	kbID := chunks[0].Metadata.KnowledgeBaseID
+	if kbID == "" {
+		kbID = knowledge.DefaultID
+	}
+	if err := h.knowledgeRepo.RemoveDocument(kbID, documentID); err != nil && !errors.Is(err, knowledge.ErrNotFound) {
+		logger.FromContext(ctx).Warnf("Failed to remove document %s from knowledge base %s: %v", documentID, kbID, err)
+	}
+	logger.FromContext(ctx).Infof("Deleted document %s (%d chunks)", documentID, len(chunks))
 	return nil
 }
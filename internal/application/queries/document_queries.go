@@ -0,0 +1,80 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/document"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/knowledge"
+)
+
+// DocumentDetail 文档概要信息及其全部分块
+type DocumentDetail struct {
+	*document.DocumentInfo
+	Chunks []ChunkView `json:"chunks"`
+}
+
+// ChunkView 分块的对外表示，不含向量
+type ChunkView struct {
+	ID       string            `json:"id"`
+	Index    int               `json:"index"`
+	Content  string            `json:"content"`
+	Metadata document.Metadata `json:"metadata"`
+}
+
+// DocumentQueryHandler 查询已上传的文档
+type DocumentQueryHandler struct {
+	docRepo document.DocumentRepository
+	kbRepo  knowledge.KnowledgeRepository
+}
+
+func NewDocumentQueryHandler(repo document.DocumentRepository, kbRepo knowledge.KnowledgeRepository) *DocumentQueryHandler {
+	return &DocumentQueryHandler{
+		docRepo: repo,
+		kbRepo:  kbRepo,
+	}
+}
+
+// List 列出知识库内的文档，kbID 为空时使用默认知识库
+func (h *DocumentQueryHandler) List(ctx context.Context, kbID string) ([]*document.DocumentInfo, error) {
+	if kbID == "" {
+		kbID = knowledge.DefaultID
+	}
+	if _, err := h.kbRepo.FindByID(kbID); err != nil {
+		return nil, fmt.Errorf("knowledge base %s: %w", kbID, err)
+	}
+
+	infos, err := h.docRepo.ListDocuments(ctx, kbID)
+	if err != nil {
+		return nil, err
+	}
+	if infos == nil {
+		infos = []*document.DocumentInfo{}
+	}
+	return infos, nil
+}
+
+// Get 返回文档及其分块，文档不存在时返回 document.ErrNotFound
+func (h *DocumentQueryHandler) Get(ctx context.Context, documentID string) (*DocumentDetail, error) {
+	chunks, err := h.docRepo.FindChunks(ctx, documentID)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("document %s: %w", documentID, document.ErrNotFound)
+	}
+
+	detail := &DocumentDetail{
+		DocumentInfo: document.InfoFromChunk(chunks[0]),
+		Chunks:       make([]ChunkView, 0, len(chunks)),
+	}
+	for _, chunk := range chunks {
+		detail.Chunks = append(detail.Chunks, ChunkView{
+			ID:       chunk.ID,
+			Index:    chunk.Metadata.ChunkIndex,
+			Content:  chunk.Content,
+			Metadata: chunk.Metadata,
+		})
+	}
+	return detail, nil
+}
@@ -2,30 +2,71 @@ package queries
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/document"
 	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/embedding"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/knowledge"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/llm"
 	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/query"
-	deepseek "github.com/redhander/AIKnowledgeBaseMiddleware/internal/infrastructure/llm" // 添加导入
 )
 
 type QueryKnowledgeRequest struct {
-	Text string `json:"text"` // 查询文本
-	TopK int    `json:"topk"`
+	Text  string `json:"text"` // 查询文本
+	TopK  int    `json:"topk"`
+	Model string `json:"model"` // 可选，指定使用的模型名称
+	// KnowledgeBaseID 可选，检索的知识库，为空时使用默认知识库
+	KnowledgeBaseID string `json:"kb_id"`
 }
 
 type QueryKnowledgeResponse struct {
 	Answer  string
-	Sources []*document.Document
+	Sources []SourceView
+	Model   string
+}
+
+// QueryKnowledgeStream 流式查询结果，Chunks 在回答结束或出错后关闭
+type QueryKnowledgeStream struct {
+	Sources []SourceView
+	Chunks  <-chan llm.StreamChunk
+	Model   string
+}
+
+// SourceView 检索到的分块的对外表示，不含向量
+type SourceView struct {
+	ID       string            `json:"id"`
+	Content  string            `json:"content"`
+	Score    float64           `json:"score"` // 与查询向量的距离，含义取决于度量方式
+	Metadata document.Metadata `json:"metadata"`
+}
+
+func sourceViews(docs []*document.Document) []SourceView {
+	views := make([]SourceView, 0, len(docs))
+	for _, doc := range docs {
+		views = append(views, SourceView{
+			ID:       doc.ID,
+			Content:  doc.Content,
+			Score:    doc.Score,
+			Metadata: doc.Metadata,
+		})
+	}
+	return views
 }
 
 type QueryKnowledgeHandler struct {
 	embedder     embedding.Embedder
 	docRepo      document.DocumentRepository
-	queryService query.QueryService
+	kbRepo       knowledge.KnowledgeRepository
+	models       *llm.Registry
+	queryService query.StreamingQueryService
 }
 
 func (h *QueryKnowledgeHandler) Handle(ctx context.Context, req QueryKnowledgeRequest) (*QueryKnowledgeResponse, error) {
+	kbID, err := h.knowledgeBase(req.KnowledgeBaseID)
+	if err != nil {
+		return nil, err
+	}
+
 	// 1. 嵌入查询
 	embedding, err := h.embedder.Embed(ctx, req.Text)
 	if err != nil {
@@ -34,24 +75,77 @@ func (h *QueryKnowledgeHandler) Handle(ctx context.Context, req QueryKnowledgeRe
 
 	// 2. 执行查询
 	result, err := h.queryService.Execute(ctx, &query.Query{
-		Text:      req.Text,
-		Embedding: embedding.Vector,
-		TopK:      req.TopK,
+		Text:            req.Text,
+		Embedding:       embedding.Vector,
+		TopK:            req.TopK,
+		Model:           req.Model,
+		KnowledgeBaseID: kbID,
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	model, _ := result.Metadata["model"].(string)
 	return &QueryKnowledgeResponse{
 		Answer:  result.Answer,
-		Sources: result.Sources,
+		Sources: sourceViews(result.Sources),
+		Model:   model,
+	}, nil
+}
+
+// HandleStream 嵌入查询并检索文档，回答以流式方式返回
+// ctx 取消时上游 LLM 请求随之取消
+func (h *QueryKnowledgeHandler) HandleStream(ctx context.Context, req QueryKnowledgeRequest) (*QueryKnowledgeStream, error) {
+	kbID, err := h.knowledgeBase(req.KnowledgeBaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	embedding, err := h.embedder.Embed(ctx, req.Text)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := h.queryService.ExecuteStream(ctx, &query.Query{
+		Text:            req.Text,
+		Embedding:       embedding.Vector,
+		TopK:            req.TopK,
+		Model:           req.Model,
+		KnowledgeBaseID: kbID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &QueryKnowledgeStream{
+		Sources: sourceViews(stream.Sources),
+		Chunks:  stream.Chunks,
+		Model:   stream.Model,
 	}, nil
 }
 
-func NewQueryKnowledgeHandler(embedder embedding.Embedder, repo document.DocumentRepository, client *deepseek.Client) *QueryKnowledgeHandler {
+// knowledgeBase 校验知识库存在，为空时返回默认知识库
+func (h *QueryKnowledgeHandler) knowledgeBase(kbID string) (string, error) {
+	if kbID == "" {
+		kbID = knowledge.DefaultID
+	}
+	if _, err := h.kbRepo.FindByID(kbID); err != nil {
+		return "", fmt.Errorf("knowledge base %s: %w", kbID, err)
+	}
+	return kbID, nil
+}
+
+// Models 返回可供选择的模型列表及默认模型名称
+func (h *QueryKnowledgeHandler) Models() ([]llm.ModelInfo, string) {
+	return h.models.Models(), h.models.Default()
+}
+
+func NewQueryKnowledgeHandler(embedder embedding.Embedder, repo document.DocumentRepository, kbRepo knowledge.KnowledgeRepository, models *llm.Registry) *QueryKnowledgeHandler {
 	return &QueryKnowledgeHandler{
 		embedder:     embedder,
 		docRepo:      repo,
-		queryService: query.NewRAGQueryService(client, repo), // 示例初始化逻辑
+		kbRepo:       kbRepo,
+		models:       models,
+		queryService: query.NewRAGQueryService(models, repo),
 	}
 }
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/application/commands"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/document"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/job"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/infrastructure/archive"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/infrastructure/logger"
+)
+
+// ArchiveResult 归档上传结果，每个文件对应一个入库任务或未入库的原因
+type ArchiveResult struct {
+	Archive string         `json:"archive"`
+	Entries []ArchiveEntry `json:"entries"`
+}
+
+// ArchiveEntry 归档中的一个文件，Job 和 Error 二者只有一个非空
+type ArchiveEntry struct {
+	Path  string   `json:"path"`
+	Job   *job.Job `json:"job,omitempty"`
+	Error string   `json:"error,omitempty"`
+}
+
+// extractedFile 已解压到暂存目录、等待提交的文件
+type extractedFile struct {
+	entry    int // 在 ArchiveResult.Entries 中的序号
+	filePath string
+}
+
+// SubmitArchive 展开 zip、tar 或 tar.gz 归档，为其中的每个文件创建入库任务
+// 先解压全部文件再提交，超出解压限制时不创建任何任务并返回 document.ErrLimitExceeded
+// 类型不支持或路径不安全的文件不影响其他文件，在结果中记录原因；cmd.FilePath 处理后删除
+// 条目数可能超过队列容量，创建的任务在后台等待入队，不会因队列已满而失败
+func (s *IngestionService) SubmitArchive(ctx context.Context, cmd commands.UploadDocumentCommand) (*ArchiveResult, error) {
+	if err := s.upload.ResolveKnowledgeBase(&cmd); err != nil {
+		return nil, err
+	}
+	log := logger.FromContext(ctx).WithFields(map[string]interface{}{"archive": cmd.Filename})
+
+	result := &ArchiveResult{Archive: cmd.Filename, Entries: []ArchiveEntry{}}
+	files, err := s.extract(ctx, cmd, result)
+	if err != nil {
+		return nil, err
+	}
+	if cmd.FilePath != "" {
+		if err := os.Remove(cmd.FilePath); err != nil {
+			log.Warnf("Failed to remove archive upload: %v", err)
+		}
+	}
+
+	var queued []string
+	for _, f := range files {
+		entry := &result.Entries[f.entry]
+		j, err := s.stage(ctx, commands.UploadDocumentCommand{
+			Filename:        path.Base(entry.Path),
+			UserID:          cmd.UserID,
+			KnowledgeBaseID: cmd.KnowledgeBaseID,
+			FilePath:        f.filePath,
+			Archive:         cmd.Filename,
+			ArchivePath:     entry.Path,
+		})
+		if err != nil {
+			if err := os.Remove(f.filePath); err != nil && !os.IsNotExist(err) {
+				log.Warnf("Failed to remove extracted file: %v", err)
+			}
+			entry.Error = err.Error()
+			continue
+		}
+		queued = append(queued, j.ID)
+		entry.Job = j
+	}
+	s.enqueueBacklog(queued)
+	log.Infof("Queued %d of %d archive entries", len(queued), len(result.Entries))
+	return result, nil
+}
+
+// extract 将归档中的文件解压到暂存目录，并按归档中的顺序将全部条目记入 result，被跳过的条目记录原因
+// 出错时删除已解压的文件
+func (s *IngestionService) extract(ctx context.Context, cmd commands.UploadDocumentCommand, result *ArchiveResult) ([]extractedFile, error) {
+	var r io.ReaderAt = bytes.NewReader(cmd.FileContent)
+	size := int64(len(cmd.FileContent))
+	if cmd.FilePath != "" {
+		f, err := os.Open(cmd.FilePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open archive: %w", err)
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat archive: %w", err)
+		}
+		r, size = f, info.Size()
+	}
+
+	var files []extractedFile
+	err := archive.Walk(r, size, s.archive, func(e *archive.Entry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if e.Err != nil {
+			result.Entries = append(result.Entries, ArchiveEntry{Path: e.Path, Error: e.Err.Error()})
+			return nil
+		}
+		filePath, err := s.stageEntry(e)
+		if err != nil {
+			return err
+		}
+		files = append(files, extractedFile{entry: len(result.Entries), filePath: filePath})
+		result.Entries = append(result.Entries, ArchiveEntry{Path: e.Path})
+		return nil
+	})
+	if err != nil {
+		for _, f := range files {
+			os.Remove(f.filePath)
+		}
+		switch {
+		case errors.Is(err, archive.ErrLimitExceeded):
+			return nil, fmt.Errorf("%w: %v", document.ErrLimitExceeded, err)
+		case errors.Is(err, archive.ErrUnsupportedFormat):
+			return nil, fmt.Errorf("%w: %v", document.ErrUnsupportedMediaType, err)
+		}
+		return nil, fmt.Errorf("failed to extract archive: %w", err)
+	}
+	return files, nil
+}
+
+// stageEntry 将条目写入暂存目录，保留原扩展名
+func (s *IngestionService) stageEntry(e *archive.Entry) (string, error) {
+	tmp, err := os.CreateTemp(s.stagingDir, "archive-*"+strings.ToLower(path.Ext(e.Path)))
+	if err != nil {
+		return "", fmt.Errorf("failed to create staging file: %w", err)
+	}
+	_, err = io.Copy(tmp, e)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
@@ -0,0 +1,438 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/application/commands"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/document"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/job"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/knowledge"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/infrastructure/archive"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/infrastructure/logger"
+)
+
+var (
+	// ErrQueueFull 排队任务已达上限
+	ErrQueueFull = errors.New("ingestion queue is full")
+	// ErrJobFinished 任务已结束，无法取消
+	ErrJobFinished = errors.New("job already finished")
+)
+
+// IngestionService 将上传的文档放入队列，由固定数量的工作协程在后台解析、嵌入和存储
+// 上传文件暂存在 stagingDir 中，任务状态保存在 job.Repository，重启后未完成的任务重新执行
+type IngestionService struct {
+	upload     *commands.UploadDocumentHandler
+	jobs       job.Repository
+	stagingDir string
+	workers    int
+	queue      chan string
+	archive    archive.Limits
+
+	mu      sync.Mutex
+	running map[string]*runningJob
+	closed  map[string]bool // 正在删除或已删除的知识库，不再为其创建任务
+	wg      sync.WaitGroup
+	ctx     context.Context // 工作协程的上下文，服务关闭时取消
+	stop    context.CancelFunc
+}
+
+type runningJob struct {
+	cancel    context.CancelFunc
+	cancelled bool          // 由用户取消，区别于服务关闭
+	done      chan struct{} // 写入最终状态后关闭
+}
+
+// IngestionOption 入库服务配置项
+type IngestionOption func(*IngestionService)
+
+// WithArchiveLimits 设置展开归档时的条目数、大小、压缩比和嵌套层数上限
+func WithArchiveLimits(limits archive.Limits) IngestionOption {
+	return func(s *IngestionService) {
+		s.archive = limits
+	}
+}
+
+func NewIngestionService(upload *commands.UploadDocumentHandler, jobs job.Repository, stagingDir string, workers, queueSize int, opts ...IngestionOption) *IngestionService {
+	if workers <= 0 {
+		workers = 1
+	}
+	s := &IngestionService{
+		upload:     upload,
+		jobs:       jobs,
+		stagingDir: stagingDir,
+		workers:    workers,
+		queue:      make(chan string, queueSize),
+		running:    make(map[string]*runningJob),
+		closed:     make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Start 启动工作协程，并将上次运行中断的任务重新入队
+func (s *IngestionService) Start(ctx context.Context) error {
+	if err := os.MkdirAll(s.stagingDir, 0755); err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+
+	s.ctx, s.stop = context.WithCancel(ctx)
+	ctx = s.ctx
+	for i := 0; i < s.workers; i++ {
+		s.wg.Add(1)
+		go s.worker(ctx)
+	}
+
+	jobs, err := s.jobs.List()
+	if err != nil {
+		return fmt.Errorf("failed to load jobs: %w", err)
+	}
+	var pending []string
+	for _, j := range jobs {
+		if j.State.Terminal() {
+			continue
+		}
+		if j.State != job.StateQueued {
+			j.State = job.StateQueued
+			j.StartedAt = nil
+			if err := s.jobs.Save(j); err != nil {
+				return fmt.Errorf("failed to requeue job %s: %w", j.ID, err)
+			}
+		}
+		pending = append(pending, j.ID)
+	}
+	if len(pending) > 0 {
+		logger.FromContext(ctx).Infof("Resuming %d unfinished ingestion jobs", len(pending))
+		// 恢复的任务可能超过队列容量
+		s.enqueueBacklog(pending)
+	}
+	return nil
+}
+
+// enqueueBacklog 在后台逐个入队，队列满时等待工作协程取出
+// 服务关闭前未入队的任务保持排队状态，下次启动时重新入队
+func (s *IngestionService) enqueueBacklog(ids []string) {
+	ctx := s.ctx
+	go func() {
+		for _, id := range ids {
+			select {
+			case s.queue <- id:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Shutdown 停止工作协程，运行中的任务保持未完成状态，下次启动时重新执行
+func (s *IngestionService) Shutdown(ctx context.Context) error {
+	if s.stop != nil {
+		s.stop()
+	}
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Submit 暂存上传内容并创建排队任务，目标知识库或文档不存在时立即返回错误
+// cmd.FilePath 非空时将该文件移入暂存目录，否则写入 cmd.FileContent。队列已满时返回 ErrQueueFull
+func (s *IngestionService) Submit(ctx context.Context, cmd commands.UploadDocumentCommand) (*job.Job, error) {
+	j, err := s.stage(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case s.queue <- j.ID:
+	default:
+		j.State = job.StateFailed
+		j.Error = ErrQueueFull.Error()
+		s.finish(ctx, j)
+		return nil, ErrQueueFull
+	}
+
+	logger.FromContext(ctx).Infof("Queued ingestion job %s for %s", j.ID, j.Filename)
+	return j, nil
+}
+
+// stage 校验上传并暂存文件，保存排队状态的任务，由调用方负责入队
+func (s *IngestionService) stage(ctx context.Context, cmd commands.UploadDocumentCommand) (*job.Job, error) {
+	if err := s.upload.Prepare(ctx, &cmd); err != nil {
+		return nil, err
+	}
+
+	id, err := document.NewID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate job id: %w", err)
+	}
+	j := &job.Job{
+		ID:              id,
+		KnowledgeBaseID: cmd.KnowledgeBaseID,
+		DocumentID:      cmd.DocumentID,
+		Replace:         cmd.Replace,
+		Filename:        cmd.Filename,
+		ContentType:     cmd.ContentType,
+		Archive:         cmd.Archive,
+		ArchivePath:     cmd.ArchivePath,
+		Uploader:        cmd.UserID,
+		State:           job.StateQueued,
+		CreatedAt:       time.Now(),
+	}
+
+	path := s.stagedPath(j)
+	if cmd.FilePath != "" {
+		err = moveFile(cmd.FilePath, path)
+	} else {
+		err = os.WriteFile(path, cmd.FileContent, 0644)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to stage upload: %w", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to stage upload: %w", err)
+	}
+	j.Size = info.Size()
+
+	// 与 CloseKnowledgeBase 互斥：任务要么在关闭前保存并被取消，要么在关闭后被拒绝
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed[j.KnowledgeBaseID] {
+		os.Remove(path)
+		return nil, fmt.Errorf("knowledge base %s: %w", j.KnowledgeBaseID, knowledge.ErrNotFound)
+	}
+	if err := s.jobs.Save(j); err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to save job: %w", err)
+	}
+	return j, nil
+}
+
+func (s *IngestionService) Get(ctx context.Context, id string) (*job.Job, error) {
+	return s.jobs.FindByID(id)
+}
+
+func (s *IngestionService) List(ctx context.Context) ([]*job.Job, error) {
+	return s.jobs.List()
+}
+
+// Cancel 取消任务：排队中的任务直接标记为已取消，运行中的任务中断处理
+func (s *IngestionService) Cancel(ctx context.Context, id string) (*job.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, err := s.jobs.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if j.State.Terminal() {
+		return j, ErrJobFinished
+	}
+
+	if r, ok := s.running[id]; ok {
+		// 由工作协程在 Handle 返回后写入最终状态
+		r.cancelled = true
+		r.cancel()
+		return j, nil
+	}
+
+	j.State = job.StateCancelled
+	s.finish(ctx, j)
+	return j, nil
+}
+
+// CloseKnowledgeBase 删除知识库前调用：此后不再为该知识库创建任务，取消排队中的任务，
+// 中断运行中的任务并等待其退出，避免删除后继续写入向量
+// 删除失败时调用方应调用 ReopenKnowledgeBase，已取消的任务不会恢复
+func (s *IngestionService) CloseKnowledgeBase(ctx context.Context, kbID string) error {
+	s.mu.Lock()
+	s.closed[kbID] = true
+	jobs, err := s.jobs.List()
+	if err != nil {
+		s.mu.Unlock()
+		return fmt.Errorf("failed to load jobs: %w", err)
+	}
+	var running []chan struct{}
+	for _, j := range jobs {
+		if j.KnowledgeBaseID != kbID || j.State.Terminal() {
+			continue
+		}
+		if r, ok := s.running[j.ID]; ok {
+			r.cancelled = true
+			r.cancel()
+			running = append(running, r.done)
+			continue
+		}
+		j.State = job.StateCancelled
+		s.finish(ctx, j)
+	}
+	s.mu.Unlock()
+
+	if len(running) > 0 {
+		logger.FromContext(ctx).Infof("Waiting for %d running ingestion jobs of knowledge base %s", len(running), kbID)
+	}
+	for _, done := range running {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// ReopenKnowledgeBase 知识库删除失败时恢复为其创建任务
+func (s *IngestionService) ReopenKnowledgeBase(kbID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.closed, kbID)
+}
+
+func (s *IngestionService) worker(ctx context.Context) {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-s.queue:
+			s.process(ctx, id)
+		}
+	}
+}
+
+// process 执行单个任务，服务关闭导致的中断不视为失败
+func (s *IngestionService) process(ctx context.Context, id string) {
+	log := logger.FromContext(ctx).WithFields(map[string]interface{}{"job_id": id})
+
+	s.mu.Lock()
+	j, err := s.jobs.FindByID(id)
+	if err != nil || j.State != job.StateQueued {
+		// 已取消或已被删除
+		s.mu.Unlock()
+		return
+	}
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	r := &runningJob{cancel: cancel, done: make(chan struct{})}
+	defer close(r.done)
+	s.running[id] = r
+	now := time.Now()
+	j.StartedAt = &now
+	j.State = job.StateParsing
+	s.save(log, j)
+	s.mu.Unlock()
+
+	result, err := s.upload.Handle(jobCtx, commands.UploadDocumentCommand{
+		Filename:        j.Filename,
+		ContentType:     j.ContentType,
+		Archive:         j.Archive,
+		ArchivePath:     j.ArchivePath,
+		UserID:          j.Uploader,
+		KnowledgeBaseID: j.KnowledgeBaseID,
+		DocumentID:      j.DocumentID,
+		Replace:         j.Replace,
+		FilePath:        s.stagedPath(j),
+		// Submit 时已执行 Prepare，类型、知识库和文档ID记录在任务中；未记录类型的旧任务重新执行
+		Prepared: j.ContentType != "",
+		OnProgress: func(state job.State, chunks int) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			j.State = state
+			j.Chunks = chunks
+			s.save(log, j)
+		},
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, id)
+
+	switch {
+	case err == nil:
+		j.State = job.StateDone
+		j.Chunks = result.Document.ChunkCount
+		// 重复文件时指向已有文档
+		j.DocumentID = result.Document.ID
+		j.Summary = &result.Summary
+	case r.cancelled:
+		j.State = job.StateCancelled
+	case ctx.Err() != nil:
+		// 服务关闭，保留暂存文件，重启后重新执行
+		log.Infof("Ingestion job interrupted by shutdown")
+		j.State = job.StateQueued
+		j.StartedAt = nil
+		s.save(log, j)
+		return
+	default:
+		j.State = job.StateFailed
+		j.Error = err.Error()
+	}
+	s.finish(ctx, j)
+	log.Infof("Ingestion job finished with state %s in %v", j.State, j.FinishedAt.Sub(*j.StartedAt))
+}
+
+// finish 记录结束时间并删除暂存文件(调用方需持有锁或确保任务未运行)
+func (s *IngestionService) finish(ctx context.Context, j *job.Job) {
+	now := time.Now()
+	j.FinishedAt = &now
+	log := logger.FromContext(ctx).WithFields(map[string]interface{}{"job_id": j.ID})
+	s.save(log, j)
+	if err := os.Remove(s.stagedPath(j)); err != nil && !os.IsNotExist(err) {
+		log.Warnf("Failed to remove staged upload: %v", err)
+	}
+}
+
+func (s *IngestionService) save(log logger.Logger, j *job.Job) {
+	if err := s.jobs.Save(j); err != nil {
+		log.Errorf("Failed to save job state %s: %v", j.State, err)
+	}
+}
+
+// stagedPath 暂存文件保留原扩展名，供解析器识别
+func (s *IngestionService) stagedPath(j *job.Job) string {
+	return filepath.Join(s.stagingDir, j.ID+strings.ToLower(filepath.Ext(j.Filename)))
+}
+
+// moveFile 优先重命名，跨文件系统时退化为复制后删除
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
@@ -1,35 +1,148 @@
-package query
+package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/document"
 	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/knowledge"
-	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/query"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/infrastructure/logger"
 )
 
+// ErrInvalidName 知识库名称为空
+var ErrInvalidName = errors.New("knowledge base name is required")
+
+// KnowledgeJobs 删除知识库时停止其入库任务，由 IngestionService 实现
+type KnowledgeJobs interface {
+	CloseKnowledgeBase(ctx context.Context, kbID string) error
+	ReopenKnowledgeBase(kbID string)
+}
+
+// KnowledgeService 管理知识库的创建、修改与删除
 type KnowledgeService struct {
 	docRepo       document.DocumentRepository
 	knowledgeRepo knowledge.KnowledgeRepository
-	queryService  query.QueryService
+	jobs          KnowledgeJobs
+}
+
+// KnowledgeOption 知识库服务配置项
+type KnowledgeOption func(*KnowledgeService)
+
+// WithKnowledgeJobs 删除知识库前停止其入库任务，否则删除后完成的任务会重新写入向量
+func WithKnowledgeJobs(jobs KnowledgeJobs) KnowledgeOption {
+	return func(s *KnowledgeService) {
+		s.jobs = jobs
+	}
+}
+
+func NewKnowledgeService(docRepo document.DocumentRepository, knowledgeRepo knowledge.KnowledgeRepository, opts ...KnowledgeOption) *KnowledgeService {
+	s := &KnowledgeService{
+		docRepo:       docRepo,
+		knowledgeRepo: knowledgeRepo,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-func (s *KnowledgeService) UploadDocument(ctx context.Context, kbID string, doc *document.Document) error {
-	// 1. 存储文档
-	if err := s.docRepo.Store(ctx, doc); err != nil {
+// EnsureDefault 默认知识库不存在时创建，升级前写入的文档均归属默认知识库
+func (s *KnowledgeService) EnsureDefault(ctx context.Context) error {
+	_, err := s.knowledgeRepo.FindByID(knowledge.DefaultID)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, knowledge.ErrNotFound) {
 		return err
 	}
 
-	// 2. 添加到知识库
-	return s.knowledgeRepo.AddDocument(kbID, doc)
+	logger.FromContext(ctx).Info("Creating default knowledge base")
+	return s.knowledgeRepo.Create(&knowledge.KnowledgeBase{
+		ID:          knowledge.DefaultID,
+		Name:        knowledge.DefaultID,
+		Description: "Default knowledge base",
+	})
 }
 
-func (s *KnowledgeService) QueryKnowledge(ctx context.Context, kbID string, q *query.Query) (*query.QueryResult, error) {
-	// 1. 验证知识库存在
-	if _, err := s.knowledgeRepo.FindByID(kbID); err != nil {
+// Create 创建知识库，splitter 为空时使用默认分块器
+func (s *KnowledgeService) Create(ctx context.Context, name, description, splitter string) (*knowledge.KnowledgeBase, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrInvalidName
+	}
+	if err := document.ValidateSplitter(splitter); err != nil {
+		return nil, err
+	}
+
+	kb := &knowledge.KnowledgeBase{Name: name, Description: description, Splitter: splitter}
+	if err := s.knowledgeRepo.Create(kb); err != nil {
+		return nil, err
+	}
+	logger.FromContext(ctx).Infof("Created knowledge base %s (%s)", kb.ID, kb.Name)
+	return kb, nil
+}
+
+func (s *KnowledgeService) List(ctx context.Context) ([]*knowledge.KnowledgeBase, error) {
+	return s.knowledgeRepo.List()
+}
+
+func (s *KnowledgeService) Get(ctx context.Context, kbID string) (*knowledge.KnowledgeBase, error) {
+	return s.knowledgeRepo.FindByID(kbID)
+}
+
+// Update 修改知识库名称、描述和分块器，splitter 为空时保留原分块器
+func (s *KnowledgeService) Update(ctx context.Context, kbID, name, description, splitter string) (*knowledge.KnowledgeBase, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrInvalidName
+	}
+	if err := document.ValidateSplitter(splitter); err != nil {
 		return nil, err
 	}
 
-	// 2. 执行查询
-	return s.queryService.Execute(ctx, q)
+	kb, err := s.knowledgeRepo.FindByID(kbID)
+	if err != nil {
+		return nil, err
+	}
+	kb.Name = name
+	kb.Description = description
+	if splitter != "" {
+		kb.Splitter = splitter
+	}
+	if err := s.knowledgeRepo.Update(kb); err != nil {
+		return nil, err
+	}
+	return kb, nil
+}
+
+// Delete 先停止知识库的入库任务，再删除知识库内的全部向量，最后删除知识库记录
+// 向量删除失败时保留记录并恢复上传，便于重试
+func (s *KnowledgeService) Delete(ctx context.Context, kbID string) (err error) {
+	if kbID == knowledge.DefaultID {
+		return knowledge.ErrDefaultImmutable
+	}
+	if _, err := s.knowledgeRepo.FindByID(kbID); err != nil {
+		return err
+	}
+
+	if s.jobs != nil {
+		defer func() {
+			if err != nil {
+				s.jobs.ReopenKnowledgeBase(kbID)
+			}
+		}()
+		if err := s.jobs.CloseKnowledgeBase(ctx, kbID); err != nil {
+			return fmt.Errorf("failed to stop ingestion jobs of knowledge base %s: %w", kbID, err)
+		}
+	}
+	if err := s.docRepo.DeleteByKnowledgeBase(ctx, kbID); err != nil {
+		return fmt.Errorf("failed to delete documents of knowledge base %s: %w", kbID, err)
+	}
+	if err := s.knowledgeRepo.Delete(kbID); err != nil {
+		return err
+	}
+	logger.FromContext(ctx).Infof("Deleted knowledge base %s", kbID)
+	return nil
 }
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/application/commands"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/document"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/embedding"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/job"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/knowledge"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/infrastructure/persistence/memory"
+)
+
+// blockingEmbedder 在 release 关闭前阻塞且不响应取消，模拟已发出的嵌入请求
+type blockingEmbedder struct {
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (e *blockingEmbedder) unblock() {
+	e.once.Do(func() { close(e.release) })
+}
+
+func (e *blockingEmbedder) Embed(ctx context.Context, text string) (*embedding.Embedding, error) {
+	return &embedding.Embedding{Vector: []float32{1, 0, 0}}, nil
+}
+
+func (e *blockingEmbedder) EmbedBatch(ctx context.Context, texts []string) ([]*embedding.Embedding, error) {
+	e.started <- struct{}{}
+	<-e.release
+	results := make([]*embedding.Embedding, len(texts))
+	for i := range texts {
+		results[i], _ = e.Embed(ctx, texts[i])
+	}
+	return results, nil
+}
+
+func waitJob(t *testing.T, jobs job.Repository, id string, want job.State) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		j, err := jobs.FindByID(id)
+		if err == nil && j.State == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s = %+v, %v, want state %s", id, j, err, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDeleteKnowledgeBaseStopsIngestion(t *testing.T) {
+	ctx := context.Background()
+	docRepo := memory.NewMemoryDocumentRepository(memory.MetricCosine)
+	knowledgeRepo := memory.NewKnowledgeRepository()
+	jobs := memory.NewJobRepository()
+	embedder := &blockingEmbedder{started: make(chan struct{}), release: make(chan struct{})}
+	upload := commands.NewUploadDocumentHandler(document.NewParserFactory(512, 50), embedder, docRepo, knowledgeRepo)
+	ingestion := NewIngestionService(upload, jobs, t.TempDir(), 1, 10)
+	kbs := NewKnowledgeService(docRepo, knowledgeRepo, WithKnowledgeJobs(ingestion))
+	if err := ingestion.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer ingestion.Shutdown(ctx)
+	defer embedder.unblock()
+
+	kb, err := kbs.Create(ctx, "temp", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	submit := func(name string) (*job.Job, error) {
+		return ingestion.Submit(ctx, commands.UploadDocumentCommand{
+			Filename:        name,
+			FileContent:     []byte("alpha beta gamma"),
+			KnowledgeBaseID: kb.ID,
+		})
+	}
+	running, err := submit("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-embedder.started
+	// 唯一的工作协程被占用，第二个任务保持排队
+	queued, err := submit("b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deleted := make(chan error, 1)
+	go func() { deleted <- kbs.Delete(ctx, kb.ID) }()
+	waitJob(t, jobs, queued.ID, job.StateCancelled)
+	select {
+	case err := <-deleted:
+		t.Fatalf("Delete returned %v before the running job stopped", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// 运行中的任务写入向量后才删除知识库内容
+	embedder.unblock()
+	if err := <-deleted; err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	docs, err := docRepo.ListDocuments(ctx, kb.ID)
+	if err != nil || len(docs) != 0 {
+		t.Errorf("documents left in deleted knowledge base = %+v, %v", docs, err)
+	}
+	if _, err := submit("c.txt"); !errors.Is(err, knowledge.ErrNotFound) {
+		t.Errorf("Submit after delete err = %v, want knowledge.ErrNotFound", err)
+	}
+	if j, _ := jobs.FindByID(running.ID); !j.State.Terminal() {
+		t.Errorf("running job state = %s, want finished", j.State)
+	}
+}
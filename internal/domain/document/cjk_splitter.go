@@ -0,0 +1,174 @@
+package document
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// cjkThreshold 中日文字符占全部字母的比例达到该值时按中日文分块
+const cjkThreshold = 0.3
+
+// isCJKText 检测文本是否以中日文为主
+func isCJKText(content string) bool {
+	cjk, letters := 0, 0
+	for _, r := range content {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		if isCJKRune(r) {
+			cjk++
+		}
+	}
+	return letters > 0 && float64(cjk)/float64(letters) >= cjkThreshold
+}
+
+func isCJKRune(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana)
+}
+
+// isSentenceEnd 句末标点，中日文全角标点和英文的 ! ? ;
+func isSentenceEnd(r rune) bool {
+	switch r {
+	case '。', '！', '？', '；', '…', '!', '?', ';':
+		return true
+	}
+	return false
+}
+
+// isClauseEnd 句内停顿标点，超长句子优先在这些位置切开
+func isClauseEnd(r rune) bool {
+	switch r {
+	case '，', '、', '：', ',', ':':
+		return true
+	}
+	return false
+}
+
+// isCloser 跟在句末标点后的右引号和右括号，归入前一句
+func isCloser(r rune) bool {
+	switch r {
+	case '”', '’', '"', '\'', '」', '』', '）', ')', '】', '》', '〉':
+		return true
+	}
+	return false
+}
+
+// cjkStrategy 中日文以字符计数：句子 → 行 → 逗号等停顿 → 字符窗口
+// 字符窗口长度为 size-overlap，为相邻窗口之间的重叠留出空间
+func (s *TextSplitter) cjkStrategy() *splitStrategy {
+	size, overlap := s.ChunkSize, s.ChunkOverlap
+	if s.CJKChunkSize > 0 {
+		size, overlap = s.CJKChunkSize, s.CJKChunkOverlap
+	}
+	return &splitStrategy{
+		size:    size,
+		overlap: overlap,
+		count:   utf8.RuneCountInString,
+		levels: []splitLevel{
+			{split: func(text string) []string { return splitSentences(text, isSentenceEnd, true) }, sep: sentenceSep},
+			{split: splitLines, sep: func(string) string { return "\n" }},
+			{split: func(text string) []string { return splitSentences(text, isClauseEnd, false) }, sep: sentenceSep},
+		},
+		fallback: func(text string, size int) []string {
+			return windows(text, size-overlap)
+		},
+		tail:    cjkTail,
+		prepare: joinLines,
+	}
+}
+
+// joinLines 合并段落内被折行打断的句子：两侧都是中日文字符时直接相连，否则以空格连接
+// 含有制表符或竖线的表格内容保留换行
+func joinLines(para string) string {
+	if strings.ContainsAny(para, "\t|") {
+		return para
+	}
+	var b strings.Builder
+	for _, line := range strings.Split(para, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			prev, _ := utf8.DecodeLastRuneInString(b.String())
+			next, _ := utf8.DecodeRuneInString(line)
+			if !isCJKRune(prev) || !isCJKRune(next) {
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+// splitSentences 在 isEnd 标点及其后的右引号、右括号之后切开
+// period 为 true 时英文句号后面是空白也视为句末，排除小数和缩写中的点
+func splitSentences(text string, isEnd func(rune) bool, period bool) []string {
+	var sentences []string
+	runes := []rune(text)
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		end := isEnd(r)
+		if !end && period && r == '.' {
+			end = i+1 == len(runes) || unicode.IsSpace(runes[i+1])
+		}
+		if !end {
+			continue
+		}
+		for i+1 < len(runes) && (isEnd(runes[i+1]) || isCloser(runes[i+1])) {
+			i++
+		}
+		if s := strings.TrimSpace(string(runes[start : i+1])); s != "" {
+			sentences = append(sentences, s)
+		}
+		start = i + 1
+	}
+	if s := strings.TrimSpace(string(runes[start:])); s != "" {
+		sentences = append(sentences, s)
+	}
+	return sentences
+}
+
+// windows 按每 size 个字符切分超长文本
+func windows(text string, size int) []string {
+	if size < 1 {
+		size = 1
+	}
+	runes := []rune(text)
+	var pieces []string
+	for len(runes) > 0 {
+		n := size
+		if n > len(runes) {
+			n = len(runes)
+		}
+		pieces = append(pieces, string(runes[:n]))
+		runes = runes[n:]
+	}
+	return pieces
+}
+
+// cjkTail 取末尾 limit 个字符，其中有句末或停顿标点时从标点之后开始
+func cjkTail(text string, limit int) string {
+	runes := []rune(text)
+	if limit >= len(runes) {
+		return text
+	}
+	tail := runes[len(runes)-limit:]
+	for i, r := range tail[:len(tail)-1] {
+		if isSentenceEnd(r) || isClauseEnd(r) {
+			return strings.TrimSpace(string(tail[i+1:]))
+		}
+	}
+	return string(tail)
+}
+
+// sentenceSep 中日文句子之间不加分隔符，以英文结尾时补一个空格
+func sentenceSep(sentence string) string {
+	if last, _ := utf8.DecodeLastRuneInString(sentence); last < utf8.RuneSelf {
+		return " "
+	}
+	return ""
+}
@@ -0,0 +1,109 @@
+package document
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// testdata/gbk.txt 为 GBK 编码、CRLF 换行的中文文本：第一段由 。！？； 结尾的句子组成，第二段是没有标点的长句
+const gbkFixture = "testdata/gbk.txt"
+
+func parseFixture(t *testing.T, size, overlap int) []string {
+	t.Helper()
+	factory := NewParserFactory(512, 50, WithCJKChunkSize(size, overlap))
+	detected, err := DetectFileType(gbkFixture)
+	if err != nil {
+		t.Fatalf("DetectFileType: %v", err)
+	}
+	if detected != TypeText {
+		t.Fatalf("detected %s, want %s", detected, TypeText)
+	}
+	parser, err := factory.ParserForType(detected)
+	if err != nil {
+		t.Fatalf("ParserForType: %v", err)
+	}
+	docs, err := parser.Parse(gbkFixture)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	chunks := make([]string, len(docs))
+	for i, doc := range docs {
+		chunks[i] = strings.ReplaceAll(doc.Content, "\n", "")
+	}
+	return chunks
+}
+
+func TestTextParserSplitsGBKOnSentencePunctuation(t *testing.T) {
+	chunks := parseFixture(t, 16, 0)
+	want := []string{
+		"知识库中间件支持多种文档格式。",
+		"上传的文件会被自动解析！",
+		"系统能识别中文编码吗？当然可以；",
+		"它会先转换为统一编码。",
+		// 长句放不下时按字符窗口切分
+		"这是一段没有任何标点符号的超长文",
+		"本用于验证按字符窗口切分的回退逻",
+		"辑",
+	}
+	if !reflect.DeepEqual(chunks, want) {
+		t.Errorf("chunks =\n%q\nwant\n%q", chunks, want)
+	}
+}
+
+func TestTextParserCJKWindowsWithOverlap(t *testing.T) {
+	const size, overlap = 16, 4
+	chunks := parseFixture(t, size, overlap)
+	if len(chunks) < 2 {
+		t.Fatalf("got %d chunks, want several", len(chunks))
+	}
+	for i, c := range chunks {
+		if n := utf8.RuneCountInString(c); n > size {
+			t.Errorf("chunk %d %q has %d characters, want at most %d", i, c, n, size)
+		}
+		if i == 0 {
+			continue
+		}
+		prev := []rune(chunks[i-1])
+		if tail := string(prev[len(prev)-overlap:]); !strings.HasPrefix(c, tail) {
+			t.Errorf("chunk %d %q does not start with the %d-character overlap %q", i, c, overlap, tail)
+		}
+	}
+	if last := chunks[len(chunks)-1]; !strings.HasSuffix(last, "回退逻辑") {
+		t.Errorf("last chunk %q, want the end of the long sentence", last)
+	}
+}
+
+func TestSplitAutoSelectsCJK(t *testing.T) {
+	newSplitter := func(mode SplitMode) *TextSplitter {
+		s := NewTextSplitter(512, 0)
+		s.Mode = mode
+		s.CJKChunkSize = 12
+		return s
+	}
+	split := func(mode SplitMode, text string) []string {
+		chunks, err := newSplitter(mode).Split(text)
+		if err != nil {
+			t.Fatalf("Split(%s): %v", mode, err)
+		}
+		return chunks
+	}
+
+	chinese := "今天天气很好。我们去公园散步吧！你觉得怎么样？"
+	auto := split(SplitAuto, chinese)
+	if want := split(SplitCJK, chinese); !reflect.DeepEqual(auto, want) {
+		t.Errorf("auto mode on Chinese text = %q, want the CJK split %q", auto, want)
+	}
+	if len(auto) != 3 {
+		t.Errorf("auto mode on Chinese text = %q, want one chunk per sentence", auto)
+	}
+	if words := split(SplitWords, chinese); len(words) != 1 {
+		t.Errorf("words mode = %q, want a single byte-counted chunk", words)
+	}
+
+	english := "The weather is nice today. Shall we take a walk in the park? What do you think?"
+	if got := split(SplitAuto, english); len(got) != 1 || strings.TrimSpace(got[0]) != english {
+		t.Errorf("auto mode on English text = %q, want the words split", got)
+	}
+}
@@ -0,0 +1,56 @@
+package document
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"unicode/utf8"
+
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/infrastructure/msoffice"
+)
+
+// DOCParser 解析 Word 97-2003 (.doc) 二进制文档
+type DOCParser struct {
+	textSplitter *TextSplitter
+}
+
+func NewDOCParser(chunkSize, chunkOverlap int) *DOCParser {
+	return &DOCParser{
+		textSplitter: NewTextSplitter(chunkSize, chunkOverlap),
+	}
+}
+
+func (p *DOCParser) Parse(filePath string) ([]*Document, error) {
+	return ParseWith(context.Background(), p, p.textSplitter, filePath)
+}
+
+// ParseSections 按正文顺序读取段落和表格，内置标题样式的段落划分章节
+// 段落号和字符偏移的计法与 DOCXParser 相同：表格计为一个段落，每个段落之后换行
+func (p *DOCParser) ParseSections(filePath string) (*ParsedDocument, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Word file: %w", err)
+	}
+	defer file.Close()
+
+	paragraphs, err := msoffice.ReadDoc(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read legacy Word file: %w", err)
+	}
+
+	var b sectionBuilder
+	offset := 0
+	for i, para := range paragraphs {
+		if para.Level > 0 {
+			b.Heading(para.Level, para.Text)
+		} else {
+			b.Block(para.Text, Source{Paragraph: i + 1, Offset: offset})
+		}
+		offset += utf8.RuneCountInString(para.Text) + 1
+	}
+	return &ParsedDocument{ContentType: "application/msword", Sections: b.Sections()}, nil
+}
+
+func (p *DOCParser) SupportedExtensions() []string {
+	return []string{".doc"}
+}
@@ -2,37 +2,147 @@ package document
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"time"
 )
 
+// ErrNotFound 文档不存在
+var ErrNotFound = errors.New("document not found")
+
+// Document 为上传文档的一个分块，同一次上传的分块共享 Metadata.DocumentID
 type Document struct {
 	ID        string
 	Content   string
 	Metadata  Metadata
 	Vector    []float32
 	CreatedAt time.Time
+	Score     float64 // 检索时与查询向量的距离，仅在 Search 结果中有效
 }
 
 type Metadata struct {
-	Filename     string
-	ContentType  string
-	Size         int64
-	Custom       map[string]interface{}
-	UploadTime   time.Time
-	OriginalFile string
+	KnowledgeBaseID string                 `json:"kb_id,omitempty"`       // 所属知识库
+	DocumentID      string                 `json:"document_id,omitempty"` // 所属上传文档
+	ChunkIndex      int                    `json:"chunk_index"`           // 分块在文档中的序号，从0开始
+	ChunkCount      int                    `json:"chunk_count,omitempty"` // 文档的分块总数
+	Provenance                             // 分块在源文件中的页码、工作表行号、段落号和字符偏移
+	Uploader        string                 `json:"uploader,omitempty"`
+	FileHash        string                 `json:"file_hash,omitempty"`    // 上传文件的 SHA-256
+	ContentHash     string                 `json:"content_hash,omitempty"` // 分块内容的 SHA-256
+	Filename        string                 `json:"filename"`
+	ContentType     string                 `json:"content_type"`
+	Size            int64                  `json:"size"` // 上传文件的字节数
+	Custom          map[string]interface{} `json:"custom,omitempty"`
+	UploadTime      time.Time              `json:"upload_time"`
+	OriginalFile    string                 `json:"original_file"`
 }
 
+// 从归档中展开的文件在 Metadata.Custom 中记录来源
+const (
+	ArchiveKey     = "archive"      // 上传的归档文件名
+	ArchivePathKey = "archive_path" // 文件在归档内的路径
+)
+
 type DocumentRepository interface {
 	Store(ctx context.Context, doc *Document) error
 	StoreBatch(ctx context.Context, docs []*Document) error
 	FindByID(ctx context.Context, id string) (*Document, error)
-	Search(ctx context.Context, embedding []float32, topK int) ([]*Document, error)
+	// Search 在指定知识库内检索，kbID 为空时检索默认知识库
+	Search(ctx context.Context, kbID string, embedding []float32, topK int) ([]*Document, error)
+	// DeleteByKnowledgeBase 删除知识库内的全部向量
+	DeleteByKnowledgeBase(ctx context.Context, kbID string) error
+
+	// FindDocumentByHash 查找知识库内文件哈希相同的文档，不存在时返回 nil, nil
+	FindDocumentByHash(ctx context.Context, kbID string, fileHash string) (*DocumentInfo, error)
+	// ListDocuments 列出知识库内的上传文档
+	ListDocuments(ctx context.Context, kbID string) ([]*DocumentInfo, error)
+	// FindChunks 按分块序号返回文档的全部分块(含向量)，文档不存在时返回空切片
+	FindChunks(ctx context.Context, documentID string) ([]*Document, error)
+	// ReplaceDocument 以新的分块替换文档原有分块，写入失败时保留原有分块
+	// 沿用原分块ID的分块被覆盖，其余原分块被删除
+	ReplaceDocument(ctx context.Context, documentID string, chunks []*Document) error
+	// DeleteDocument 删除文档的全部分块，文档不存在时返回 ErrNotFound
+	DeleteDocument(ctx context.Context, documentID string) error
+}
+
+// DocumentInfo 上传文档的概要信息，由其分块的元数据汇总而来
+type DocumentInfo struct {
+	ID              string    `json:"id"`
+	KnowledgeBaseID string    `json:"kb_id"`
+	Filename        string    `json:"filename"`
+	ContentType     string    `json:"content_type"`
+	ChunkCount      int       `json:"chunk_count"`
+	UploadTime      time.Time `json:"upload_time"`
+	Uploader        string    `json:"uploader,omitempty"`
+	FileHash        string    `json:"file_hash,omitempty"`
+}
+
+// InfoFromChunk 从文档任一分块的元数据还原文档概要信息
+func InfoFromChunk(chunk *Document) *DocumentInfo {
+	m := chunk.Metadata
+	filename := m.OriginalFile
+	if filename == "" {
+		filename = m.Filename
+	}
+	return &DocumentInfo{
+		ID:              m.DocumentID,
+		KnowledgeBaseID: m.KnowledgeBaseID,
+		Filename:        filename,
+		ContentType:     m.ContentType,
+		ChunkCount:      m.ChunkCount,
+		UploadTime:      m.UploadTime,
+		Uploader:        m.Uploader,
+		FileHash:        m.FileHash,
+	}
+}
+
+// NewID 生成32位十六进制随机ID
+func NewID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
 }
 
 type DocumentParser interface {
 	Parse(filePath string) ([]*Document, error)
 }
 
+// ParsedDocument 解析得到的章节结构，尚未分块
+type ParsedDocument struct {
+	ContentType string
+	Sections    []Section
+	// Metadata 文档级属性，如 Markdown 的 front matter、HTML 的 title 和 meta，写入每个分块的 Metadata.Custom
+	Metadata map[string]interface{}
+}
+
+// SectionParser 输出章节结构的解析器，由调用方选择分块器
+type SectionParser interface {
+	DocumentParser
+	ParseSections(filePath string) (*ParsedDocument, error)
+}
+
+// DocumentSplitter 将章节切分为分块，语义分块需要调用嵌入服务，因此接收 ctx
 type DocumentSplitter interface {
-	Split(content string) ([]string, error)
+	SplitSections(ctx context.Context, sections []Section) ([]Chunk, error)
+}
+
+// ParseWith 解析文档并使用 splitter 分块，解析器不输出章节结构时使用其自带的分块方式
+func ParseWith(ctx context.Context, parser DocumentParser, splitter DocumentSplitter, filePath string) ([]*Document, error) {
+	sp, ok := parser.(SectionParser)
+	if !ok {
+		return parser.Parse(filePath)
+	}
+	parsed, err := sp.ParseSections(filePath)
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := splitter.SplitSections(ctx, parsed.Sections)
+	if err != nil {
+		return nil, fmt.Errorf("failed to split %s content: %w", parsed.ContentType, err)
+	}
+	return newChunkDocuments(chunks, filePath, parsed), nil
 }
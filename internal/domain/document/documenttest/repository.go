@@ -0,0 +1,310 @@
+// Package documenttest 提供 DocumentRepository 各实现共用的行为测试
+package documenttest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/document"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/knowledge"
+)
+
+// baseTime 测试文档的上传时间，doc-a、doc-b、doc-c 依次晚一小时
+var baseTime = time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+var uploadOffsets = map[string]time.Duration{"doc-a": 0, "doc-b": time.Hour, "doc-c": 2 * time.Hour}
+
+// chunk 返回文档 docID 的第 index 个分块，同一文档的分块共享文件信息
+func chunk(id, kbID, docID string, index int, content string, vector []float32) *document.Document {
+	return &document.Document{
+		ID:      id,
+		Content: content,
+		Vector:  vector,
+		Metadata: document.Metadata{
+			KnowledgeBaseID: kbID,
+			DocumentID:      docID,
+			ChunkIndex:      index,
+			ChunkCount:      2,
+			FileHash:        "hash-" + docID,
+			Filename:        docID + ".txt",
+			ContentType:     "text/plain",
+			UploadTime:      baseTime.Add(uploadOffsets[docID]),
+		},
+	}
+}
+
+func ids(docs []*document.Document) []string {
+	out := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		out = append(out, doc.ID)
+	}
+	return out
+}
+
+func store(t *testing.T, repo document.DocumentRepository, docs ...*document.Document) {
+	t.Helper()
+	if err := repo.StoreBatch(context.Background(), docs); err != nil {
+		t.Fatalf("StoreBatch: %v", err)
+	}
+}
+
+func search(t *testing.T, repo document.DocumentRepository, kbID string, embedding []float32, topK int) []*document.Document {
+	t.Helper()
+	hits, err := repo.Search(context.Background(), kbID, embedding, topK)
+	if err != nil {
+		t.Fatalf("Search(%q): %v", kbID, err)
+	}
+	return hits
+}
+
+func findChunks(t *testing.T, repo document.DocumentRepository, documentID string) []*document.Document {
+	t.Helper()
+	chunks, err := repo.FindChunks(context.Background(), documentID)
+	if err != nil {
+		t.Fatalf("FindChunks(%q): %v", documentID, err)
+	}
+	return chunks
+}
+
+// TestRepository 对 newRepo 返回的空仓库验证 DocumentRepository 的约定
+// 测试数据的向量为3维，newRepo 创建的仓库应按余弦相似度检索
+func TestRepository(t *testing.T, newRepo func(t *testing.T) document.DocumentRepository) {
+	t.Run("StoreAndFindByID", func(t *testing.T) { testStoreAndFindByID(t, newRepo(t)) })
+	t.Run("Search", func(t *testing.T) { testSearch(t, newRepo(t)) })
+	t.Run("ListDocumentsAndFindByHash", func(t *testing.T) { testListDocuments(t, newRepo(t)) })
+	t.Run("FindChunks", func(t *testing.T) { testFindChunks(t, newRepo(t)) })
+	t.Run("ReplaceDocument", func(t *testing.T) { testReplaceDocument(t, newRepo(t)) })
+	t.Run("DeleteDocument", func(t *testing.T) { testDeleteDocument(t, newRepo(t)) })
+	t.Run("DeleteByKnowledgeBase", func(t *testing.T) { testDeleteByKnowledgeBase(t, newRepo(t)) })
+}
+
+func testStoreAndFindByID(t *testing.T, repo document.DocumentRepository) {
+	ctx := context.Background()
+	generated := chunk("", "team", "doc-a", 0, "first", []float32{0.1, 0.2, 0.3})
+	fixed := chunk("fixed", "team", "doc-a", 1, "second", []float32{0.4, 0.5, 0.6})
+	store(t, repo, generated, fixed)
+
+	if generated.ID == "" {
+		t.Fatal("StoreBatch did not assign an id")
+	}
+	for _, want := range []*document.Document{generated, fixed} {
+		got, err := repo.FindByID(ctx, want.ID)
+		if err != nil {
+			t.Fatalf("FindByID(%s): %v", want.ID, err)
+		}
+		if got == nil {
+			t.Fatalf("FindByID(%s) returned nil", want.ID)
+		}
+		if got.Content != want.Content || !reflect.DeepEqual(got.Vector, want.Vector) {
+			t.Errorf("FindByID(%s) = %q %v, want %q %v", want.ID, got.Content, got.Vector, want.Content, want.Vector)
+		}
+		m := got.Metadata
+		if m.KnowledgeBaseID != "team" || m.DocumentID != "doc-a" || m.ChunkIndex != want.Metadata.ChunkIndex ||
+			m.Filename != "doc-a.txt" || !m.UploadTime.Equal(want.Metadata.UploadTime) {
+			t.Errorf("FindByID(%s) metadata = %+v", want.ID, m)
+		}
+	}
+
+	missing, err := repo.FindByID(ctx, "missing")
+	if err != nil || missing != nil {
+		t.Errorf("FindByID(missing) = %v, %v; want nil, nil", missing, err)
+	}
+}
+
+func testSearch(t *testing.T, repo document.DocumentRepository) {
+	store(t, repo,
+		chunk("a", "team", "doc-a", 0, "alpha", []float32{1, 0, 0}),
+		chunk("b", "team", "doc-b", 0, "beta", []float32{0, 1, 0}),
+		chunk("c", "", "doc-c", 0, "gamma", []float32{1, 0, 0}),
+	)
+
+	hits := search(t, repo, "team", []float32{1, 0, 0}, 5)
+	if got := ids(hits); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Fatalf("Search(team) = %v, want [a b]", got)
+	}
+	for _, hit := range hits {
+		if hit.Content == "" || hit.Metadata.KnowledgeBaseID != "team" || hit.Metadata.DocumentID == "" {
+			t.Errorf("hit %s = %q %+v, want content and metadata", hit.ID, hit.Content, hit.Metadata)
+		}
+		// 检索结果不返回向量，调用方只需要内容和元数据
+		if hit.Vector != nil {
+			t.Errorf("hit %s carries its vector", hit.ID)
+		}
+	}
+	if got := ids(search(t, repo, "team", []float32{1, 0, 0}, 1)); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("Search(team, topK 1) = %v, want [a]", got)
+	}
+
+	for _, kbID := range []string{"", knowledge.DefaultID} {
+		if got := ids(search(t, repo, kbID, []float32{1, 0, 0}, 5)); !reflect.DeepEqual(got, []string{"c"}) {
+			t.Errorf("Search(%q) = %v, want the default knowledge base [c]", kbID, got)
+		}
+	}
+	if got := search(t, repo, "unknown", []float32{1, 0, 0}, 5); len(got) != 0 {
+		t.Errorf("Search(unknown) = %v, want no hits", ids(got))
+	}
+	if _, err := repo.Search(context.Background(), "team", []float32{1, 0}, 5); err == nil {
+		t.Error("expected an error for a query vector of the wrong dimension")
+	}
+}
+
+func testListDocuments(t *testing.T, repo document.DocumentRepository) {
+	ctx := context.Background()
+	store(t, repo,
+		chunk("a0", "team", "doc-a", 0, "a0", []float32{1, 0, 0}),
+		chunk("a1", "team", "doc-a", 1, "a1", []float32{0, 1, 0}),
+		chunk("b0", "team", "doc-b", 0, "b0", []float32{0, 0, 1}),
+		chunk("c0", "other", "doc-c", 0, "c0", []float32{1, 1, 0}),
+	)
+
+	infos, err := repo.ListDocuments(ctx, "team")
+	if err != nil {
+		t.Fatalf("ListDocuments: %v", err)
+	}
+	var got []string
+	for _, info := range infos {
+		got = append(got, info.ID)
+		if info.KnowledgeBaseID != "team" || info.Filename != info.ID+".txt" || info.ChunkCount != 2 || info.FileHash != "hash-"+info.ID {
+			t.Errorf("info %s = %+v", info.ID, info)
+		}
+	}
+	if !reflect.DeepEqual(got, []string{"doc-a", "doc-b"}) {
+		t.Errorf("ListDocuments(team) = %v, want [doc-a doc-b]", got)
+	}
+	if infos, err := repo.ListDocuments(ctx, "unknown"); err != nil || len(infos) != 0 {
+		t.Errorf("ListDocuments(unknown) = %v, %v; want none", infos, err)
+	}
+
+	info, err := repo.FindDocumentByHash(ctx, "team", "hash-doc-b")
+	if err != nil || info == nil || info.ID != "doc-b" {
+		t.Errorf("FindDocumentByHash(team, doc-b) = %+v, %v", info, err)
+	}
+	for _, tc := range []struct{ kbID, hash string }{{"other", "hash-doc-b"}, {"team", "missing"}, {"unknown", "hash-doc-a"}} {
+		if info, err := repo.FindDocumentByHash(ctx, tc.kbID, tc.hash); err != nil || info != nil {
+			t.Errorf("FindDocumentByHash(%s, %s) = %+v, %v; want nil, nil", tc.kbID, tc.hash, info, err)
+		}
+	}
+}
+
+func testFindChunks(t *testing.T, repo document.DocumentRepository) {
+	// 后写入的分块序号更小，结果仍按分块序号排列
+	store(t, repo,
+		chunk("a1", "team", "doc-a", 1, "second", []float32{0, 1, 0}),
+		chunk("a0", "team", "doc-a", 0, "first", []float32{1, 0, 0}),
+		chunk("b0", "team", "doc-b", 0, "other", []float32{0, 0, 1}),
+	)
+
+	chunks := findChunks(t, repo, "doc-a")
+	if got := ids(chunks); !reflect.DeepEqual(got, []string{"a0", "a1"}) {
+		t.Fatalf("FindChunks(doc-a) = %v, want [a0 a1]", got)
+	}
+	if !reflect.DeepEqual(chunks[0].Vector, []float32{1, 0, 0}) || chunks[1].Content != "second" {
+		t.Errorf("FindChunks(doc-a) = %+v", chunks)
+	}
+	if got := findChunks(t, repo, "missing"); len(got) != 0 {
+		t.Errorf("FindChunks(missing) = %v, want none", ids(got))
+	}
+}
+
+func testReplaceDocument(t *testing.T, repo document.DocumentRepository) {
+	ctx := context.Background()
+	store(t, repo,
+		chunk("a0", "team", "doc-a", 0, "old first", []float32{1, 0, 0}),
+		chunk("a1", "team", "doc-a", 1, "old second", []float32{0, 1, 0}),
+		chunk("b0", "team", "doc-b", 0, "untouched", []float32{0, 0, 1}),
+	)
+
+	// 沿用 a0 的ID覆盖，a1 被删除，a2 新增
+	err := repo.ReplaceDocument(ctx, "doc-a", []*document.Document{
+		chunk("a0", "team", "doc-a", 0, "new first", []float32{1, 0, 0}),
+		chunk("a2", "team", "doc-a", 1, "new second", []float32{0, 1, 0}),
+	})
+	if err != nil {
+		t.Fatalf("ReplaceDocument: %v", err)
+	}
+	chunks := findChunks(t, repo, "doc-a")
+	if got := ids(chunks); !reflect.DeepEqual(got, []string{"a0", "a2"}) {
+		t.Fatalf("chunks after replace = %v, want [a0 a2]", got)
+	}
+	if chunks[0].Content != "new first" {
+		t.Errorf("a0 content = %q, want the replacement", chunks[0].Content)
+	}
+	if old, err := repo.FindByID(ctx, "a1"); err != nil || old != nil {
+		t.Errorf("FindByID(a1) = %v, %v; want the stale chunk removed", old, err)
+	}
+	if got := ids(findChunks(t, repo, "doc-b")); !reflect.DeepEqual(got, []string{"b0"}) {
+		t.Errorf("FindChunks(doc-b) = %v, want [b0]", got)
+	}
+
+	if err := repo.ReplaceDocument(ctx, "doc-a", nil); err == nil {
+		t.Error("expected an error when replacing with no chunks")
+	}
+	if got := ids(findChunks(t, repo, "doc-a")); !reflect.DeepEqual(got, []string{"a0", "a2"}) {
+		t.Errorf("chunks after the rejected replace = %v, want [a0 a2]", got)
+	}
+}
+
+func testDeleteDocument(t *testing.T, repo document.DocumentRepository) {
+	ctx := context.Background()
+	store(t, repo,
+		chunk("a0", "team", "doc-a", 0, "a0", []float32{1, 0, 0}),
+		chunk("a1", "team", "doc-a", 1, "a1", []float32{0, 1, 0}),
+		chunk("b0", "team", "doc-b", 0, "b0", []float32{0, 0, 1}),
+	)
+
+	if err := repo.DeleteDocument(ctx, "doc-a"); err != nil {
+		t.Fatalf("DeleteDocument: %v", err)
+	}
+	if got := findChunks(t, repo, "doc-a"); len(got) != 0 {
+		t.Errorf("FindChunks(doc-a) after delete = %v", ids(got))
+	}
+	if got := ids(search(t, repo, "team", []float32{1, 0, 0}, 5)); !reflect.DeepEqual(got, []string{"b0"}) {
+		t.Errorf("Search after delete = %v, want [b0]", got)
+	}
+	if err := repo.DeleteDocument(ctx, "doc-a"); !errors.Is(err, document.ErrNotFound) {
+		t.Errorf("second DeleteDocument = %v, want ErrNotFound", err)
+	}
+}
+
+func testDeleteByKnowledgeBase(t *testing.T, repo document.DocumentRepository) {
+	ctx := context.Background()
+	store(t, repo,
+		chunk("a0", "team", "doc-a", 0, "team", []float32{1, 0, 0}),
+		chunk("c0", "", "doc-c", 0, "default", []float32{1, 0, 0}),
+	)
+
+	if err := repo.DeleteByKnowledgeBase(ctx, "team"); err != nil {
+		t.Fatalf("DeleteByKnowledgeBase(team): %v", err)
+	}
+	if got := search(t, repo, "team", []float32{1, 0, 0}, 5); len(got) != 0 {
+		t.Errorf("Search(team) after delete = %v", ids(got))
+	}
+	if infos, err := repo.ListDocuments(ctx, "team"); err != nil || len(infos) != 0 {
+		t.Errorf("ListDocuments(team) after delete = %v, %v", infos, err)
+	}
+	if got := ids(search(t, repo, "", []float32{1, 0, 0}, 5)); !reflect.DeepEqual(got, []string{"c0"}) {
+		t.Errorf("Search(default) = %v, want [c0] untouched", got)
+	}
+	if err := repo.DeleteByKnowledgeBase(ctx, "team"); err != nil {
+		t.Errorf("deleting an empty knowledge base: %v", err)
+	}
+
+	// 删除后仍可重新写入同名知识库
+	store(t, repo, chunk("b0", "team", "doc-b", 0, "again", []float32{1, 0, 0}))
+	if got := ids(search(t, repo, "team", []float32{1, 0, 0}, 5)); !reflect.DeepEqual(got, []string{"b0"}) {
+		t.Errorf("Search(team) after writing again = %v, want [b0]", got)
+	}
+
+	if err := repo.DeleteByKnowledgeBase(ctx, ""); err != nil {
+		t.Fatalf("DeleteByKnowledgeBase(default): %v", err)
+	}
+	if got := search(t, repo, "", []float32{1, 0, 0}, 5); len(got) != 0 {
+		t.Errorf("Search(default) after delete = %v", ids(got))
+	}
+	if got := ids(search(t, repo, "team", []float32{1, 0, 0}, 5)); !reflect.DeepEqual(got, []string{"b0"}) {
+		t.Errorf("Search(team) = %v, want [b0] untouched", got)
+	}
+}
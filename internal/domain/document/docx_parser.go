@@ -1,14 +1,21 @@
 package document
 
 import (
+	"context"
 	"fmt"
-	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/qifengzhang007/gooxml/document"
+	"github.com/qifengzhang007/gooxml/schema/soo/wml"
 	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/infrastructure/logger"
 )
 
+// headingStyleName 匹配 "heading 1"、"Heading1"、"标题 1" 等标题样式名称
+var headingStyleName = regexp.MustCompile(`(?i)^(heading|标题)\s*([1-9])$`)
+
 // DOCXParser implements DocumentParser for Microsoft Word (.docx) files
 type DOCXParser struct {
 	textSplitter *TextSplitter
@@ -23,6 +30,11 @@ func NewDOCXParser(chunkSize, chunkOverlap int) *DOCXParser {
 
 // Parse extracts text content from a DOCX file and splits it into chunks
 func (p *DOCXParser) Parse(filePath string) ([]*Document, error) {
+	return ParseWith(context.Background(), p, p.textSplitter, filePath)
+}
+
+// ParseSections 按正文顺序读取段落和表格，标题样式的段落划分章节，连续的列表项合并为一个内容块
+func (p *DOCXParser) ParseSections(filePath string) (*ParsedDocument, error) {
 	logger.Infof("Parsing DOCX file: %s", filePath)
 	doc, err := document.Open(filePath)
 	if err != nil {
@@ -31,53 +43,139 @@ func (p *DOCXParser) Parse(filePath string) ([]*Document, error) {
 	if doc == nil {
 		return nil, fmt.Errorf("opened DOCX document is nil")
 	}
-	var contentBuilder strings.Builder
-	// 提取所有段落文本
-	for _, para := range doc.Paragraphs() {
-		for _, run := range para.Runs() {
-			contentBuilder.WriteString(run.Text())
+
+	var b sectionBuilder
+	if body := doc.X().Body; body != nil {
+		paragraphs := make(map[*wml.CT_P]document.Paragraph)
+		for _, para := range doc.Paragraphs() {
+			paragraphs[para.X()] = para
 		}
-		contentBuilder.WriteString("\n\n") // 段落之间保留空行
-	}
+		tables := make(map[*wml.CT_Tbl]document.Table)
+		for _, tbl := range doc.Tables() {
+			tables[tbl.X()] = tbl
+		}
+		headingStyles := docxHeadingStyles(doc)
 
-	// 提取表格内容（可选）
-	for _, tbl := range doc.Tables() {
-		for _, row := range tbl.Rows() {
-			for _, cell := range row.Cells() {
-				for _, para := range cell.Paragraphs() {
-					for _, run := range para.Runs() {
-						contentBuilder.WriteString(run.Text())
+		// 段落号只计非空段落，表格计为一个段落；字符偏移以每个段落、列表项或表格行之后换行拼接的文本计
+		var list []string
+		var listSrc Source
+		paragraph, offset := 0, 0
+		flushList := func() {
+			b.Block(strings.Join(list, "\n"), listSrc)
+			list = nil
+		}
+		for _, ble := range body.EG_BlockLevelElts {
+			for _, c := range ble.EG_ContentBlockContent {
+				for _, x := range c.P {
+					para := paragraphs[x]
+					text := strings.TrimSpace(para.GetAllParagraphText(para))
+					if text == "" {
+						continue
+					}
+					paragraph++
+					src := Source{Paragraph: paragraph, Offset: offset}
+					if level := docxHeadingLevel(x, headingStyles); level > 0 {
+						flushList()
+						b.Heading(level, text)
+						offset += utf8.RuneCountInString(text) + 1
+						continue
+					}
+					if x.PPr != nil && x.PPr.NumPr != nil {
+						indent := 0
+						if x.PPr.NumPr.Ilvl != nil {
+							indent = int(x.PPr.NumPr.Ilvl.ValAttr)
+						}
+						if len(list) == 0 {
+							listSrc = src
+							listSrc.perLine = true
+						}
+						item := strings.Repeat("  ", indent) + "- " + text
+						list = append(list, item)
+						offset += utf8.RuneCountInString(item) + 1
+						continue
 					}
+					flushList()
+					b.Block(text, src)
+					offset += utf8.RuneCountInString(text) + 1
+				}
+				for _, x := range c.Tbl {
+					flushList()
+					paragraph++
+					text := docxTableText(tables[x])
+					b.Block(text, Source{Paragraph: paragraph, Offset: offset})
+					offset += utf8.RuneCountInString(text)
 				}
-				contentBuilder.WriteString("\t") // 单元格分隔符
 			}
-			contentBuilder.WriteString("\n") // 行结束换行
 		}
-		contentBuilder.WriteString("\n\n") // 表格之间留空行
+		flushList()
 	}
 
-	fullContent := contentBuilder.String()
+	return &ParsedDocument{
+		ContentType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		Sections:    b.Sections(),
+	}, nil
+}
 
-	// 使用 textSplitter 进行分块处理
-	chunks, err := p.textSplitter.Split(fullContent)
-	if err != nil {
-		return nil, fmt.Errorf("failed to split text: %w", err)
+// docxHeadingStyles 返回标题样式ID对应的层级，层级取自样式的大纲级别或样式名称
+func docxHeadingStyles(doc *document.Document) map[string]int {
+	levels := make(map[string]int)
+	for _, style := range doc.Styles.ParagraphStyles() {
+		x := style.X()
+		switch {
+		case x.PPr != nil && x.PPr.OutlineLvl != nil && x.PPr.OutlineLvl.ValAttr < 9:
+			levels[style.StyleID()] = int(x.PPr.OutlineLvl.ValAttr) + 1
+		case strings.EqualFold(style.Name(), "title"):
+			levels[style.StyleID()] = 1
+		default:
+			if m := headingStyleName.FindStringSubmatch(style.Name()); m != nil {
+				levels[style.StyleID()], _ = strconv.Atoi(m[2])
+			}
+		}
 	}
+	return levels
+}
 
-	// 构建返回结果
-	var documents []*Document
-	for _, chunk := range chunks {
-		documents = append(documents, &Document{
-			Content: chunk,
-			Metadata: Metadata{
-				Filename:    filepath.Base(filePath),
-				ContentType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
-				Size:        int64(len(chunk)),
-			},
-		})
+// docxHeadingLevel 段落的标题层级，非标题返回0
+func docxHeadingLevel(x *wml.CT_P, styles map[string]int) int {
+	if x.PPr == nil {
+		return 0
+	}
+	if x.PPr.OutlineLvl != nil && x.PPr.OutlineLvl.ValAttr < 9 {
+		return int(x.PPr.OutlineLvl.ValAttr) + 1
+	}
+	if x.PPr.PStyle == nil {
+		return 0
+	}
+	styleID := x.PPr.PStyle.ValAttr
+	if level, ok := styles[styleID]; ok {
+		return level
 	}
+	// 文档未定义样式时按样式ID判断
+	if m := headingStyleName.FindStringSubmatch(styleID); m != nil {
+		level, _ := strconv.Atoi(m[2])
+		return level
+	}
+	return 0
+}
 
-	return documents, nil
+// docxTableText 表格按行输出，单元格以制表符分隔
+func docxTableText(tbl document.Table) string {
+	var sb strings.Builder
+	for _, row := range tbl.Rows() {
+		var cells []string
+		for _, cell := range row.Cells() {
+			var text strings.Builder
+			for _, para := range cell.Paragraphs() {
+				for _, run := range para.Runs() {
+					text.WriteString(run.Text())
+				}
+			}
+			cells = append(cells, strings.TrimSpace(text.String()))
+		}
+		sb.WriteString(strings.Join(cells, "\t"))
+		sb.WriteString("\n") // 行结束换行
+	}
+	return sb.String()
 }
 
 // SupportedExtensions returns the file extensions this parser supports
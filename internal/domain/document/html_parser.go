@@ -0,0 +1,385 @@
+package document
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// HTMLParser 提取网页正文，去掉脚本、样式、导航、页眉页脚等，按 h1-h6 划分章节
+// title 和 description、keywords、author 等 meta 写入分块的 Metadata.Custom，表格按行输出
+type HTMLParser struct {
+	textSplitter *TextSplitter
+}
+
+func NewHTMLParser(chunkSize, chunkOverlap int) *HTMLParser {
+	return &HTMLParser{
+		textSplitter: NewTextSplitter(chunkSize, chunkOverlap),
+	}
+}
+
+func (p *HTMLParser) Parse(filePath string) ([]*Document, error) {
+	return ParseWith(context.Background(), p, p.textSplitter, filePath)
+}
+
+// ParseSections 按 <meta charset> 或内容检测的编码解码，有 <main> 或 <article> 时只提取其中的内容
+// 字符偏移以提取出的各内容块依次换行拼接计
+func (p *HTMLParser) ParseSections(filePath string) (*ParsedDocument, error) {
+	raw, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HTML file: %w", err)
+	}
+	reader, err := charset.NewReader(bytes.NewReader(raw), "text/html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect HTML encoding: %w", err)
+	}
+	root, err := html.Parse(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	w := &htmlWalker{}
+	content := findElement(root, atom.Main)
+	if content == nil {
+		content = findElement(root, atom.Article)
+	}
+	if content != nil {
+		w.article++
+	} else if content = findElement(root, atom.Body); content == nil {
+		content = root
+	}
+	w.walk(content)
+	w.flush()
+
+	return &ParsedDocument{
+		ContentType: "text/html",
+		Sections:    w.b.Sections(),
+		Metadata:    htmlMetadata(root),
+	}, nil
+}
+
+// htmlSkipped 不含正文的元素
+var htmlSkipped = map[atom.Atom]bool{
+	atom.Head: true, atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Nav: true, atom.Aside: true, atom.Form: true, atom.Iframe: true, atom.Svg: true,
+	atom.Button: true, atom.Select: true, atom.Textarea: true, atom.Dialog: true,
+}
+
+// htmlBlocks 块级元素，其前后断开段落
+var htmlBlocks = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true, atom.Main: true,
+	atom.Blockquote: true, atom.Ul: true, atom.Ol: true, atom.Dl: true, atom.Dt: true, atom.Dd: true,
+	atom.Figure: true, atom.Figcaption: true, atom.Address: true, atom.Hr: true, atom.Details: true,
+	atom.Summary: true, atom.Caption: true, atom.Header: true, atom.Footer: true,
+	atom.Table: true, atom.Tr: true, atom.Td: true, atom.Th: true,
+}
+
+// boilerplateRoles 导航、页眉页脚等区域的 role 属性
+var boilerplateRoles = map[string]bool{
+	"navigation": true, "banner": true, "contentinfo": true, "complementary": true, "search": true,
+}
+
+// boilerplateNames class 或 id 中表示导航、广告、分享等区域的单词
+var boilerplateNames = map[string]bool{
+	"nav": true, "navbar": true, "navigation": true, "menu": true, "sidebar": true, "footer": true,
+	"breadcrumb": true, "breadcrumbs": true, "cookie": true, "cookies": true, "advert": true,
+	"ad": true, "ads": true, "share": true, "social": true,
+}
+
+// htmlWalker 按文档顺序遍历元素，将行内文本累积为段落
+type htmlWalker struct {
+	b       sectionBuilder
+	text    strings.Builder
+	prefix  string // 下一个段落的前缀，如列表项的 "- "
+	offset  int
+	article int // 位于 <main> 或 <article> 内时大于0，此时 <header>、<footer> 属于正文
+}
+
+func (w *htmlWalker) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		// 源码中的换行只是空白，段落内只有 <br> 换行
+		w.text.WriteString(strings.NewReplacer("\r", " ", "\n", " ").Replace(n.Data))
+		return
+	case html.ElementNode, html.DocumentNode:
+	default:
+		return
+	}
+	if n.Type == html.ElementNode && w.skip(n) {
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		w.flush()
+		title := collapseSpace(textContent(n))
+		w.b.Heading(int(n.Data[1]-'0'), title)
+		w.advance(title)
+		return
+	case atom.Pre:
+		w.flush()
+		code := strings.TrimRight(textContent(n), " \t\n")
+		w.b.Code(code, Source{Offset: w.offset})
+		w.advance(code)
+		return
+	case atom.Table:
+		if w.table(n) {
+			return
+		}
+	case atom.Br:
+		w.text.WriteByte('\n')
+		return
+	case atom.Li:
+		w.flush()
+		w.prefix = "- "
+	case atom.Main, atom.Article:
+		w.article++
+		defer func() { w.article-- }()
+	}
+
+	block := htmlBlocks[n.DataAtom] || n.DataAtom == atom.Li
+	if block {
+		w.flush()
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.walk(c)
+	}
+	if block {
+		w.flush()
+	}
+}
+
+// skip 判断元素是否为脚本、导航、隐藏内容等非正文元素
+func (w *htmlWalker) skip(n *html.Node) bool {
+	if htmlSkipped[n.DataAtom] {
+		return true
+	}
+	if (n.DataAtom == atom.Header || n.DataAtom == atom.Footer) && w.article == 0 {
+		return true
+	}
+	for _, a := range n.Attr {
+		switch a.Key {
+		case "hidden":
+			return true
+		case "aria-hidden":
+			if a.Val == "true" {
+				return true
+			}
+		case "role":
+			if boilerplateRoles[strings.ToLower(a.Val)] {
+				return true
+			}
+		case "class", "id":
+			words := strings.FieldsFunc(strings.ToLower(a.Val), func(r rune) bool {
+				return r == ' ' || r == '-' || r == '_'
+			})
+			for _, word := range words {
+				if boilerplateNames[word] {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// flush 将累积的行内文本作为一个段落输出，<br> 之外的空白合并为一个空格
+func (w *htmlWalker) flush() {
+	var lines []string
+	for _, line := range strings.Split(w.text.String(), "\n") {
+		if line = collapseSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	w.text.Reset()
+	if len(lines) == 0 {
+		return
+	}
+	text := w.prefix + strings.Join(lines, "\n")
+	w.prefix = ""
+	w.b.Block(text, Source{Offset: w.offset})
+	w.advance(text)
+}
+
+func (w *htmlWalker) advance(text string) {
+	w.offset += utf8.RuneCountInString(text) + 1
+}
+
+// table 按行输出表格，首行全部为 <th> 时每行输出为 "表头: 值; 表头: 值"，否则单元格以制表符分隔
+// 没有多列的行时视为排版用的表格，返回 false 由调用方按普通内容处理
+func (w *htmlWalker) table(n *html.Node) bool {
+	var rows [][]string
+	header := false
+	multiColumn := false
+	for _, tr := range tableRows(n) {
+		var cells []string
+		allHeaders := true
+		for c := tr.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode || (c.DataAtom != atom.Td && c.DataAtom != atom.Th) {
+				continue
+			}
+			if c.DataAtom != atom.Th {
+				allHeaders = false
+			}
+			// 跨列的单元格在其覆盖的每一列重复
+			text := collapseSpace(textContent(c))
+			for i := 0; i < colspan(c); i++ {
+				cells = append(cells, text)
+			}
+		}
+		if isEmptyRow(cells) {
+			continue
+		}
+		if len(rows) == 0 {
+			header = allHeaders
+		}
+		multiColumn = multiColumn || len(trimCells(cells)) > 1
+		rows = append(rows, cells)
+	}
+	if !multiColumn {
+		return false
+	}
+
+	w.flush()
+	if caption := findElement(n, atom.Caption); caption != nil {
+		w.text.WriteString(textContent(caption))
+		w.flush()
+	}
+	var names []string
+	if header && len(rows) > 1 {
+		names, rows = headerNames(rows[0]), rows[1:]
+	}
+	for _, row := range rows {
+		text := strings.Join(trimCells(row), "\t")
+		if names != nil {
+			text = recordRow(row, names)
+		}
+		w.b.Row(text, Source{Offset: w.offset})
+		w.advance(text)
+	}
+	return true
+}
+
+// tableRows 返回表格自身的行，不包括嵌套表格中的行
+func tableRows(table *html.Node) []*html.Node {
+	var rows []*html.Node
+	for c := table.FirstChild; c != nil; c = c.NextSibling {
+		switch c.DataAtom {
+		case atom.Tr:
+			rows = append(rows, c)
+		case atom.Thead, atom.Tbody, atom.Tfoot:
+			for r := c.FirstChild; r != nil; r = r.NextSibling {
+				if r.DataAtom == atom.Tr {
+					rows = append(rows, r)
+				}
+			}
+		}
+	}
+	return rows
+}
+
+func colspan(cell *html.Node) int {
+	var n int
+	if _, err := fmt.Sscanf(attr(cell, "colspan"), "%d", &n); err != nil || n < 1 {
+		return 1
+	}
+	return min(n, 100)
+}
+
+// htmlMetadata 提取 <title> 和 description、keywords、author 等 meta，缺少时以 Open Graph 属性代替
+func htmlMetadata(root *html.Node) map[string]interface{} {
+	meta := make(map[string]interface{})
+	if title := findElement(root, atom.Title); title != nil {
+		if text := collapseSpace(textContent(title)); text != "" {
+			meta["title"] = text
+		}
+	}
+
+	var visit func(n *html.Node)
+	visit = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Meta {
+			content := collapseSpace(attr(n, "content"))
+			key := strings.ToLower(attr(n, "name"))
+			if key == "" {
+				key = strings.ToLower(attr(n, "property"))
+			}
+			switch key {
+			case "description", "keywords", "author":
+				if content != "" {
+					meta[key] = content
+				}
+			case "og:title", "og:description":
+				if name := strings.TrimPrefix(key, "og:"); meta[name] == nil && content != "" {
+					meta[name] = content
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			visit(c)
+		}
+	}
+	visit(root)
+
+	if len(meta) == 0 {
+		return nil
+	}
+	return meta
+}
+
+// findElement 按文档顺序查找第一个指定类型的元素
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// textContent 拼接元素内的全部文本，<br> 转换为换行，跳过脚本和样式
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	var visit func(n *html.Node)
+	visit = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			b.WriteString(n.Data)
+		case n.DataAtom == atom.Br:
+			b.WriteByte('\n')
+		case n.DataAtom == atom.Script || n.DataAtom == atom.Style:
+		default:
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				visit(c)
+			}
+		}
+	}
+	visit(n)
+	return b.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func (p *HTMLParser) SupportedExtensions() []string {
+	return []string{".html", ".htm"}
+}
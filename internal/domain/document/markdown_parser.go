@@ -0,0 +1,87 @@
+package document
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v2"
+)
+
+// MarkdownParser 按标题划分章节，围栏代码块尽量整块放入一个分块，YAML front matter 写入分块的 Metadata.Custom
+type MarkdownParser struct {
+	textSplitter *TextSplitter
+}
+
+func NewMarkdownParser(chunkSize, chunkOverlap int) *MarkdownParser {
+	return &MarkdownParser{
+		textSplitter: NewTextSplitter(chunkSize, chunkOverlap),
+	}
+}
+
+func (p *MarkdownParser) Parse(filePath string) ([]*Document, error) {
+	return ParseWith(context.Background(), p, p.textSplitter, filePath)
+}
+
+// ParseSections 读取文本并转换为 UTF-8，去掉 front matter 后按 # 标题和下划线标题划分章节
+func (p *MarkdownParser) ParseSections(filePath string) (*ParsedDocument, error) {
+	content, err := readText(filePath)
+	if err != nil {
+		return nil, err
+	}
+	meta, body := splitFrontMatter(content)
+	offset := utf8.RuneCountInString(content[:len(content)-len(body)])
+	return &ParsedDocument{
+		ContentType: "text/markdown",
+		Sections:    markdownSections(body, offset),
+		Metadata:    meta,
+	}, nil
+}
+
+// splitFrontMatter 拆分文档开头以 --- 包围的 YAML front matter，返回其键值和之后的正文
+// 标题路径的键保留给分块使用；没有 front matter 或无法解析为键值时原样返回全文
+func splitFrontMatter(content string) (map[string]interface{}, string) {
+	text := strings.TrimPrefix(content, "\uFEFF")
+	if !strings.HasPrefix(text, "---\n") {
+		return nil, content
+	}
+	rest := text[len("---\n"):]
+	pos := 0
+	for _, line := range strings.SplitAfter(rest, "\n") {
+		if marker := strings.TrimRight(line, " \t\n"); marker == "---" || marker == "..." {
+			var meta map[string]interface{}
+			if err := yaml.Unmarshal([]byte(rest[:pos]), &meta); err != nil || len(meta) == 0 {
+				return nil, content
+			}
+			delete(meta, HeadingsKey)
+			for k, v := range meta {
+				meta[k] = stringKeys(v)
+			}
+			return meta, rest[pos+len(line):]
+		}
+		pos += len(line)
+	}
+	return nil, content
+}
+
+// stringKeys yaml.v2 将嵌套的映射解码为 map[interface{}]interface{}，转换为可编码为 JSON 的 map[string]interface{}
+func stringKeys(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[fmt.Sprint(k)] = stringKeys(val)
+		}
+		return m
+	case []interface{}:
+		for i := range v {
+			v[i] = stringKeys(v[i])
+		}
+	}
+	return v
+}
+
+func (p *MarkdownParser) SupportedExtensions() []string {
+	return []string{".md", ".markdown"}
+}
@@ -0,0 +1,45 @@
+package document
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSplitFrontMatter(t *testing.T) {
+	content := "---\ntitle: 部署指南\ntags: [ops, milvus]\nauthor:\n  name: alice\n  teams:\n    - {id: 1, role: owner}\n" +
+		HeadingsKey + ": ignored\n---\n# 正文\n"
+
+	meta, body := splitFrontMatter(content)
+	if body != "# 正文\n" {
+		t.Errorf("body = %q", body)
+	}
+	want := map[string]interface{}{
+		"title": "部署指南",
+		"tags":  []interface{}{"ops", "milvus"},
+		"author": map[string]interface{}{
+			"name":  "alice",
+			"teams": []interface{}{map[string]interface{}{"id": 1, "role": "owner"}},
+		},
+	}
+	if !reflect.DeepEqual(meta, want) {
+		t.Errorf("meta = %#v, want %#v", meta, want)
+	}
+	// 嵌套的键值写入分块元数据，须能编码为 JSON
+	if _, err := json.Marshal(Metadata{Custom: meta}); err != nil {
+		t.Errorf("json.Marshal: %v", err)
+	}
+}
+
+func TestSplitFrontMatterKeepsInvalidHeader(t *testing.T) {
+	for _, content := range []string{
+		"# 没有 front matter\n",
+		"---\njust a sentence\n---\nbody\n",
+		"---\ntitle: [unclosed\n---\nbody\n",
+		"---\ntitle: never closed\n",
+	} {
+		if meta, body := splitFrontMatter(content); meta != nil || body != content {
+			t.Errorf("splitFrontMatter(%q) = %v, %q, want the content unchanged", content, meta, body)
+		}
+	}
+}
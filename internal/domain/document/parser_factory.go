@@ -1,39 +1,226 @@
 package document
 
 import (
+	"errors"
 	"fmt"
-	"strings"
+	"io"
+
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/embedding"
+)
+
+var (
+	// ErrLimitExceeded 文档页数、工作表数或分块数超出限制
+	ErrLimitExceeded = errors.New("document exceeds processing limits")
+	// ErrUnsupportedSplitter 未知或未启用的分块器
+	ErrUnsupportedSplitter = errors.New("unsupported splitter")
+)
+
+// 知识库可选的分块器
+const (
+	SplitterFixed    = "fixed"    // 按大小递归切分(默认)
+	SplitterSemantic = "semantic" // 按相邻句子的语义距离切分
 )
 
+// ValidateSplitter 校验分块器名称，空字符串表示默认分块器
+func ValidateSplitter(name string) error {
+	switch name {
+	case "", SplitterFixed, SplitterSemantic:
+		return nil
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedSplitter, name)
+	}
+}
+
+// Limits 单个文档的处理上限，0 表示不限制
+type Limits struct {
+	MaxPages  int // PDF 页数或演示文稿幻灯片数
+	MaxSheets int // 表格工作表数
+	MaxChunks int // 分块数
+}
+
+// CheckChunks 校验分块数是否超出限制
+func (l Limits) CheckChunks(n int) error {
+	if l.MaxChunks > 0 && n > l.MaxChunks {
+		return fmt.Errorf("%w: %d chunks (max %d)", ErrLimitExceeded, n, l.MaxChunks)
+	}
+	return nil
+}
+
 type ParserFactory struct {
-	parsers map[string]DocumentParser // 扩展名 -> 解析器
+	parsers         map[string]DocumentParser // MIME 类型 -> 解析器
+	limits          Limits
+	tokenizer       Tokenizer
+	splitMode       SplitMode
+	cjkChunkSize    int
+	cjkChunkOverlap int
+	tableFormat     TableFormat
+	splitter        *TextSplitter
+	semantic        *SemanticSplitter
+}
+
+// FactoryOption 解析器工厂配置项
+type FactoryOption func(*ParserFactory)
+
+// WithLimits 设置页数、工作表数和分块数上限
+func WithLimits(limits Limits) FactoryOption {
+	return func(f *ParserFactory) {
+		f.limits = limits
+	}
+}
+
+// WithTokenizer 按嵌入模型的 token 数分块，chunkSize 和 chunkOverlap 以 token 计
+func WithTokenizer(tokenizer Tokenizer) FactoryOption {
+	return func(f *ParserFactory) {
+		f.tokenizer = tokenizer
+	}
+}
+
+// WithSplitMode 设置分块方式，默认按文本语言自动选择
+func WithSplitMode(mode SplitMode) FactoryOption {
+	return func(f *ParserFactory) {
+		f.splitMode = mode
+	}
+}
+
+// WithCJKChunkSize 设置中日文分块的大小和重叠字符数
+func WithCJKChunkSize(size, overlap int) FactoryOption {
+	return func(f *ParserFactory) {
+		f.cjkChunkSize = size
+		f.cjkChunkOverlap = overlap
+	}
+}
+
+// WithTableFormat 设置表格行的输出格式，默认 TableRecords
+func WithTableFormat(format TableFormat) FactoryOption {
+	return func(f *ParserFactory) {
+		f.tableFormat = format
+	}
 }
 
-func NewParserFactory(chunkSize, chunkOverlap int) *ParserFactory {
-	return &ParserFactory{
-		parsers: map[string]DocumentParser{
-			".pdf":  NewPDFParser(chunkSize, chunkOverlap),
-			".txt":  NewTextParser(chunkSize, chunkOverlap),
-			".docx": NewDOCXParser(chunkSize, chunkOverlap),
-			".doc":  NewDOCXParser(chunkSize, chunkOverlap),
-			".xlsx": NewXLSParser(chunkSize, chunkOverlap),
-			".xls":  NewXLSParser(chunkSize, chunkOverlap),
-		},
+// WithSemanticSplitter 启用语义分块，percentile、minSize、maxSize 为 0 时使用 SemanticSplitter 的默认值
+func WithSemanticSplitter(embedder embedding.Embedder, percentile float64, minSize, maxSize int) FactoryOption {
+	return func(f *ParserFactory) {
+		f.semantic = &SemanticSplitter{
+			Embedder:             embedder,
+			BreakpointPercentile: percentile,
+			MinChunkSize:         minSize,
+			MaxChunkSize:         maxSize,
+		}
 	}
 }
 
+func NewParserFactory(chunkSize, chunkOverlap int, opts ...FactoryOption) *ParserFactory {
+	f := &ParserFactory{}
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	// 所有解析器共用同一个分块器
+	splitter := NewTextSplitter(chunkSize, chunkOverlap)
+	splitter.Tokenizer = f.tokenizer
+	if f.splitMode != "" {
+		splitter.Mode = f.splitMode
+	}
+	splitter.CJKChunkSize = f.cjkChunkSize
+	splitter.CJKChunkOverlap = f.cjkChunkOverlap
+	f.splitter = splitter
+	if f.semantic != nil {
+		f.semantic.Base = splitter
+	}
+	xlsParser := &XLSParser{textSplitter: splitter, MaxSheets: f.limits.MaxSheets, Format: f.tableFormat}
+
+	f.parsers = map[string]DocumentParser{
+		TypePDF:      &PDFParser{textSplitter: splitter, MaxPages: f.limits.MaxPages},
+		TypeText:     &TextParser{textSplitter: splitter},
+		TypeMarkdown: &MarkdownParser{textSplitter: splitter},
+		TypeHTML:     &HTMLParser{textSplitter: splitter},
+		TypeDOCX:     &DOCXParser{textSplitter: splitter},
+		TypePPTX:     &PPTXParser{textSplitter: splitter, MaxSlides: f.limits.MaxPages},
+		TypeDOC:      &DOCParser{textSplitter: splitter},
+		TypeXLSX:     xlsParser,
+		TypeXLS:      xlsParser,
+	}
+	return f
+}
+
+// Limits 返回文档处理上限
+func (f *ParserFactory) Limits() Limits {
+	return f.limits
+}
+
+// Splitter 返回知识库配置的分块器，name 为空时返回默认的 TextSplitter
+func (f *ParserFactory) Splitter(name string) (DocumentSplitter, error) {
+	switch name {
+	case "", SplitterFixed:
+		return f.splitter, nil
+	case SplitterSemantic:
+		if f.semantic != nil {
+			return f.semantic, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedSplitter, name)
+}
+
+// GetParser 按扩展名查找解析器
 func (f *ParserFactory) GetParser(fileExt string) (DocumentParser, error) {
-	parser, exists := f.parsers[strings.ToLower(fileExt)]
+	parser, exists := f.parsers[TypeForExtension(fileExt)]
 	if !exists {
 		return nil, fmt.Errorf("no parser found for extension: %s", fileExt)
 	}
 	return parser, nil
 }
 
+// ParserForType 按 MIME 类型查找解析器
+func (f *ParserFactory) ParserForType(contentType string) (DocumentParser, error) {
+	parser, exists := f.parsers[contentType]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedMediaType, contentType)
+	}
+	return parser, nil
+}
+
+// ResolveType 按文件内容识别类型，与扩展名和客户端声明的 Content-Type 交叉校验后返回可解析的 MIME 类型
+// 内容无法识别、与声明的类型不符或没有对应的解析器时返回 ErrUnsupportedMediaType
+func (f *ParserFactory) ResolveType(r io.ReaderAt, size int64, fileExt, declared string) (string, error) {
+	detected, err := DetectContentType(r, size)
+	if err != nil {
+		return "", err
+	}
+	contentType, err := ResolveContentType(detected, fileExt, declared)
+	if err != nil {
+		return "", err
+	}
+	if _, exists := f.parsers[contentType]; !exists {
+		if contentType == TypeUnknown {
+			return "", fmt.Errorf("%w: unrecognized file content", ErrUnsupportedMediaType)
+		}
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedMediaType, contentType)
+	}
+	return contentType, nil
+}
+
+// limitChecker 能在解析前以较小的代价校验页数等上限的解析器
+type limitChecker interface {
+	CheckLimits(filePath string) error
+}
+
+// CheckLimits 在解析前校验页数、幻灯片数和 .xlsx 工作表数，超出时返回 ErrLimitExceeded
+// 分块数只有切分后才能确定，仍由处理时的 Limits.CheckChunks 校验
+func (f *ParserFactory) CheckLimits(contentType, filePath string) error {
+	checker, ok := f.parsers[contentType].(limitChecker)
+	if !ok {
+		return nil
+	}
+	return checker.CheckLimits(filePath)
+}
+
+// SupportedExtensions 返回有对应解析器的扩展名
 func (f *ParserFactory) SupportedExtensions() []string {
-	exts := make([]string, 0, len(f.parsers))
-	for ext := range f.parsers {
-		exts = append(exts, ext)
+	exts := make([]string, 0, len(extensionTypes))
+	for ext, contentType := range extensionTypes {
+		if _, exists := f.parsers[contentType]; exists {
+			exts = append(exts, ext)
+		}
 	}
 	return exts
 }
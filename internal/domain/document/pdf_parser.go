@@ -1,10 +1,12 @@
 package document
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"path/filepath"
+	"sort"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/unidoc/unipdf/v3/extractor"
 	"github.com/unidoc/unipdf/v3/model"
@@ -12,6 +14,7 @@ import (
 
 type PDFParser struct {
 	textSplitter *TextSplitter
+	MaxPages     int // 最大页数，0表示不限制
 }
 
 func NewPDFParser(chunkSize, chunkOverlap int) *PDFParser {
@@ -21,6 +24,36 @@ func NewPDFParser(chunkSize, chunkOverlap int) *PDFParser {
 }
 
 func (p *PDFParser) Parse(filePath string) ([]*Document, error) {
+	return ParseWith(context.Background(), p, p.textSplitter, filePath)
+}
+
+// CheckLimits 只读取页数，在解析前校验页数上限
+func (p *PDFParser) CheckLimits(filePath string) error {
+	if p.MaxPages <= 0 {
+		return nil
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open PDF file: %w", err)
+	}
+	defer file.Close()
+
+	pdfReader, err := model.NewPdfReader(file)
+	if err != nil {
+		return fmt.Errorf("failed to create PDF reader: %w", err)
+	}
+	numPages, err := pdfReader.GetNumPages()
+	if err != nil {
+		return fmt.Errorf("failed to get page count: %w", err)
+	}
+	if numPages > p.MaxPages {
+		return fmt.Errorf("%w: %d pages (max %d)", ErrLimitExceeded, numPages, p.MaxPages)
+	}
+	return nil
+}
+
+// ParseSections 逐页提取文本，按书签划分章节
+func (p *PDFParser) ParseSections(filePath string) (*ParsedDocument, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open PDF file: %w", err)
@@ -36,8 +69,15 @@ func (p *PDFParser) Parse(filePath string) ([]*Document, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get page count: %w", err)
 	}
+	if p.MaxPages > 0 && numPages > p.MaxPages {
+		return nil, fmt.Errorf("%w: %d pages (max %d)", ErrLimitExceeded, numPages, p.MaxPages)
+	}
 
-	var contentBuilder strings.Builder
+	// 书签(大纲)划分章节，书签标题出现在页面文本中时从该位置切开，否则从页首开始新章节
+	headings := pdfOutlineHeadings(pdfReader)
+	// 字符偏移以各页提取文本依次拼接计
+	var b sectionBuilder
+	offset := 0
 	for i := 0; i < numPages; i++ {
 		pageNum := i + 1
 		page, err := pdfReader.GetPage(pageNum)
@@ -55,29 +95,59 @@ func (p *PDFParser) Parse(filePath string) ([]*Document, error) {
 			return nil, fmt.Errorf("failed to extract text from page %d: %w", pageNum, err)
 		}
 
-		contentBuilder.WriteString(text)
-		contentBuilder.WriteString("\n\n") // 保留页面分隔
+		pageSrc := Source{Page: pageNum, Offset: offset}
+		pos := 0
+		for len(headings) > 0 && headings[0].page <= i {
+			h := headings[0]
+			headings = headings[1:]
+			if idx := strings.Index(text[pos:], h.title); idx >= 0 {
+				addPDFBlocks(&b, text[pos:pos+idx], pageSrc.advance(text[:pos]))
+				pos += idx + len(h.title)
+			}
+			b.Heading(h.level, h.title)
+		}
+		addPDFBlocks(&b, text[pos:], pageSrc.advance(text[:pos]))
+		offset += utf8.RuneCountInString(text)
 	}
 
-	fullContent := contentBuilder.String()
-	chunks, err := p.textSplitter.Split(fullContent)
-	if err != nil {
-		return nil, fmt.Errorf("failed to split PDF text: %w", err)
+	return &ParsedDocument{ContentType: "application/pdf", Sections: b.Sections()}, nil
+}
+
+// pdfHeading 书签条目，page 从0开始
+type pdfHeading struct {
+	title string
+	level int
+	page  int
+}
+
+// pdfOutlineHeadings 按页码顺序返回展开后的书签，没有书签或读取失败时返回空
+func pdfOutlineHeadings(reader *model.PdfReader) []pdfHeading {
+	outline, err := reader.GetOutlines()
+	if err != nil || outline == nil {
+		return nil
 	}
 
-	var documents []*Document
-	for _, chunk := range chunks {
-		documents = append(documents, &Document{
-			Content: chunk,
-			Metadata: Metadata{
-				Filename:    filepath.Base(filePath),
-				ContentType: "application/pdf",
-				Size:        int64(len(chunk)),
-			},
-		})
+	var headings []pdfHeading
+	var walk func(items []*model.OutlineItem, level int)
+	walk = func(items []*model.OutlineItem, level int) {
+		for _, item := range items {
+			if title := strings.TrimSpace(item.Title); title != "" && item.Dest.Page >= 0 {
+				headings = append(headings, pdfHeading{title: title, level: level, page: int(item.Dest.Page)})
+			}
+			walk(item.Entries, level+1)
+		}
 	}
+	walk(outline.Entries, 1)
+	sort.SliceStable(headings, func(i, j int) bool { return headings[i].page < headings[j].page })
+	return headings
+}
 
-	return documents, nil
+// addPDFBlocks 按空行将页面文本分段，src 为 text 首字符的位置
+func addPDFBlocks(b *sectionBuilder, text string, src Source) {
+	for _, para := range strings.Split(text, "\n\n") {
+		b.Block(para, src)
+		src = src.advance(para + "\n\n")
+	}
 }
 
 func (p *PDFParser) SupportedExtensions() []string {
@@ -0,0 +1,434 @@
+package document
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// PPTXParser 按幻灯片顺序提取标题、正文、表格和演讲者备注，每张幻灯片作为一个章节，分块不跨越幻灯片
+type PPTXParser struct {
+	textSplitter *TextSplitter
+	MaxSlides    int // 最大幻灯片数，0表示不限制
+}
+
+func NewPPTXParser(chunkSize, chunkOverlap int) *PPTXParser {
+	return &PPTXParser{
+		textSplitter: NewTextSplitter(chunkSize, chunkOverlap),
+	}
+}
+
+func (p *PPTXParser) Parse(filePath string) ([]*Document, error) {
+	return ParseWith(context.Background(), p, p.textSplitter, filePath)
+}
+
+// xmlNode 不区分命名空间的通用 XML 节点，保留子元素的顺序
+type xmlNode struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Nodes   []xmlNode  `xml:",any"`
+	Text    string     `xml:",chardata"`
+}
+
+// attr 返回不带命名空间前缀的属性值
+func (n *xmlNode) attr(name string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name && a.Name.Space == "" {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// relID 返回 r:id 属性，即关系的 Id；Strict 格式的关系命名空间不同，因此只要求带命名空间
+func (n *xmlNode) relID() string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == "id" && a.Name.Space != "" {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// child 返回第一个指定名称的子元素
+func (n *xmlNode) child(name string) *xmlNode {
+	for i := range n.Nodes {
+		if n.Nodes[i].XMLName.Local == name {
+			return &n.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// path 依次查找各级子元素
+func (n *xmlNode) path(names ...string) *xmlNode {
+	for _, name := range names {
+		if n = n.child(name); n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// pptxSkippedPlaceholders 不含正文的占位符：幻灯片编号、日期、页脚、页眉和备注页中的幻灯片缩略图
+var pptxSkippedPlaceholders = map[string]bool{
+	"sldNum": true, "dt": true, "ftr": true, "hdr": true, "sldImg": true,
+}
+
+// CheckLimits 只读取幻灯片列表，在解析前校验幻灯片数上限
+func (p *PPTXParser) CheckLimits(filePath string) error {
+	if p.MaxSlides <= 0 {
+		return nil
+	}
+	zr, err := zip.OpenReader(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open PPTX file: %w", err)
+	}
+	defer zr.Close()
+	pkg := pptxPackage{files: make(map[string]*zip.File, len(zr.File))}
+	for _, f := range zr.File {
+		pkg.files[f.Name] = f
+	}
+
+	slides, err := pkg.slidePaths()
+	if err != nil {
+		return err
+	}
+	if len(slides) > p.MaxSlides {
+		return fmt.Errorf("%w: %d slides (max %d)", ErrLimitExceeded, len(slides), p.MaxSlides)
+	}
+	return nil
+}
+
+// ParseSections 以标题占位符的文本作为章节标题，没有标题时以 "Slide N" 代替；隐藏的幻灯片不输出
+// 正文占位符的段落按层级输出为列表项，备注以 "Notes:" 开头输出在幻灯片内容之后
+// 字符偏移以各幻灯片的标题和内容块依次换行拼接计
+func (p *PPTXParser) ParseSections(filePath string) (*ParsedDocument, error) {
+	zr, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PPTX file: %w", err)
+	}
+	defer zr.Close()
+	pkg := pptxPackage{files: make(map[string]*zip.File, len(zr.File))}
+	for _, f := range zr.File {
+		pkg.files[f.Name] = f
+	}
+
+	slides, err := pkg.slidePaths()
+	if err != nil {
+		return nil, err
+	}
+	if p.MaxSlides > 0 && len(slides) > p.MaxSlides {
+		return nil, fmt.Errorf("%w: %d slides (max %d)", ErrLimitExceeded, len(slides), p.MaxSlides)
+	}
+
+	var b sectionBuilder
+	offset := 0
+	for i, slidePath := range slides {
+		number := i + 1
+		slide, err := pkg.readXML(slidePath)
+		if err != nil {
+			return nil, err
+		}
+		if slide.attr("show") == "0" {
+			continue
+		}
+		content := pptxSlideContent(slide, false)
+
+		title := content.title
+		if title == "" {
+			title = fmt.Sprintf("Slide %d", number)
+		}
+		b.Heading(1, title)
+		offset += utf8.RuneCountInString(title) + 1
+		emit := func(block pptxBlock) {
+			src := Source{Slide: number, Offset: offset}
+			if block.row {
+				b.Row(block.text, src)
+			} else {
+				b.Block(block.text, src)
+			}
+			offset += utf8.RuneCountInString(block.text) + 1
+		}
+		for _, block := range content.blocks {
+			emit(block)
+		}
+
+		notesPath, err := pkg.relationship(slidePath, "/notesSlide")
+		if err != nil {
+			return nil, err
+		}
+		if notesPath == "" {
+			continue
+		}
+		notes, err := pkg.readXML(notesPath)
+		if err != nil {
+			return nil, err
+		}
+		var lines []string
+		for _, block := range pptxSlideContent(notes, true).blocks {
+			lines = append(lines, block.text)
+		}
+		if text := strings.TrimSpace(strings.Join(lines, "\n")); text != "" {
+			emit(pptxBlock{text: "Notes: " + text})
+		}
+	}
+
+	return &ParsedDocument{
+		ContentType: "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+		Sections:    b.Sections(),
+	}, nil
+}
+
+// pptxMaxPartSize 单个 XML 部件解压后的最大字节数，防止解压炸弹
+const pptxMaxPartSize = 16 << 20
+
+// pptxPackage 演示文稿的 zip 包，按部件路径访问
+type pptxPackage struct {
+	files map[string]*zip.File
+}
+
+func (pkg pptxPackage) readXML(name string) (*xmlNode, error) {
+	f, ok := pkg.files[name]
+	if !ok {
+		return nil, fmt.Errorf("failed to read PPTX part %s: not found", name)
+	}
+	if f.UncompressedSize64 > pptxMaxPartSize {
+		return nil, fmt.Errorf("%w: PPTX part %s is %d bytes uncompressed (max %d)", ErrLimitExceeded, name, f.UncompressedSize64, pptxMaxPartSize)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PPTX part %s: %w", name, err)
+	}
+	defer rc.Close()
+	var node xmlNode
+	// 文件头中的大小可能被伪造，读取时仍按上限截断
+	if err := xml.NewDecoder(io.LimitReader(rc, pptxMaxPartSize)).Decode(&node); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to parse PPTX part %s: %w", name, err)
+	}
+	return &node, nil
+}
+
+// relationships 读取部件的关系，返回 Id -> 目标部件路径；typeSuffix 非空时只返回该类型的关系
+func (pkg pptxPackage) relationships(part, typeSuffix string) (map[string]string, error) {
+	relsPath := path.Join(path.Dir(part), "_rels", path.Base(part)+".rels")
+	if _, ok := pkg.files[relsPath]; !ok {
+		return nil, nil
+	}
+	rels, err := pkg.readXML(relsPath)
+	if err != nil {
+		return nil, err
+	}
+	targets := make(map[string]string)
+	for i := range rels.Nodes {
+		rel := &rels.Nodes[i]
+		if rel.attr("TargetMode") == "External" || !strings.HasSuffix(rel.attr("Type"), typeSuffix) {
+			continue
+		}
+		target := rel.attr("Target")
+		if strings.HasPrefix(target, "/") {
+			target = strings.TrimPrefix(target, "/")
+		} else {
+			target = path.Join(path.Dir(part), target)
+		}
+		targets[rel.attr("Id")] = target
+	}
+	return targets, nil
+}
+
+// relationship 返回部件指定类型的第一个关系目标，没有时返回空字符串
+func (pkg pptxPackage) relationship(part, typeSuffix string) (string, error) {
+	targets, err := pkg.relationships(part, typeSuffix)
+	if err != nil {
+		return "", err
+	}
+	for _, target := range targets {
+		return target, nil
+	}
+	return "", nil
+}
+
+// slidePaths 按 presentation.xml 中的顺序返回幻灯片部件路径
+func (pkg pptxPackage) slidePaths() ([]string, error) {
+	const presentation = "ppt/presentation.xml"
+	root, err := pkg.readXML(presentation)
+	if err != nil {
+		return nil, err
+	}
+	targets, err := pkg.relationships(presentation, "/slide")
+	if err != nil {
+		return nil, err
+	}
+	var slides []string
+	if list := root.child("sldIdLst"); list != nil {
+		for i := range list.Nodes {
+			if target, ok := targets[list.Nodes[i].relID()]; ok {
+				slides = append(slides, target)
+			}
+		}
+	}
+	return slides, nil
+}
+
+// pptxBlock 幻灯片中的一个内容块，row 为表格行
+type pptxBlock struct {
+	text string
+	row  bool
+}
+
+// pptxContent 幻灯片或备注页的标题和按形状顺序排列的内容块
+type pptxContent struct {
+	title  string
+	blocks []pptxBlock
+	notes  bool // 备注页的正文不加项目符号
+}
+
+func pptxSlideContent(slide *xmlNode, notes bool) pptxContent {
+	content := pptxContent{notes: notes}
+	if tree := slide.path("cSld", "spTree"); tree != nil {
+		content.addShapes(tree)
+	}
+	return content
+}
+
+// addShapes 按顺序读取形状树中的文本框、组合形状和表格
+func (c *pptxContent) addShapes(tree *xmlNode) {
+	for i := range tree.Nodes {
+		shape := &tree.Nodes[i]
+		switch shape.XMLName.Local {
+		case "sp":
+			c.addShape(shape)
+		case "grpSp":
+			c.addShapes(shape)
+		case "graphicFrame":
+			if table := shape.path("graphic", "graphicData", "tbl"); table != nil {
+				c.addTable(table)
+			}
+		}
+	}
+}
+
+func (c *pptxContent) addShape(shape *xmlNode) {
+	body := shape.child("txBody")
+	if body == nil {
+		return
+	}
+	phType, placeholder := "", false
+	if ph := shape.path("nvSpPr", "nvPr", "ph"); ph != nil {
+		phType, placeholder = ph.attr("type"), true
+	}
+	if pptxSkippedPlaceholders[phType] {
+		return
+	}
+
+	// 第一个标题占位符作为幻灯片标题
+	if phType == "title" || phType == "ctrTitle" {
+		if title := collapseSpace(strings.Join(pptxParagraphs(body, false), " ")); title != "" && c.title == "" {
+			c.title = title
+			return
+		}
+	}
+	// 正文占位符(未指定类型的占位符默认为正文)的段落带项目符号
+	bullets := placeholder && !c.notes && (phType == "" || phType == "body" || phType == "obj")
+	if text := strings.Join(pptxParagraphs(body, bullets), "\n"); text != "" {
+		c.blocks = append(c.blocks, pptxBlock{text: text})
+	}
+}
+
+// pptxParagraphs 返回文本框中的非空段落，bullets 为 true 时按层级缩进并加上 "- "，除非段落设置了无项目符号
+func pptxParagraphs(body *xmlNode, bullets bool) []string {
+	var paragraphs []string
+	for i := range body.Nodes {
+		p := &body.Nodes[i]
+		if p.XMLName.Local != "p" {
+			continue
+		}
+		text := strings.TrimSpace(pptxText(p))
+		if text == "" {
+			continue
+		}
+		if pPr := p.child("pPr"); bullets && (pPr == nil || pPr.child("buNone") == nil) {
+			level := 0
+			if pPr != nil {
+				fmt.Sscanf(pPr.attr("lvl"), "%d", &level)
+			}
+			text = strings.Repeat("  ", level) + "- " + text
+		}
+		paragraphs = append(paragraphs, text)
+	}
+	return paragraphs
+}
+
+// pptxText 拼接段落中文本段和字段的文本，<a:br> 转换为换行
+func pptxText(p *xmlNode) string {
+	var b strings.Builder
+	for i := range p.Nodes {
+		n := &p.Nodes[i]
+		switch n.XMLName.Local {
+		case "r", "fld":
+			if t := n.child("t"); t != nil {
+				b.WriteString(t.Text)
+			}
+		case "br":
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// addTable 按行输出表格，设置了标题行时每行输出为 "表头: 值; 表头: 值"，否则单元格以制表符分隔
+// 合并单元格中被合并的单元格取左侧或上方单元格的值
+func (c *pptxContent) addTable(table *xmlNode) {
+	var rows [][]string
+	for i := range table.Nodes {
+		tr := &table.Nodes[i]
+		if tr.XMLName.Local != "tr" {
+			continue
+		}
+		var cells []string
+		for j := range tr.Nodes {
+			tc := &tr.Nodes[j]
+			if tc.XMLName.Local != "tc" {
+				continue
+			}
+			col := len(cells)
+			var text string
+			switch {
+			case tc.attr("hMerge") == "1" && col > 0:
+				text = cells[col-1]
+			case tc.attr("vMerge") == "1" && len(rows) > 0 && col < len(rows[len(rows)-1]):
+				text = rows[len(rows)-1][col]
+			default:
+				if body := tc.child("txBody"); body != nil {
+					text = strings.Join(pptxParagraphs(body, false), " ")
+				}
+			}
+			cells = append(cells, strings.Join(strings.Fields(text), " "))
+		}
+		rows = append(rows, cells)
+	}
+
+	var names []string
+	if tblPr := table.child("tblPr"); tblPr != nil && tblPr.attr("firstRow") == "1" && len(rows) > 1 {
+		names, rows = headerNames(rows[0]), rows[1:]
+	}
+	for _, row := range rows {
+		if isEmptyRow(row) {
+			continue
+		}
+		text := strings.Join(trimCells(row), "\t")
+		if names != nil {
+			text = recordRow(row, names)
+		}
+		c.blocks = append(c.blocks, pptxBlock{text: text, row: true})
+	}
+}
+
+func (p *PPTXParser) SupportedExtensions() []string {
+	return []string{".pptx"}
+}
@@ -0,0 +1,54 @@
+package document
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// zipPackage 用内存中的 zip 构造演示文稿包
+func zipPackage(t *testing.T, build func(w *zip.Writer)) pptxPackage {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	build(w)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := pptxPackage{files: make(map[string]*zip.File, len(zr.File))}
+	for _, f := range zr.File {
+		pkg.files[f.Name] = f
+	}
+	return pkg
+}
+
+func TestPPTXReadXMLLimitsPartSize(t *testing.T) {
+	big := "<a>" + strings.Repeat(" ", pptxMaxPartSize) + "</a>"
+	pkg := zipPackage(t, func(w *zip.Writer) {
+		fw, _ := w.Create("small.xml")
+		fw.Write([]byte("<a><b>text</b></a>"))
+		fw, _ = w.Create("big.xml")
+		fw.Write([]byte(big))
+		// 文件头声明的大小被伪造为很小
+		fw, _ = w.CreateRaw(&zip.FileHeader{Name: "lying.xml", Method: zip.Store, UncompressedSize64: 10, CompressedSize64: uint64(len(big))})
+		fw.Write([]byte(big))
+	})
+
+	if _, err := pkg.readXML("small.xml"); err != nil {
+		t.Errorf("readXML(small.xml): %v", err)
+	}
+	// 声明的大小超限时不解压
+	if _, err := pkg.readXML("big.xml"); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("readXML(big.xml) err = %v, want ErrLimitExceeded", err)
+	}
+	// 伪造大小的部件读取时被截断，解析失败
+	if _, err := pkg.readXML("lying.xml"); err == nil {
+		t.Error("readXML(lying.xml) succeeded, want an error")
+	}
+}
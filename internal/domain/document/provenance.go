@@ -0,0 +1,99 @@
+package document
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Source 内容块在源文件中的位置，不适用的字段为零值
+type Source struct {
+	Page      int    // PDF 页码，从1开始
+	Slide     int    // 幻灯片序号，从1开始
+	Sheet     string // 工作表名称
+	Row       int    // 工作表行号，从1开始
+	Paragraph int    // DOCX 正文段落序号，从1开始，表格计为一个段落
+	Offset    int    // 在解析器提取的文本中的字符偏移
+	perLine   bool   // 块内每行对应一个行号或段落号
+}
+
+// advance 返回经过 text 之后的位置
+func (s Source) advance(text string) Source {
+	s.Offset += utf8.RuneCountInString(text)
+	if s.perLine {
+		lines := strings.Count(text, "\n")
+		if s.Row > 0 {
+			s.Row += lines
+		}
+		if s.Paragraph > 0 {
+			s.Paragraph += lines
+		}
+	}
+	return s
+}
+
+// Provenance 分块在源文件中的范围，用于在回答中标注出处
+// 页码、行号、段落号均包含两端，字符偏移为 [OffsetStart, OffsetEnd)
+type Provenance struct {
+	PageStart      int    `json:"page_start,omitempty"`
+	PageEnd        int    `json:"page_end,omitempty"`
+	SlideStart     int    `json:"slide_start,omitempty"`
+	SlideEnd       int    `json:"slide_end,omitempty"`
+	Sheet          string `json:"sheet,omitempty"`
+	RowStart       int    `json:"row_start,omitempty"`
+	RowEnd         int    `json:"row_end,omitempty"`
+	ParagraphStart int    `json:"paragraph_start,omitempty"`
+	ParagraphEnd   int    `json:"paragraph_end,omitempty"`
+	OffsetStart    int    `json:"offset_start,omitempty"`
+	OffsetEnd      int    `json:"offset_end,omitempty"`
+}
+
+// newProvenance 以起止位置构造范围，to 为最后一个字符之后的位置
+func newProvenance(from, to Source) Provenance {
+	return Provenance{
+		PageStart:      from.Page,
+		PageEnd:        to.Page,
+		SlideStart:     from.Slide,
+		SlideEnd:       to.Slide,
+		Sheet:          from.Sheet,
+		RowStart:       from.Row,
+		RowEnd:         to.Row,
+		ParagraphStart: from.Paragraph,
+		ParagraphEnd:   to.Paragraph,
+		OffsetStart:    from.Offset,
+		OffsetEnd:      to.Offset,
+	}
+}
+
+// Citation 返回分块的出处，如 "report.pdf p.12-13"、"deck.pptx slide 4"、"budget.xlsx Budget rows 40-80"、"plan.docx para. 3-5"
+func (m Metadata) Citation() string {
+	name := m.OriginalFile
+	if name == "" {
+		name = m.Filename
+	}
+	parts := []string{name}
+	p := m.Provenance
+	if p.PageStart > 0 {
+		parts = append(parts, "p."+numberRange(p.PageStart, p.PageEnd))
+	}
+	if p.SlideStart > 0 {
+		parts = append(parts, "slide "+numberRange(p.SlideStart, p.SlideEnd))
+	}
+	if p.Sheet != "" {
+		parts = append(parts, p.Sheet)
+	}
+	if p.RowStart > 0 {
+		parts = append(parts, "rows "+numberRange(p.RowStart, p.RowEnd))
+	}
+	if p.ParagraphStart > 0 {
+		parts = append(parts, "para. "+numberRange(p.ParagraphStart, p.ParagraphEnd))
+	}
+	return strings.Join(parts, " ")
+}
+
+func numberRange(start, end int) string {
+	if end <= start {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d-%d", start, end)
+}
@@ -0,0 +1,220 @@
+package document
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/embedding"
+)
+
+const (
+	// DefaultBreakpointPercentile 相邻句子距离超过该百分位时断开
+	DefaultBreakpointPercentile = 95
+	// semanticWindow 生成句子向量时前后各拼接的句子数，减少短句向量的噪声
+	semanticWindow = 1
+)
+
+// SemanticSplitter 按语义切分：为每个句子生成向量，在相邻句子向量距离达到百分位阈值的位置断开
+// 分句、计数方式和超长句子的切分沿用 Base，MinChunkSize 和 MaxChunkSize 的单位与 Base 一致，分块之间不重叠
+type SemanticSplitter struct {
+	Embedder             embedding.Embedder
+	Base                 *TextSplitter
+	BreakpointPercentile float64 // 0 表示使用 DefaultBreakpointPercentile
+	MinChunkSize         int     // 未达到该大小时不在断点处断开，0 表示 MaxChunkSize 的1/4
+	MaxChunkSize         int     // 0 表示沿用 Base 的分块大小
+}
+
+func NewSemanticSplitter(embedder embedding.Embedder, base *TextSplitter) *SemanticSplitter {
+	return &SemanticSplitter{
+		Embedder:             embedder,
+		Base:                 base,
+		BreakpointPercentile: DefaultBreakpointPercentile,
+	}
+}
+
+// semanticSection 一个章节的句子，offset 为首个句子在整篇文档句子中的下标
+type semanticSection struct {
+	section Section
+	size    int
+	units   []splitUnit
+	offset  int
+}
+
+// SplitSections 逐章节切分，分块不跨越章节；断点阈值按整篇文档的句子距离计算
+func (s *SemanticSplitter) SplitSections(ctx context.Context, sections []Section) ([]Chunk, error) {
+	st := *s.Base.strategy(sections)
+	if s.MaxChunkSize > 0 {
+		st.size = s.MaxChunkSize
+	}
+	minSize := s.MinChunkSize
+	if minSize <= 0 {
+		minSize = st.size / 4
+	}
+
+	var parts []semanticSection
+	var texts []string
+	for _, section := range sections {
+		part := semanticSection{
+			section: section,
+			size:    st.sectionSize(section),
+			offset:  len(texts),
+		}
+		for _, block := range st.blocks(section) {
+			// 表格行整行、代码块整块作为一个单位
+			var units []splitUnit
+			switch {
+			case block.code:
+				units = st.codeUnits(block.text, part.size)
+			case block.row:
+				units = st.units(block.text, part.size, st.levels)
+			default:
+				units = st.sentenceUnits(block.text, part.size)
+			}
+			part.units = append(part.units, st.blockUnits(block, units)...)
+		}
+		if len(part.units) == 0 {
+			continue
+		}
+		for i := range part.units {
+			texts = append(texts, windowText(part.units, i))
+		}
+		parts = append(parts, part)
+	}
+
+	vectors, err := s.embed(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+
+	// distances[i] 为第 i 个句子与同一章节内下一个句子的距离
+	distances := make([]float64, len(texts))
+	var all []float64
+	for _, part := range parts {
+		for i := part.offset; i < part.offset+len(part.units)-1; i++ {
+			distances[i] = cosineDistance(vectors[i], vectors[i+1])
+			all = append(all, distances[i])
+		}
+	}
+	threshold := percentile(all, s.percentile())
+
+	var chunks []Chunk
+	for _, part := range parts {
+		partDistances := distances[part.offset : part.offset+len(part.units)]
+		for _, group := range packSemantic(part.units, partDistances, threshold, min(minSize, part.size), part.size) {
+			chunks = append(chunks, newChunk(group, part.section))
+		}
+	}
+	return chunks, nil
+}
+
+func (s *SemanticSplitter) percentile() float64 {
+	if s.BreakpointPercentile <= 0 || s.BreakpointPercentile > 100 {
+		return DefaultBreakpointPercentile
+	}
+	return s.BreakpointPercentile
+}
+
+// embed 批量生成句子向量，少于两个句子时无需计算距离
+func (s *SemanticSplitter) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) < 2 {
+		return nil, nil
+	}
+	embeddings, err := s.Embedder.EmbedBatch(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed sentences: %w", err)
+	}
+	if len(embeddings) != len(texts) {
+		return nil, fmt.Errorf("embedder returned %d vectors for %d sentences", len(embeddings), len(texts))
+	}
+	vectors := make([][]float32, len(embeddings))
+	for i, emb := range embeddings {
+		if emb == nil || len(emb.Vector) == 0 {
+			return nil, fmt.Errorf("received empty embedding for sentence %d", i)
+		}
+		vectors[i] = emb.Vector
+	}
+	return vectors, nil
+}
+
+// sentenceUnits 将内容块切分为句子，超过 size 的句子按后续层级继续切分
+func (st *splitStrategy) sentenceUnits(block string, size int) []splitUnit {
+	level := st.levels[0]
+	var units []splitUnit
+	for _, sentence := range level.split(block) {
+		sub := st.units(sentence, size, st.levels[1:])
+		sub[len(sub)-1].sep = level.sep(sentence)
+		units = append(units, sub...)
+	}
+	return units
+}
+
+// windowText 第 i 个句子连同前后 semanticWindow 个句子，用于生成句子向量
+func windowText(units []splitUnit, i int) string {
+	start, end := max(i-semanticWindow, 0), min(i+semanticWindow+1, len(units))
+	texts := make([]string, 0, end-start)
+	for _, u := range units[start:end] {
+		texts = append(texts, u.text)
+	}
+	return strings.Join(texts, " ")
+}
+
+// packSemantic 依次放入句子，距离达到 threshold 且分块不小于 minSize 时断开，超过 size 时强制断开
+// 末尾不足 minSize 的分块并入上一个分块
+func packSemantic(units []splitUnit, distances []float64, threshold float64, minSize, size int) [][]splitUnit {
+	var groups [][]splitUnit
+	var current []splitUnit
+	tokens, lastTokens := 0, 0
+	flush := func() {
+		groups = append(groups, current)
+		current, lastTokens, tokens = nil, tokens, 0
+	}
+
+	for i, u := range units {
+		if tokens+u.tokens > size && len(current) > 0 {
+			flush()
+		}
+		current = append(current, u)
+		tokens += u.tokens
+		if i < len(units)-1 && distances[i] >= threshold && tokens >= minSize {
+			flush()
+		}
+	}
+	if len(current) > 0 {
+		if n := len(groups); n > 0 && tokens < minSize && lastTokens+tokens <= size {
+			groups[n-1] = append(groups[n-1], current...)
+		} else {
+			groups = append(groups, current)
+		}
+	}
+	return groups
+}
+
+// cosineDistance 余弦距离，取值 [0, 2]，任一向量为零向量时返回1
+func cosineDistance(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := 0; i < len(a) && i < len(b); i++ {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 1
+	}
+	return 1 - dot/(math.Sqrt(normA)*math.Sqrt(normB))
+}
+
+// percentile 线性插值计算百分位数，没有数据时返回 +Inf(不断开)
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return math.Inf(1)
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	pos := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(pos-float64(lo))
+}
@@ -0,0 +1,331 @@
+package document
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/infrastructure/msoffice"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/transform"
+)
+
+// ErrUnsupportedMediaType 文件内容无法识别，或与扩展名、声明的类型不符
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+// 支持及可识别的 MIME 类型
+const (
+	TypePDF      = "application/pdf"
+	TypeText     = "text/plain"
+	TypeMarkdown = "text/markdown"
+	TypeHTML     = "text/html"
+	TypeDOCX     = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	TypeXLSX     = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	TypePPTX     = "application/vnd.openxmlformats-officedocument.presentationml.presentation"
+	TypeDOC      = "application/msword"
+	TypeXLS      = "application/vnd.ms-excel"
+	TypePPT      = "application/vnd.ms-powerpoint"
+	TypeZip      = "application/zip"
+	TypeTar      = "application/x-tar"
+	TypeGzip     = "application/gzip"
+	TypeCFB      = "application/x-cfb" // 无法识别的复合文档
+	TypeUnknown  = "application/octet-stream"
+)
+
+// extensionTypes 扩展名 -> MIME 类型
+var extensionTypes = map[string]string{
+	".pdf":      TypePDF,
+	".txt":      TypeText,
+	".md":       TypeMarkdown,
+	".markdown": TypeMarkdown,
+	".html":     TypeHTML,
+	".htm":      TypeHTML,
+	".docx":     TypeDOCX,
+	".xlsx":     TypeXLSX,
+	".pptx":     TypePPTX,
+	".doc":      TypeDOC,
+	".xls":      TypeXLS,
+	".ppt":      TypePPT,
+	".zip":      TypeZip,
+	".tar":      TypeTar,
+	".tgz":      TypeGzip,
+	".gz":       TypeGzip,
+}
+
+// typeAliases 客户端常用的非标准 MIME 类型
+var typeAliases = map[string]string{
+	"application/x-pdf":            TypePDF,
+	"text/x-markdown":              TypeMarkdown,
+	"application/xhtml+xml":        TypeHTML,
+	"application/x-zip":            TypeZip,
+	"application/x-zip-compressed": TypeZip,
+	"application/x-gzip":           TypeGzip,
+	"application/x-compressed-tar": TypeGzip,
+}
+
+// TypeForExtension 返回扩展名对应的 MIME 类型，未知扩展名返回空字符串
+func TypeForExtension(ext string) string {
+	return extensionTypes[strings.ToLower(ext)]
+}
+
+// IsArchiveType 判断是否为可展开为多个文档的归档格式
+func IsArchiveType(t string) bool {
+	return t == TypeZip || t == TypeTar || t == TypeGzip
+}
+
+// isTextType 纯文本类格式，内容嗅探只能识别为文本，具体格式以扩展名或声明的类型为准
+func isTextType(t string) bool {
+	return t == TypeText || t == TypeMarkdown || t == TypeHTML
+}
+
+var (
+	pdfMagic  = []byte("%PDF-")
+	zipMagic  = []byte("PK\x03\x04")
+	cfbMagic  = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1} // Office 97-2003 复合文档
+	gzipMagic = []byte{0x1f, 0x8b}
+	tarMagic  = []byte("ustar") // 位于偏移 257
+)
+
+// sniffLen 识别文本和 HTML 时读取的字节数
+const sniffLen = 8 << 10
+
+// ooxmlMainTypes OOXML 主文档部件的内容类型 -> 文档的 MIME 类型，包括启用宏的文档和模板
+var ooxmlMainTypes = map[string]string{
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml":   TypeDOCX,
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.template.main+xml":   TypeDOCX,
+	"application/vnd.ms-word.document.macroenabled.main+xml":                             TypeDOCX,
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml":         TypeXLSX,
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.template.main+xml":      TypeXLSX,
+	"application/vnd.ms-excel.sheet.macroenabled.main+xml":                               TypeXLSX,
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation.main+xml": TypePPTX,
+	"application/vnd.openxmlformats-officedocument.presentationml.template.main+xml":     TypePPTX,
+	"application/vnd.ms-powerpoint.presentation.macroenabled.main+xml":                   TypePPTX,
+}
+
+// DetectContentType 按文件头识别 PDF、OOXML、复合文档、归档和文本(UTF-8 或 GBK)，返回 MIME 类型
+// 文本只区分 HTML 和纯文本，无法识别的二进制内容返回 TypeUnknown
+func DetectContentType(r io.ReaderAt, size int64) (string, error) {
+	header := make([]byte, min(size, sniffLen))
+	n, err := r.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read file header: %w", err)
+	}
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, pdfMagic):
+		return TypePDF, nil
+	case bytes.HasPrefix(header, zipMagic):
+		return detectOOXML(r, size)
+	case bytes.HasPrefix(header, cfbMagic):
+		format, err := msoffice.Identify(r)
+		if err != nil {
+			return TypeCFB, nil
+		}
+		switch format {
+		case msoffice.FormatWord:
+			return TypeDOC, nil
+		case msoffice.FormatExcel:
+			return TypeXLS, nil
+		case msoffice.FormatPowerPoint:
+			return TypePPT, nil
+		}
+		return TypeCFB, nil
+	case bytes.HasPrefix(header, gzipMagic):
+		return TypeGzip, nil
+	case len(header) >= 262 && bytes.Equal(header[257:262], tarMagic):
+		return TypeTar, nil
+	case isText(header, int64(n) < size):
+		if isHTML(header) {
+			return TypeHTML, nil
+		}
+		return TypeText, nil
+	}
+	return TypeUnknown, nil
+}
+
+// DetectFileType 按内容识别文件的 MIME 类型
+func DetectFileType(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	return DetectContentType(f, info.Size())
+}
+
+// detectOOXML 按 [Content_Types].xml 中主文档部件的类型识别 Word、Excel 和 PowerPoint 文档
+// 缺少内容类型时按部件目录识别，其他 zip 文件返回 TypeZip
+func detectOOXML(r io.ReaderAt, size int64) (string, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return TypeUnknown, nil
+	}
+	for _, f := range zr.File {
+		if f.Name != "[Content_Types].xml" {
+			continue
+		}
+		if t := ooxmlContentType(f); t != "" {
+			return t, nil
+		}
+	}
+	for _, f := range zr.File {
+		switch {
+		case strings.HasPrefix(f.Name, "word/"):
+			return TypeDOCX, nil
+		case strings.HasPrefix(f.Name, "xl/"):
+			return TypeXLSX, nil
+		case strings.HasPrefix(f.Name, "ppt/"):
+			return TypePPTX, nil
+		}
+	}
+	return TypeZip, nil
+}
+
+// ooxmlContentType 从 [Content_Types].xml 的 Override 中查找主文档部件的类型
+func ooxmlContentType(f *zip.File) string {
+	rc, err := f.Open()
+	if err != nil {
+		return ""
+	}
+	defer rc.Close()
+	var types struct {
+		Overrides []struct {
+			ContentType string `xml:"ContentType,attr"`
+		} `xml:"Override"`
+	}
+	if err := xml.NewDecoder(io.LimitReader(rc, 1<<20)).Decode(&types); err != nil {
+		return ""
+	}
+	for _, o := range types.Overrides {
+		if t, ok := ooxmlMainTypes[strings.ToLower(o.ContentType)]; ok {
+			return t
+		}
+	}
+	return ""
+}
+
+// isText 判断内容是否为 UTF-8 或 GBK 文本，truncated 为 true 时忽略末尾被截断的多字节字符
+func isText(sample []byte, truncated bool) bool {
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return false
+	}
+	if truncated {
+		for i := 0; i < 3 && len(sample) > 0 && sample[len(sample)-1] >= 0x80; i++ {
+			sample = sample[:len(sample)-1]
+		}
+	}
+	if utf8.Valid(sample) {
+		return true
+	}
+	decoded, _, err := transform.Bytes(simplifiedchinese.GB18030.NewDecoder(), sample)
+	return err == nil && !bytes.ContainsRune(decoded, utf8.RuneError)
+}
+
+// isHTML 去掉 BOM 和空白后以 <!DOCTYPE html、<html 等标签开头时视为 HTML
+func isHTML(sample []byte) bool {
+	s := strings.ToLower(strings.TrimLeft(strings.TrimPrefix(string(sample), "\uFEFF"), " \t\r\n"))
+	for _, tag := range []string{"<!doctype html", "<html", "<head", "<body"} {
+		if strings.HasPrefix(s, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveContentType 以文件内容为准，与扩展名和客户端声明的 Content-Type 分别交叉校验后确定文件的 MIME 类型
+//   - 内容为 PDF、Office 文档或归档时，扩展名和声明的类型须属于同一类格式：.doc 实际为 .docx 等新旧格式混用时以内容为准，
+//     .txt 实际为 PDF、.pdf 实际为 Word 文档等返回 ErrUnsupportedMediaType；无法判断的声明类型(如 application/x-download)不参与校验
+//   - 内容为文本时按扩展名，其次按声明的类型区分纯文本、Markdown 和 HTML，都没有时按内容判断；
+//     扩展名或声明的类型为二进制格式时返回 ErrUnsupportedMediaType
+func ResolveContentType(detected, fileExt, declared string) (string, error) {
+	claims := []string{TypeForExtension(fileExt), normalizeType(declared)}
+	for _, claimed := range claims {
+		if !consistentType(detected, claimed) {
+			return "", fmt.Errorf("%w: file is declared as %s but its content is %s", ErrUnsupportedMediaType, claimed, detected)
+		}
+	}
+	if !isTextType(detected) {
+		return detected, nil
+	}
+	for _, claimed := range claims {
+		switch {
+		case isTextType(claimed):
+			return claimed, nil
+		case strings.HasPrefix(claimed, "text/"):
+			// text/csv 等其他文本格式按纯文本处理
+			return TypeText, nil
+		}
+	}
+	return detected, nil
+}
+
+// typeFamilies 同一类格式的新旧版本可以互相替代，zip 包含 OOXML 文档
+var typeFamilies = map[string]string{
+	TypePDF:  "pdf",
+	TypeDOC:  "word",
+	TypeDOCX: "word",
+	TypeXLS:  "excel",
+	TypeXLSX: "excel",
+	TypePPT:  "powerpoint",
+	TypePPTX: "powerpoint",
+	TypeZip:  "archive",
+	TypeTar:  "archive",
+	TypeGzip: "archive",
+}
+
+// consistentType 判断扩展名或声明的类型 claimed 是否与识别出的内容类型 detected 相符
+func consistentType(detected, claimed string) bool {
+	switch {
+	case claimed == "" || claimed == TypeUnknown || claimed == detected:
+		return true
+	case isTextType(detected):
+		return strings.HasPrefix(claimed, "text/")
+	case strings.HasPrefix(claimed, "text/"):
+		return false
+	case detected == TypeUnknown:
+		// 无法识别的内容由调用方按不支持的类型处理
+		return true
+	case detected == TypeCFB:
+		return claimed == TypeDOC || claimed == TypeXLS || claimed == TypePPT
+	case claimed == TypeZip && (detected == TypeDOCX || detected == TypeXLSX || detected == TypePPTX):
+		return true
+	}
+	family, known := typeFamilies[claimed]
+	return !known || family == typeFamilies[detected]
+}
+
+// normalizeType 去掉 Content-Type 中的参数并转换常用别名
+func normalizeType(contentType string) string {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	if alias, ok := typeAliases[t]; ok {
+		return alias
+	}
+	return t
+}
+
+// hasMagic 判断文件是否以 magic 开头
+func hasMagic(filePath string, magic []byte) bool {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	header := make([]byte, len(magic))
+	n, _ := io.ReadFull(f, header)
+	return bytes.Equal(header[:n], magic)
+}
@@ -0,0 +1,170 @@
+package document
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// zipBytes 按文件名 -> 内容构造 zip
+func zipBytes(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarBytes(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	w.WriteHeader(&tar.Header{Name: "a.txt", Mode: 0o644, Size: 5})
+	w.Write([]byte("hello"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func readFixture(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDetectFileType(t *testing.T) {
+	const msofficeTestdata = "../../infrastructure/msoffice/testdata"
+	contentTypes := `<?xml version="1.0"?><Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/></Types>`
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"pdf", []byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n"), TypePDF},
+		{"docx by content types", zipBytes(t, map[string]string{"[Content_Types].xml": contentTypes, "word/document.xml": "<w:document/>"}), TypeDOCX},
+		{"xlsx by part directory", zipBytes(t, map[string]string{"xl/workbook.xml": "<workbook/>"}), TypeXLSX},
+		{"pptx by part directory", zipBytes(t, map[string]string{"ppt/presentation.xml": "<p:presentation/>"}), TypePPTX},
+		{"plain zip", zipBytes(t, map[string]string{"notes.txt": "hello"}), TypeZip},
+		{"tar", tarBytes(t), TypeTar},
+		{"gzip", gzipBytes(t, tarBytes(t)), TypeGzip},
+		{"doc", readFixture(t, filepath.Join(msofficeTestdata, "novpapplan.doc")), TypeDOC},
+		{"xls", readFixture(t, filepath.Join(msofficeTestdata, "test.xls")), TypeXLS},
+		{"truncated compound document", cfbMagic, TypeCFB},
+		{"utf-8 text", []byte("# 标题\n\n正文"), TypeText},
+		{"gbk text", readFixture(t, gbkFixture), TypeText},
+		{"html with bom", []byte("\uFEFF\n  <!DOCTYPE html><html><body>hi</body></html>"), TypeHTML},
+		// 8KB 采样末尾截断的多字节字符不影响识别
+		{"long utf-8 text", []byte(strings.Repeat("a", sniffLen-1) + "中文"), TypeText},
+		{"binary", []byte{0x00, 0x01, 0x02, 0xff}, TypeUnknown},
+		{"empty", nil, TypeText},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, strings.ReplaceAll(tt.name, " ", "_"))
+			if err := os.WriteFile(path, tt.data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := DetectFileType(path)
+			if err != nil {
+				t.Fatalf("DetectFileType: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("DetectFileType = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := DetectFileType(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("DetectFileType(missing) err = %v, want not exist", err)
+	}
+}
+
+func TestResolveContentType(t *testing.T) {
+	tests := []struct {
+		name     string
+		detected string
+		ext      string
+		declared string
+		want     string // 为空时期望 ErrUnsupportedMediaType
+	}{
+		{"pdf", TypePDF, ".pdf", "application/pdf", TypePDF},
+		{"pdf alias", TypePDF, "", "application/x-pdf", TypePDF},
+		{"generic declared type", TypePDF, ".pdf", "application/octet-stream", TypePDF},
+		{"unknown declared type", TypePDF, ".pdf", "application/x-download", TypePDF},
+		{"no claims", TypeXLSX, "", "", TypeXLSX},
+		// 新旧 Office 格式混用时以内容为准
+		{"doc that is docx", TypeDOCX, ".doc", TypeDOC, TypeDOCX},
+		{"xlsx that is xls", TypeXLS, ".xlsx", "", TypeXLS},
+		{"docx declared as zip", TypeDOCX, ".docx", "application/zip", TypeDOCX},
+		{"unidentified compound document", TypeCFB, ".ppt", "", TypeCFB},
+		{"tgz", TypeGzip, ".tgz", "application/x-gzip", TypeGzip},
+		// 二进制内容与扩展名或声明的类型不符
+		{"txt that is pdf", TypePDF, ".txt", "", ""},
+		{"pdf declared as text", TypePDF, ".pdf", "text/plain", ""},
+		{"pdf that is docx", TypeDOCX, ".pdf", "", ""},
+		{"docx that is pptx", TypePPTX, ".docx", "", ""},
+		{"xlsx declared as pdf", TypeXLSX, ".xlsx", "application/pdf", ""},
+		{"zip that is pdf", TypePDF, ".zip", "", ""},
+		{"doc that is plain zip", TypeZip, ".doc", "", ""},
+		{"compound document declared as pdf", TypeCFB, ".pdf", "", ""},
+		// 文本内容按扩展名，其次按声明的类型区分格式
+		{"markdown by extension", TypeText, ".md", "text/plain", TypeMarkdown},
+		{"markdown by declared type", TypeText, ".mdx", "text/x-markdown; charset=utf-8", TypeMarkdown},
+		{"html saved as txt", TypeHTML, ".txt", "", TypeText},
+		{"csv", TypeText, ".csv", "text/csv", TypeText},
+		{"text without claims", TypeHTML, "", "", TypeHTML},
+		{"text with generic declared type", TypeText, "", "application/octet-stream", TypeText},
+		{"pdf that is text", TypeText, ".pdf", "", ""},
+		{"markdown declared as docx", TypeText, ".md", TypeDOCX, ""},
+		{"json", TypeText, ".json", "application/json", ""},
+		// 无法识别的内容交由调用方处理
+		{"unknown content", TypeUnknown, ".pdf", "", TypeUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveContentType(tt.detected, tt.ext, tt.declared)
+			if tt.want == "" {
+				if !errors.Is(err, ErrUnsupportedMediaType) {
+					t.Errorf("ResolveContentType = %q, %v, want ErrUnsupportedMediaType", got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ResolveContentType = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
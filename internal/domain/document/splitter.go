@@ -1,77 +1,425 @@
 package document
 
 import (
+	"context"
+	"sort"
 	"strings"
+	"unicode"
 )
 
+// Tokenizer 统计文本的 token 数，应与嵌入模型使用的分词器一致
+type Tokenizer interface {
+	CountTokens(text string) int
+}
+
+// byteCounter 未配置分词器时按字节计数
+type byteCounter struct{}
+
+func (byteCounter) CountTokens(text string) int {
+	return len(text)
+}
+
+// SplitMode 分块方式
+type SplitMode string
+
+const (
+	SplitAuto  SplitMode = "auto"  // 根据文本检测语言，中日文使用 SplitCJK，其余使用 SplitWords
+	SplitWords SplitMode = "words" // 按句、行、词逐级切分
+	SplitCJK   SplitMode = "cjk"   // 按中日文句末标点分句，超长句子按字符窗口切分
+)
+
+// TextSplitter 递归地按章节 → 段落 → 句子 → 词的层级切分文本，放不下一个分块时才进入下一级
+// ChunkSize 和 ChunkOverlap 以 Tokenizer 的 token 数计，未设置 Tokenizer 时以字节计。重叠部分不会截断字符
+// 中日文模式下 CJKChunkSize 和 CJKChunkOverlap 以字符数计，未设置时沿用 ChunkSize 和 ChunkOverlap
 type TextSplitter struct {
-	ChunkSize    int
-	ChunkOverlap int
+	ChunkSize       int
+	ChunkOverlap    int
+	Tokenizer       Tokenizer
+	Mode            SplitMode
+	CJKChunkSize    int
+	CJKChunkOverlap int
 }
 
 func NewTextSplitter(chunkSize, chunkOverlap int) *TextSplitter {
 	return &TextSplitter{
 		ChunkSize:    chunkSize,
 		ChunkOverlap: chunkOverlap,
+		Mode:         SplitAuto,
 	}
 }
 
-func (s *TextSplitter) Split(content string) ([]string, error) {
-	// 首先按段落分割
-	paragraphs := strings.Split(content, "\n\n")
+// Chunk 切分结果，Headings 为分块所在章节的标题路径
+type Chunk struct {
+	Content    string
+	Headings   []string
+	Provenance Provenance
+}
+
+// newChunk 拼接单位为分块，章节有 Preamble 时放在分块开头
+// 位置范围取首个单位的起点到最后一个单位的终点
+func newChunk(units []splitUnit, section Section) Chunk {
+	content := joinUnits(units)
+	if section.Preamble != "" {
+		content = section.Preamble + "\n" + content
+	}
+	return Chunk{
+		Content:    content,
+		Headings:   section.Headings,
+		Provenance: newProvenance(units[0].from, units[len(units)-1].to),
+	}
+}
+
+// splitUnit 分块的最小单位：能放入分块的段落、句子或词
+type splitUnit struct {
+	text    string
+	tokens  int
+	sep     string // 与下一个单位之间的分隔符
+	paraEnd bool   // 段落的最后一个单位
+	from    Source // 首字符的位置
+	to      Source // 最后一个字符之后的位置
+}
 
-	var chunks []string
-	var currentChunk strings.Builder
-	currentLength := 0
+// preparedBlock 预处理后的内容块，exact 为 false 时预处理改写了文本，块内的单位只能定位到整个块
+type preparedBlock struct {
+	text     string
+	from, to Source
+	exact    bool
+	row      bool
+	code     bool
+}
 
-	for _, para := range paragraphs {
-		para = strings.TrimSpace(para)
-		if para == "" {
+// locate 依次在块内查找各单位的文本，记录其位置
+func (b preparedBlock) locate(units []splitUnit) {
+	pos, cursor := b.from, 0
+	for i := range units {
+		u := &units[i]
+		idx := -1
+		if b.exact {
+			idx = strings.Index(b.text[cursor:], u.text)
+		}
+		if idx < 0 {
+			u.from, u.to = b.from, b.to
 			continue
 		}
+		pos = pos.advance(b.text[cursor : cursor+idx])
+		u.from, u.to = pos, pos.advance(u.text)
+		pos, cursor = u.to, cursor+idx+len(u.text)
+	}
+}
 
-		words := strings.Fields(para)
-		for _, word := range words {
-			wordLength := len(word)
-
-			// 如果添加这个词会超过chunk大小，并且当前chunk不为空
-			if currentLength+wordLength > s.ChunkSize && currentLength > 0 {
-				chunks = append(chunks, currentChunk.String())
-
-				// 处理重叠部分
-				if s.ChunkOverlap > 0 {
-					lastChunk := currentChunk.String()
-					overlapStart := len(lastChunk) - s.ChunkOverlap
-					if overlapStart < 0 {
-						overlapStart = 0
-					}
-					currentChunk.Reset()
-					currentChunk.WriteString(lastChunk[overlapStart:])
-					currentLength = len(lastChunk) - overlapStart
-				} else {
-					currentChunk.Reset()
-					currentLength = 0
-				}
+// splitLevel 一级分隔方式，sep 返回片段与下一个片段之间的分隔符
+type splitLevel struct {
+	split func(text string) []string
+	sep   func(piece string) string
+}
+
+// splitStrategy 一种语言模式下的计数方式和分隔层级
+type splitStrategy struct {
+	size, overlap int
+	count         func(text string) int
+	levels        []splitLevel
+	fallback      func(text string, size int) []string // 所有层级都放不下时按字符切分
+	tail          func(text string, limit int) string  // 取文本末尾不超过 limit 的部分作为重叠
+	prepare       func(block string) string
+}
+
+// Split 将纯文本按空行分段后切分
+func (s *TextSplitter) Split(content string) ([]string, error) {
+	chunks, err := s.SplitSections(context.Background(), PlainSections(content))
+	if err != nil {
+		return nil, err
+	}
+	texts := make([]string, len(chunks))
+	for i, c := range chunks {
+		texts[i] = c.Content
+	}
+	return texts, nil
+}
+
+// SplitSections 逐章节切分，分块不跨越章节
+// 标题路径会拼接在分块前用于生成向量，因此每个分块的容量扣除了标题路径的长度
+func (s *TextSplitter) SplitSections(ctx context.Context, sections []Section) ([]Chunk, error) {
+	st := s.strategy(sections)
+	var chunks []Chunk
+	for _, section := range sections {
+		size := st.sectionSize(section)
+		var units []splitUnit
+		for _, block := range st.blocks(section) {
+			units = append(units, st.blockUnits(block, st.split(block, size))...)
+		}
+
+		for _, group := range st.pack(units, size) {
+			chunks = append(chunks, newChunk(group, section))
+		}
+	}
+	return chunks, nil
+}
+
+// strategy 按 Mode 选择分隔层级，SplitAuto 时根据全部章节的文本检测语言
+func (s *TextSplitter) strategy(sections []Section) *splitStrategy {
+	mode := s.Mode
+	if mode == "" || mode == SplitAuto {
+		mode = SplitWords
+		if isCJKText(sectionsText(sections)) {
+			mode = SplitCJK
+		}
+	}
+	if mode == SplitCJK {
+		return s.cjkStrategy()
+	}
+	return s.wordStrategy()
+}
+
+// wordStrategy 按空白分词的语言：句子 → 行 → 词 → 字符
+func (s *TextSplitter) wordStrategy() *splitStrategy {
+	count := s.countTokens
+	if s.Tokenizer == nil {
+		// 按字节计数时包含单位后的分隔符
+		count = func(text string) int { return len(text) + 1 }
+	}
+	space := func(string) string { return " " }
+	return &splitStrategy{
+		size:    s.ChunkSize,
+		overlap: s.ChunkOverlap,
+		count:   count,
+		levels: []splitLevel{
+			{split: func(text string) []string { return splitSentences(text, isSentenceEnd, true) }, sep: space},
+			{split: splitLines, sep: func(string) string { return "\n" }},
+			{split: strings.Fields, sep: space},
+		},
+		fallback: s.splitWord,
+		tail: func(text string, limit int) string {
+			// 从末尾逐词累加，重叠部分总是从完整的词开始
+			words := strings.Fields(text)
+			start, tokens := len(words), 0
+			for start > 0 && tokens+count(words[start-1]) <= limit {
+				start--
+				tokens += count(words[start])
 			}
+			return strings.Join(words[start:], " ")
+		},
+	}
+}
+
+// sectionSize 扣除标题路径和 Preamble 后的分块容量，两者过长时至少保留一半容量给正文
+func (st *splitStrategy) sectionSize(section Section) int {
+	size := st.size
+	if len(section.Headings) > 0 {
+		size -= st.count(Breadcrumb(section.Headings) + "\n\n")
+	}
+	if section.Preamble != "" {
+		size -= st.count(section.Preamble + "\n")
+	}
+	if size < st.size/2 {
+		size = st.size / 2
+	}
+	return size
+}
+
+// blockUnits 标记内容块最后一个单位的结尾并记录各单位的位置：表格行之后换行，其余内容块之后空行
+func (st *splitStrategy) blockUnits(block preparedBlock, units []splitUnit) []splitUnit {
+	if block.row {
+		units[len(units)-1].sep = "\n"
+	} else {
+		units[len(units)-1].paraEnd = true
+	}
+	block.locate(units)
+	return units
+}
 
-			if currentLength > 0 {
-				currentChunk.WriteRune(' ')
-				currentLength++
+// blocks 返回章节内预处理后的非空内容块，去掉的首尾空白计入位置
+// 代码块只去掉开头的空行，保留首行缩进，也不做预处理
+func (st *splitStrategy) blocks(section Section) []preparedBlock {
+	var blocks []preparedBlock
+	for _, block := range section.Blocks {
+		text := strings.TrimLeftFunc(block.Text, unicode.IsSpace)
+		if block.code {
+			text = strings.TrimLeft(block.Text, "\r\n")
+		}
+		from := block.Source.advance(block.Text[:len(block.Text)-len(text)])
+		text = strings.TrimRightFunc(text, unicode.IsSpace)
+		if text == "" {
+			continue
+		}
+		pb := preparedBlock{text: text, from: from, to: from.advance(text), exact: true, row: block.row, code: block.code}
+		if st.prepare != nil && !block.code {
+			if prepared := strings.TrimSpace(st.prepare(text)); prepared != text {
+				pb.text, pb.exact = prepared, false
 			}
+		}
+		if pb.text != "" {
+			blocks = append(blocks, pb)
+		}
+	}
+	return blocks
+}
+
+// split 将内容块切分为单位，代码块按行切分，其余内容块按各级分隔方式切分
+func (st *splitStrategy) split(block preparedBlock, size int) []splitUnit {
+	if block.code {
+		return st.codeUnits(block.text, size)
+	}
+	return st.units(block.text, size, st.levels)
+}
+
+// codeUnits 代码块放不下一个分块时按行切分，保留缩进和空行，超长的行按字符切分
+func (st *splitStrategy) codeUnits(text string, size int) []splitUnit {
+	if tokens := st.count(text); tokens <= size {
+		return []splitUnit{{text: text, tokens: tokens}}
+	}
+	var units []splitUnit
+	for _, line := range strings.Split(text, "\n") {
+		sub := st.units(line, size, nil)
+		sub[len(sub)-1].sep = "\n"
+		units = append(units, sub...)
+	}
+	return units
+}
 
-			currentChunk.WriteString(word)
-			currentLength += wordLength
+// units 依次尝试各级分隔方式，只有放不下一个分块的片段才交给下一级继续切分
+func (st *splitStrategy) units(text string, size int, levels []splitLevel) []splitUnit {
+	if tokens := st.count(text); tokens <= size {
+		return []splitUnit{{text: text, tokens: tokens}}
+	}
+	if len(levels) == 0 {
+		var units []splitUnit
+		for _, piece := range st.fallback(text, size) {
+			units = append(units, splitUnit{text: piece, tokens: st.count(piece)})
 		}
+		return units
+	}
 
-		// 段落结束后添加换行
-		currentChunk.WriteString("\n\n")
-		currentLength += 2
+	pieces := levels[0].split(text)
+	if len(pieces) <= 1 {
+		return st.units(text, size, levels[1:])
+	}
+	var units []splitUnit
+	for _, piece := range pieces {
+		sub := st.units(piece, size, levels[1:])
+		sub[len(sub)-1].sep = levels[0].sep(piece)
+		units = append(units, sub...)
+	}
+	return units
+}
+
+// pack 依次放入单位，超过 size 时开始新分块，新分块以上一个分块末尾不超过 overlap 的内容开头
+func (st *splitStrategy) pack(units []splitUnit, size int) [][]splitUnit {
+	var chunks [][]splitUnit
+	var current []splitUnit
+	tokens := 0
+
+	for _, u := range units {
+		// 如果添加这个单位会超过chunk大小，并且当前chunk不为空
+		if tokens+u.tokens > size && len(current) > 0 {
+			chunks = append(chunks, current)
+			limit := st.overlap
+			if room := size - u.tokens; room < limit {
+				limit = room
+			}
+			current = st.overlapUnits(current, limit)
+			tokens = 0
+			for _, o := range current {
+				tokens += o.tokens
+			}
+		}
+		current = append(current, u)
+		tokens += u.tokens
 	}
 
 	// 添加最后一个chunk
-	if currentLength > 0 {
-		chunks = append(chunks, currentChunk.String())
+	if len(current) > 0 {
+		chunks = append(chunks, current)
 	}
+	return chunks
+}
 
-	return chunks, nil
+// overlapUnits 取上一个分块末尾不超过 limit 的若干个完整单位，作为下一个分块的开头
+// 最后一个单位就超过 limit 时，取它末尾的若干个词(中日文为若干字符)
+func (st *splitStrategy) overlapUnits(chunk []splitUnit, limit int) []splitUnit {
+	if limit <= 0 {
+		return nil
+	}
+	tokens := 0
+	start := len(chunk)
+	// 至少保留一个单位不重叠，保证每个分块都有新内容
+	for start > 1 && tokens+chunk[start-1].tokens <= limit {
+		start--
+		tokens += chunk[start].tokens
+	}
+	if start < len(chunk) {
+		return append([]splitUnit(nil), chunk[start:]...)
+	}
+
+	last := chunk[len(chunk)-1]
+	tail := st.tail(last.text, limit)
+	if tail == "" || tail == last.text {
+		return nil
+	}
+	from := last.from
+	if idx := strings.LastIndex(last.text, tail); idx >= 0 {
+		from = from.advance(last.text[:idx])
+	}
+	return []splitUnit{{text: tail, tokens: st.count(tail), sep: last.sep, paraEnd: last.paraEnd, from: from, to: last.to}}
+}
+
+// splitWord 将超长的词(如无空格的长句)切成不超过 size 的片段，切分位置总在字符边界上
+func (s *TextSplitter) splitWord(word string, size int) []string {
+	var pieces []string
+	runes := []rune(word)
+	for len(runes) > 0 {
+		// 先倍增确定搜索范围，避免每次都对整个剩余文本计数
+		hi := size
+		if hi < 1 {
+			hi = 1
+		}
+		for hi < len(runes) && s.countTokens(string(runes[:hi])) <= size {
+			hi *= 2
+		}
+		if hi > len(runes) {
+			hi = len(runes)
+		}
+		// 二分查找能放入一个分块的最长前缀
+		n := sort.Search(hi, func(i int) bool {
+			return s.countTokens(string(runes[:i+1])) > size
+		})
+		if n == 0 {
+			n = 1
+		}
+		pieces = append(pieces, string(runes[:n]))
+		runes = runes[n:]
+	}
+	return pieces
+}
+
+func (s *TextSplitter) countTokens(text string) int {
+	if s.Tokenizer == nil {
+		return byteCounter{}.CountTokens(text)
+	}
+	return s.Tokenizer.CountTokens(text)
+}
+
+// splitLines 按行切分，忽略空行
+func splitLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// joinUnits 单位之间以各自的分隔符连接，段落之间以空行分隔
+func joinUnits(units []splitUnit) string {
+	var b strings.Builder
+	for i, u := range units {
+		b.WriteString(u.text)
+		switch {
+		case u.paraEnd:
+			b.WriteString("\n\n")
+		case i < len(units)-1:
+			b.WriteString(u.sep)
+		}
+	}
+	return b.String()
 }
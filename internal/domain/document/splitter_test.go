@@ -0,0 +1,94 @@
+package document
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// wordTokenizer 每个词记为一个 token，与字节数明显不同
+type wordTokenizer struct{}
+
+func (wordTokenizer) CountTokens(text string) int {
+	return len(strings.Fields(text))
+}
+
+func splitWith(t *testing.T, s *TextSplitter, text string) []string {
+	t.Helper()
+	chunks, err := s.Split(text)
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	for i := range chunks {
+		chunks[i] = strings.TrimSpace(chunks[i])
+	}
+	return chunks
+}
+
+func TestSplitCountsSizeAndOverlapInTokens(t *testing.T) {
+	s := NewTextSplitter(5, 2)
+	s.Tokenizer = wordTokenizer{}
+	// 长词和短词混排：按字节计数时分块边界完全不同
+	text := "a internationalization b c electroencephalography d e f g"
+
+	want := []string{
+		"a internationalization b c electroencephalography",
+		"c electroencephalography d e f",
+		"e f g",
+	}
+	if got := splitWith(t, s, text); !reflect.DeepEqual(got, want) {
+		t.Errorf("chunks = %q, want %q", got, want)
+	}
+}
+
+func TestSplitOverlapStartsOnWordBoundary(t *testing.T) {
+	s := NewTextSplitter(6, 2)
+	s.Tokenizer = wordTokenizer{}
+	s.Mode = SplitWords
+	// 每句4个词，句子放不下重叠时从句尾取完整的词
+	text := "Alpha beta gamma delta. Épée naïve café résumé. One two three four."
+
+	want := []string{
+		"Alpha beta gamma delta.",
+		"gamma delta. Épée naïve café résumé.",
+		"café résumé. One two three four.",
+	}
+	got := splitWith(t, s, text)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("chunks = %q, want %q", got, want)
+	}
+	for i := 1; i < len(got); i++ {
+		prev := strings.Fields(got[i-1])
+		overlap := strings.Join(prev[len(prev)-2:], " ")
+		if !strings.HasPrefix(got[i], overlap+" ") {
+			t.Errorf("chunk %d %q does not start with the whole words %q", i, got[i], overlap)
+		}
+	}
+}
+
+func TestSplitByteOverlapKeepsRunes(t *testing.T) {
+	// 未设置 Tokenizer 时按字节计数，重叠和超长词的切分都不截断多字节字符
+	s := NewTextSplitter(24, 10)
+	s.Mode = SplitWords
+	text := "résumé café naïve déjà Zürich Ålesund señor über " + strings.Repeat("é", 20)
+
+	want := []string{
+		"résumé café naïve",
+		"naïve déjà Zürich",
+		"Zürich Ålesund señor",
+		"señor über",
+		// 超长词按字符切分，每段不超过24字节
+		strings.Repeat("é", 12),
+		strings.Repeat("é", 8),
+	}
+	chunks := splitWith(t, s, text)
+	if !reflect.DeepEqual(chunks, want) {
+		t.Fatalf("chunks = %q, want %q", chunks, want)
+	}
+	for i, c := range chunks {
+		if !utf8.ValidString(c) || len(c) > 24 {
+			t.Errorf("chunk %d %q is %d bytes or not valid UTF-8", i, c, len(c))
+		}
+	}
+}
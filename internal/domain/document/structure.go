@@ -0,0 +1,254 @@
+package document
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// HeadingsKey Metadata.Custom 中保存分块标题路径的键
+const HeadingsKey = "headings"
+
+// Section 文档的一个章节
+type Section struct {
+	Headings []string // 从顶层到本章节的标题路径，第一个标题之前的内容为空
+	Blocks   []Block  // 段落、列表、表格等内容块
+	Preamble string   // 在本章节每个分块开头重复的内容，如表格的表头
+}
+
+// Block 章节内的一个内容块，Source 为块首字符在源文件中的位置
+type Block struct {
+	Text   string
+	Source Source
+	row    bool // 表格行，与相邻的行以换行而不是空行连接
+	code   bool // 代码块，放得下时整块放入一个分块，放不下时按行切分并保留缩进
+}
+
+// Breadcrumb 将标题路径拼接为 "一级 > 二级 > 三级"
+func Breadcrumb(headings []string) string {
+	return strings.Join(headings, " > ")
+}
+
+// Headings 返回分块的标题路径，兼容从存储中反序列化得到的 []interface{}
+func (m Metadata) Headings() []string {
+	switch v := m.Custom[HeadingsKey].(type) {
+	case []string:
+		return v
+	case []interface{}:
+		headings := make([]string, 0, len(v))
+		for _, h := range v {
+			if s, ok := h.(string); ok {
+				headings = append(headings, s)
+			}
+		}
+		return headings
+	default:
+		return nil
+	}
+}
+
+// TextWithHeadings 在分块内容前加上标题路径，用于生成向量和构造提示词
+func (d *Document) TextWithHeadings() string {
+	headings := d.Metadata.Headings()
+	if len(headings) == 0 {
+		return d.Content
+	}
+	return Breadcrumb(headings) + "\n\n" + d.Content
+}
+
+// sectionBuilder 按文档顺序接收标题和内容块，组装为章节
+type sectionBuilder struct {
+	path     []string
+	levels   []int
+	sections []Section
+}
+
+// Heading 开始新章节，弹出层级不低于 level 的标题(level 从1开始，数字越小层级越高)
+func (b *sectionBuilder) Heading(level int, title string) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return
+	}
+	for len(b.levels) > 0 && b.levels[len(b.levels)-1] >= level {
+		b.path = b.path[:len(b.path)-1]
+		b.levels = b.levels[:len(b.levels)-1]
+	}
+	b.path = append(b.path, title)
+	b.levels = append(b.levels, level)
+	b.sections = append(b.sections, Section{Headings: append([]string(nil), b.path...)})
+}
+
+// Block 向当前章节添加内容块
+func (b *sectionBuilder) Block(text string, src Source) {
+	b.add(Block{Text: text, Source: src})
+}
+
+// Code 向当前章节添加代码块
+func (b *sectionBuilder) Code(text string, src Source) {
+	b.add(Block{Text: text, Source: src, code: true})
+}
+
+// Row 向当前章节添加表格行
+func (b *sectionBuilder) Row(text string, src Source) {
+	b.add(Block{Text: text, Source: src, row: true})
+}
+
+func (b *sectionBuilder) add(block Block) {
+	if strings.TrimSpace(block.Text) == "" {
+		return
+	}
+	if len(b.sections) == 0 {
+		b.sections = append(b.sections, Section{})
+	}
+	last := &b.sections[len(b.sections)-1]
+	last.Blocks = append(last.Blocks, block)
+}
+
+// Preamble 设置当前章节每个分块开头重复的内容
+func (b *sectionBuilder) Preamble(text string) {
+	if len(b.sections) == 0 {
+		b.sections = append(b.sections, Section{})
+	}
+	b.sections[len(b.sections)-1].Preamble = text
+}
+
+// Sections 返回有内容的章节
+func (b *sectionBuilder) Sections() []Section {
+	var sections []Section
+	for _, s := range b.sections {
+		if len(s.Blocks) > 0 {
+			sections = append(sections, s)
+		}
+	}
+	return sections
+}
+
+// PlainSections 将纯文本按空行分段，作为一个没有标题的章节
+func PlainSections(content string) []Section {
+	var b sectionBuilder
+	offset := 0
+	for _, para := range strings.Split(content, "\n\n") {
+		b.Block(para, Source{Offset: offset})
+		offset += utf8.RuneCountInString(para) + 2
+	}
+	return b.Sections()
+}
+
+var (
+	atxHeading    = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	setextHeading = regexp.MustCompile(`^(=+|-+)\s*$`)
+	codeFence     = regexp.MustCompile("^(`{3,}|~{3,})")
+)
+
+// MarkdownSections 按 Markdown 的 # 标题和下划线标题划分章节，代码块内的 # 不视为标题
+func MarkdownSections(content string) []Section {
+	return markdownSections(content, 0)
+}
+
+// markdownSections offset 为 content 在源文本中的字符偏移，围栏代码块作为一个内容块
+func markdownSections(content string, offset int) []Section {
+	var b sectionBuilder
+	var para []string
+	paraOffset := offset
+	flush := func() {
+		b.Block(strings.Join(para, "\n"), Source{Offset: paraOffset})
+		para = nil
+	}
+
+	fence, codeOffset := "", 0
+	var code []string
+	for _, raw := range strings.Split(content, "\n") {
+		line := strings.TrimSuffix(raw, "\r")
+		lineOffset := offset
+		if len(para) == 0 {
+			paraOffset = offset
+		}
+		offset += utf8.RuneCountInString(raw) + 1
+
+		// 代码块以相同字符、不短于开始标记的围栏结束
+		if fence != "" {
+			code = append(code, line)
+			if closing := strings.TrimSpace(line); len(closing) >= len(fence) && strings.Trim(closing, fence[:1]) == "" {
+				b.Code(strings.Join(code, "\n"), Source{Offset: codeOffset})
+				fence, code = "", nil
+			}
+			continue
+		}
+		if m := codeFence.FindString(strings.TrimSpace(line)); m != "" {
+			flush()
+			fence, codeOffset, code = m, lineOffset, []string{line}
+			continue
+		}
+
+		if m := atxHeading.FindStringSubmatch(line); m != nil {
+			flush()
+			b.Heading(len(m[1]), m[2])
+			continue
+		}
+		// 下划线标题: 上一行是单行段落
+		if m := setextHeading.FindStringSubmatch(line); m != nil && len(para) == 1 {
+			title := para[0]
+			para = nil
+			level := 1
+			if strings.HasPrefix(m[1], "-") {
+				level = 2
+			}
+			b.Heading(level, title)
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		para = append(para, line)
+	}
+	flush()
+	// 未闭合的代码块延续到文末
+	if fence != "" {
+		b.Code(strings.Join(code, "\n"), Source{Offset: codeOffset})
+	}
+	return b.Sections()
+}
+
+// newChunkDocuments 将切分结果转换为分块，文档属性和标题路径写入 Metadata.Custom
+func newChunkDocuments(chunks []Chunk, filePath string, parsed *ParsedDocument) []*Document {
+	var documents []*Document
+	for _, chunk := range chunks {
+		doc := &Document{
+			Content: chunk.Content,
+			Metadata: Metadata{
+				Filename:    filepath.Base(filePath),
+				ContentType: parsed.ContentType,
+				Provenance:  chunk.Provenance,
+			},
+		}
+		if len(parsed.Metadata) > 0 || len(chunk.Headings) > 0 {
+			doc.Metadata.Custom = make(map[string]interface{}, len(parsed.Metadata)+1)
+			for k, v := range parsed.Metadata {
+				doc.Metadata.Custom[k] = v
+			}
+		}
+		if len(chunk.Headings) > 0 {
+			doc.Metadata.Custom[HeadingsKey] = chunk.Headings
+		}
+		documents = append(documents, doc)
+	}
+	return documents
+}
+
+// sectionsText 拼接全部章节文本，用于语言检测
+func sectionsText(sections []Section) string {
+	var b strings.Builder
+	for _, s := range sections {
+		for _, h := range s.Headings {
+			b.WriteString(h)
+			b.WriteByte('\n')
+		}
+		for _, block := range s.Blocks {
+			b.WriteString(block.Text)
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
@@ -1,10 +1,11 @@
 package document
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
+	"unicode/utf8"
 
 	"golang.org/x/net/html/charset"
 	"golang.org/x/text/encoding/simplifiedchinese"
@@ -22,45 +23,51 @@ func NewTextParser(chunkSize, chunkOverlap int) *TextParser {
 }
 
 func (p *TextParser) Parse(filePath string) ([]*Document, error) {
+	return ParseWith(context.Background(), p, p.textSplitter, filePath)
+}
+
+// ParseSections 读取文本并转换为 UTF-8，按空行分段
+func (p *TextParser) ParseSections(filePath string) (*ParsedDocument, error) {
+	content, err := readText(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return &ParsedDocument{ContentType: "text/plain", Sections: PlainSections(content)}, nil
+}
+
+// readText 读取文本文件并转换为 UTF-8，统一换行符，分块的字符偏移以转换后的文本计
+func readText(filePath string) (string, error) {
 	rawContent, err := os.ReadFile(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read text file: %w", err)
+		return "", fmt.Errorf("failed to read text file: %w", err)
 	}
 
 	// 自动检测编码并转换为UTF-8
-	encoding, name, _ := charset.DetermineEncoding(rawContent, "")
-	var utf8Content string
-	//todo,下面代码可能需要将name改为encoding
-	fmt.Println("encoding:", encoding, "name:", name)
-	switch {
-	case strings.Contains(name, "GBK"):
-		decoder := simplifiedchinese.GBK.NewDecoder()
-		utf8Content, _, err = transform.String(decoder, string(rawContent))
-		if err != nil {
-			return nil, fmt.Errorf("failed to convert GBK to UTF-8: %w", err)
-		}
-	default:
-		utf8Content = string(rawContent)
+	utf8Content, err := decodeText(rawContent)
+	if err != nil {
+		return "", err
 	}
+	return strings.ReplaceAll(utf8Content, "\r\n", "\n"), nil
+}
 
-	chunks, err := p.textSplitter.Split(utf8Content)
-	if err != nil {
-		return nil, fmt.Errorf("failed to split text content: %w", err)
+// decodeText 将文本转换为 UTF-8
+// 不是合法 UTF-8 时优先按 GBK(GB18030) 解码，出现无法识别的字节时再按 charset 检测的编码解码
+func decodeText(raw []byte) (string, error) {
+	if utf8.Valid(raw) {
+		return string(raw), nil
 	}
 
-	var documents []*Document
-	for _, chunk := range chunks {
-		documents = append(documents, &Document{
-			Content: chunk,
-			Metadata: Metadata{
-				Filename:    filepath.Base(filePath),
-				ContentType: "text/plain",
-				Size:        int64(len(chunk)),
-			},
-		})
+	decoded, _, err := transform.String(simplifiedchinese.GB18030.NewDecoder(), string(raw))
+	if err == nil && !strings.ContainsRune(decoded, utf8.RuneError) {
+		return decoded, nil
 	}
 
-	return documents, nil
+	encoding, name, _ := charset.DetermineEncoding(raw, "")
+	decoded, _, err = transform.String(encoding.NewDecoder(), string(raw))
+	if err != nil {
+		return "", fmt.Errorf("failed to convert %s to UTF-8: %w", name, err)
+	}
+	return decoded, nil
 }
 
 func (p *TextParser) SupportedExtensions() []string {
@@ -1,15 +1,33 @@
 package document
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/infrastructure/msoffice"
 	"github.com/xuri/excelize/v2"
 )
 
+// TableFormat 表格行的输出格式
+type TableFormat string
+
+const (
+	TableRecords  TableFormat = "records"  // 每行输出为 "表头: 值; 表头: 值"
+	TableMarkdown TableFormat = "markdown" // 输出为 Markdown 表格，每个分块开头重复表头
+)
+
+// headerScanRows 在前若干行中查找表头
+const headerScanRows = 10
+
 type XLSParser struct {
 	textSplitter *TextSplitter
+	MaxSheets    int         // 最大工作表数，0表示不限制
+	Format       TableFormat // 为空时使用 TableRecords
 }
 
 func NewXLSParser(chunkSize, chunkOverlap int) *XLSParser {
@@ -19,56 +37,344 @@ func NewXLSParser(chunkSize, chunkOverlap int) *XLSParser {
 }
 
 func (p *XLSParser) Parse(filePath string) ([]*Document, error) {
+	return ParseWith(context.Background(), p, p.textSplitter, filePath)
+}
+
+// sheetTable 工作表的单元格文本，合并单元格已展开
+type sheetTable struct {
+	name   string
+	hidden bool
+	rows   [][]string
+}
+
+// ParseSections 每个可见的非空工作表作为一个章节，以工作表名称为标题
+// 检测到表头时每行按表头输出，跳过空行；字符偏移以输出的各行依次换行拼接计
+func (p *XLSParser) ParseSections(filePath string) (*ParsedDocument, error) {
+	sheets, err := p.readSheets(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if p.MaxSheets > 0 && len(sheets) > p.MaxSheets {
+		return nil, fmt.Errorf("%w: %d sheets (max %d)", ErrLimitExceeded, len(sheets), p.MaxSheets)
+	}
+
+	var b sectionBuilder
+	offset := 0
+	emit := func(text string, src Source, row bool) {
+		src.Offset = offset
+		if row {
+			b.Row(text, src)
+		} else {
+			b.Block(text, src)
+		}
+		offset += utf8.RuneCountInString(text) + 1
+	}
+
+	for _, sheet := range sheets {
+		if sheet.hidden || isEmptyTable(sheet.rows) {
+			continue
+		}
+		b.Heading(1, sheet.name)
+
+		header := detectHeader(sheet.rows)
+		var names []string
+		if header >= 0 {
+			names = headerNames(sheet.rows[header])
+			// 表头之前的标题行原样输出；Markdown 格式下与表头一起在每个分块开头重复
+			var titles []string
+			for i, row := range sheet.rows[:header] {
+				if isEmptyRow(row) {
+					continue
+				}
+				title := strings.Join(trimCells(row), "\t")
+				if p.Format == TableMarkdown {
+					titles = append(titles, title)
+				} else {
+					emit(title, Source{Sheet: sheet.name, Row: i + 1}, false)
+				}
+			}
+			if p.Format == TableMarkdown {
+				b.Preamble(strings.Join(append(titles, markdownHeader(names)), "\n"))
+			}
+		}
+
+		for i := header + 1; i < len(sheet.rows); i++ {
+			row := sheet.rows[i]
+			if isEmptyRow(row) {
+				continue
+			}
+			var text string
+			switch {
+			case header < 0:
+				text = strings.Join(trimCells(row), "\t")
+			case p.Format == TableMarkdown:
+				text = markdownRow(row, len(names))
+			default:
+				text = recordRow(row, names)
+			}
+			emit(text, Source{Sheet: sheet.name, Row: i + 1}, true)
+		}
+	}
+	return &ParsedDocument{ContentType: p.getContentType(filePath), Sections: b.Sections()}, nil
+}
+
+// CheckLimits 只读取工作表列表，在解析前校验 .xlsx 的工作表数上限
+// .xls 需要完整解析才能得到工作表，由 ParseSections 校验
+func (p *XLSParser) CheckLimits(filePath string) error {
+	if p.MaxSheets <= 0 || hasMagic(filePath, cfbMagic) {
+		return nil
+	}
 	f, err := excelize.OpenFile(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open Excel file: %w", err)
+		return fmt.Errorf("failed to open Excel file: %w", err)
 	}
 	defer f.Close()
+	if n := len(f.GetSheetList()); n > p.MaxSheets {
+		return fmt.Errorf("%w: %d sheets (max %d)", ErrLimitExceeded, n, p.MaxSheets)
+	}
+	return nil
+}
 
-	var contentBuilder strings.Builder
+// readSheets 按文件内容而不是扩展名选择格式：复合文档为 BIFF8 (.xls)，其余使用 excelize 读取
+func (p *XLSParser) readSheets(filePath string) ([]sheetTable, error) {
+	if hasMagic(filePath, cfbMagic) {
+		return readBIFFSheets(filePath)
+	}
+	return readXLSXSheets(filePath)
+}
 
-	// 处理所有工作表
-	for _, sheet := range f.GetSheetList() {
-		rows, err := f.GetRows(sheet)
+func readXLSXSheets(filePath string) ([]sheetTable, error) {
+	f, err := excelize.OpenFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Excel file: %w", err)
+	}
+	defer f.Close()
+
+	var sheets []sheetTable
+	for _, name := range f.GetSheetList() {
+		visible, err := f.GetSheetVisible(name)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get rows from sheet %s: %w", sheet, err)
+			return nil, fmt.Errorf("failed to get visibility of sheet %s: %w", name, err)
+		}
+		if !visible {
+			sheets = append(sheets, sheetTable{name: name, hidden: true})
+			continue
 		}
 
-		for i, row := range rows {
-			var rowContent []string
-			for _, cell := range row {
-				rowContent = append(rowContent, strings.TrimSpace(cell))
+		// GetRows 返回公式的缓存结果
+		rows, err := f.GetRows(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get rows from sheet %s: %w", name, err)
+		}
+		fillFormulaValues(f, name, rows)
+		table := sheetTable{name: name, rows: rows}
+		merges, err := f.GetMergeCells(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get merged cells of sheet %s: %w", name, err)
+		}
+		for _, m := range merges {
+			firstCol, firstRow, err := excelize.CellNameToCoordinates(m.GetStartAxis())
+			if err != nil {
+				continue
 			}
-			contentBuilder.WriteString(strings.Join(rowContent, "\t"))
-			contentBuilder.WriteString("\n")
+			lastCol, lastRow, err := excelize.CellNameToCoordinates(m.GetEndAxis())
+			if err != nil {
+				continue
+			}
+			table.rows = fillMerged(table.rows, msoffice.CellRange{
+				FirstRow: firstRow - 1, LastRow: lastRow - 1,
+				FirstCol: firstCol - 1, LastCol: lastCol - 1,
+			}, m.GetCellValue())
+		}
+		sheets = append(sheets, table)
+	}
+	return sheets, nil
+}
 
-			// 每处理100行添加一个分隔符
-			if (i+1)%100 == 0 {
-				contentBuilder.WriteString("\n\n")
+// fillFormulaValues 没有缓存结果的公式单元格(如由程序生成、未经 Excel 保存的文件)重新计算
+func fillFormulaValues(f *excelize.File, sheet string, rows [][]string) {
+	for r, row := range rows {
+		for c, value := range row {
+			if value != "" {
+				continue
+			}
+			cell, err := excelize.CoordinatesToCellName(c+1, r+1)
+			if err != nil {
+				continue
+			}
+			if formula, _ := f.GetCellFormula(sheet, cell); formula == "" {
+				continue
+			}
+			if v, err := f.CalcCellValue(sheet, cell); err == nil {
+				row[c] = v
 			}
 		}
-		contentBuilder.WriteString("\n\n") // 工作表分隔
 	}
+}
+
+func readBIFFSheets(filePath string) ([]sheetTable, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Excel file: %w", err)
+	}
+	defer file.Close()
 
-	fullContent := contentBuilder.String()
-	chunks, err := p.textSplitter.Split(fullContent)
+	books, err := msoffice.ReadXLS(file)
 	if err != nil {
-		return nil, fmt.Errorf("failed to split Excel content: %w", err)
+		return nil, fmt.Errorf("failed to read legacy Excel file: %w", err)
 	}
+	sheets := make([]sheetTable, 0, len(books))
+	for _, s := range books {
+		table := sheetTable{name: s.Name, hidden: s.Hidden, rows: s.Rows}
+		for _, m := range s.Merges {
+			value := ""
+			if m.FirstRow < len(s.Rows) && m.FirstCol < len(s.Rows[m.FirstRow]) {
+				value = s.Rows[m.FirstRow][m.FirstCol]
+			}
+			table.rows = fillMerged(table.rows, m, value)
+		}
+		sheets = append(sheets, table)
+	}
+	return sheets, nil
+}
 
-	var documents []*Document
-	for _, chunk := range chunks {
-		documents = append(documents, &Document{
-			Content: chunk,
-			Metadata: Metadata{
-				Filename:    filepath.Base(filePath),
-				ContentType: p.getContentType(filePath),
-				Size:        int64(len(chunk)),
-			},
-		})
+// fillMerged 将合并区域左上角的值填入区域内的每个单元格，只填充已有内容的行范围内
+func fillMerged(rows [][]string, m msoffice.CellRange, value string) [][]string {
+	if value == "" {
+		return rows
 	}
+	for r := m.FirstRow; r <= m.LastRow && r < len(rows); r++ {
+		for len(rows[r]) <= m.LastCol {
+			rows[r] = append(rows[r], "")
+		}
+		for c := m.FirstCol; c <= m.LastCol; c++ {
+			rows[r][c] = value
+		}
+	}
+	return rows
+}
+
+// detectHeader 返回表头所在行的下标，没有表头时返回 -1
+// 前 headerScanRows 行中，跳过只有一个单元格的标题行，第一个多单元格的行全部为非数值文本且其后还有数据时视为表头
+func detectHeader(rows [][]string) int {
+	for i := 0; i < len(rows) && i < headerScanRows; i++ {
+		cells := trimCells(rows[i])
+		filled := 0
+		for _, cell := range cells {
+			if cell != "" {
+				filled++
+			}
+		}
+		if filled < 2 {
+			continue
+		}
+		for _, cell := range cells {
+			if cell == "" {
+				continue
+			}
+			if isNumeric(cell) {
+				return -1
+			}
+		}
+		for _, row := range rows[i+1:] {
+			if !isEmptyRow(row) {
+				return i
+			}
+		}
+		return -1
+	}
+	return -1
+}
+
+// headerNames 表头单元格文本，空表头以列名(A、B、C...)代替
+func headerNames(row []string) []string {
+	names := trimCells(row)
+	for i, name := range names {
+		if name == "" {
+			names[i] = columnName(i)
+		}
+	}
+	return names
+}
+
+// recordRow 输出为 "表头: 值; 表头: 值"，跳过空单元格，超出表头的列以列名代替
+func recordRow(row []string, names []string) string {
+	var fields []string
+	for i, cell := range trimCells(row) {
+		if cell == "" {
+			continue
+		}
+		name := columnName(i)
+		if i < len(names) {
+			name = names[i]
+		}
+		fields = append(fields, name+": "+cell)
+	}
+	return strings.Join(fields, "; ")
+}
+
+func markdownHeader(names []string) string {
+	sep := make([]string, len(names))
+	for i := range sep {
+		sep[i] = "---"
+	}
+	return markdownLine(names) + "\n" + markdownLine(sep)
+}
+
+// markdownRow 输出为 Markdown 表格行，列数与表头一致，超出的列并入最后一列
+func markdownRow(row []string, width int) string {
+	cells := trimCells(row)
+	if len(cells) > width {
+		cells = append(cells[:width-1], strings.Join(cells[width-1:], " "))
+	}
+	for len(cells) < width {
+		cells = append(cells, "")
+	}
+	return markdownLine(cells)
+}
+
+func markdownLine(cells []string) string {
+	escaped := make([]string, len(cells))
+	for i, cell := range cells {
+		escaped[i] = strings.ReplaceAll(cell, "|", `\|`)
+	}
+	return "| " + strings.Join(escaped, " | ") + " |"
+}
+
+// trimCells 去掉单元格首尾空白，单元格内的换行替换为空格，并去掉行尾的空单元格
+func trimCells(row []string) []string {
+	cells := make([]string, len(row))
+	for i, cell := range row {
+		cells[i] = strings.Join(strings.Fields(cell), " ")
+	}
+	for len(cells) > 0 && cells[len(cells)-1] == "" {
+		cells = cells[:len(cells)-1]
+	}
+	return cells
+}
+
+func isEmptyRow(row []string) bool {
+	return len(trimCells(row)) == 0
+}
+
+func isEmptyTable(rows [][]string) bool {
+	for _, row := range rows {
+		if !isEmptyRow(row) {
+			return false
+		}
+	}
+	return true
+}
+
+func isNumeric(s string) bool {
+	s = strings.NewReplacer(",", "", "%", "", "$", "", "¥", "", "￥", "").Replace(s)
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
 
-	return documents, nil
+func columnName(i int) string {
+	name, _ := excelize.ColumnNumberToName(i + 1)
+	return name
 }
 
 func (p *XLSParser) getContentType(filePath string) string {
@@ -1,6 +1,10 @@
 package embedding
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"sort"
+)
 
 type Embedding struct {
 	Vector []float32
@@ -11,3 +15,34 @@ type Embedder interface {
 	Embed(context.Context, string) (*Embedding, error)
 	EmbedBatch(context.Context, []string) ([]*Embedding, error)
 }
+
+// BatchError 表示批量嵌入中部分文本失败，Failures 以文本在输入中的下标为键
+// EmbedBatch 返回 BatchError 时，成功的下标对应的 Embedding 仍然有效，失败的为 nil
+type BatchError struct {
+	Total    int
+	Failures map[int]error
+}
+
+func (e *BatchError) Error() string {
+	// 取下标最小的错误作为示例，避免输出过长
+	first := -1
+	for idx := range e.Failures {
+		if first == -1 || idx < first {
+			first = idx
+		}
+	}
+	if first == -1 {
+		return fmt.Sprintf("failed to embed 0 of %d texts", e.Total)
+	}
+	return fmt.Sprintf("failed to embed %d of %d texts (text %d: %v)", len(e.Failures), e.Total, first, e.Failures[first])
+}
+
+// FailedIndexes 返回失败文本的下标，按升序排列
+func (e *BatchError) FailedIndexes() []int {
+	indexes := make([]int, 0, len(e.Failures))
+	for idx := range e.Failures {
+		indexes = append(indexes, idx)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
@@ -0,0 +1,65 @@
+package job
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotFound 任务不存在
+var ErrNotFound = errors.New("job not found")
+
+// State 入库任务状态
+type State string
+
+const (
+	StateQueued    State = "queued"
+	StateParsing   State = "parsing"
+	StateEmbedding State = "embedding"
+	StateStoring   State = "storing"
+	StateDone      State = "done"
+	StateFailed    State = "failed"
+	StateCancelled State = "cancelled"
+)
+
+// Terminal 任务是否已结束
+func (s State) Terminal() bool {
+	return s == StateDone || s == StateFailed || s == StateCancelled
+}
+
+// Job 文档入库任务，上传文件暂存在磁盘上，由后台工作协程处理
+type Job struct {
+	ID              string     `json:"id"`
+	KnowledgeBaseID string     `json:"kb_id"`
+	DocumentID      string     `json:"document_id"`
+	Replace         bool       `json:"replace,omitempty"` // 替换已有文档的分块
+	Filename        string     `json:"filename"`
+	Size            int64      `json:"size"`
+	ContentType     string     `json:"content_type,omitempty"` // 按内容识别的 MIME 类型
+	Archive         string     `json:"archive,omitempty"`      // 从归档中展开时为归档文件名
+	ArchivePath     string     `json:"archive_path,omitempty"` // 文件在归档内的路径
+	Uploader        string     `json:"uploader,omitempty"`
+	State           State      `json:"state"`
+	Chunks          int        `json:"chunks"`
+	Summary         *Summary   `json:"summary,omitempty"`
+	Error           string     `json:"error,omitempty"` // 失败原因，分块数或 .xls 工作表数超出上限时以 "document exceeds processing limits" 开头
+	CreatedAt       time.Time  `json:"created_at"`
+	StartedAt       *time.Time `json:"started_at,omitempty"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty"`
+}
+
+// Summary 入库结果：新嵌入写入、沿用原向量及删除的分块数
+type Summary struct {
+	Added     int  `json:"added"`
+	Unchanged int  `json:"unchanged"`
+	Removed   int  `json:"removed"`
+	Duplicate bool `json:"duplicate,omitempty"` // 知识库中已有相同文件，未重复入库
+}
+
+type Repository interface {
+	// Save 新增或覆盖任务
+	Save(job *Job) error
+	// FindByID 查找任务，不存在时返回 ErrNotFound
+	FindByID(id string) (*Job, error)
+	// List 按创建时间返回全部任务
+	List() ([]*Job, error)
+}
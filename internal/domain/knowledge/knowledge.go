@@ -1,22 +1,38 @@
 package knowledge
 
 import (
+	"errors"
 	"time"
+)
+
+// DefaultID 默认知识库ID，未指定知识库的上传和查询均落在默认知识库
+const DefaultID = "default"
 
-	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/document"
+var (
+	// ErrNotFound 知识库不存在
+	ErrNotFound = errors.New("knowledge base not found")
+	// ErrDuplicateName 知识库名称已存在
+	ErrDuplicateName = errors.New("knowledge base name already exists")
+	// ErrDefaultImmutable 默认知识库不可删除
+	ErrDefaultImmutable = errors.New("default knowledge base cannot be deleted")
 )
 
 type KnowledgeBase struct {
-	ID          string
-	Name        string
-	Description string
-	Documents   []*document.Document
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Splitter    string    `json:"splitter,omitempty"` // 分块器 fixed/semantic，为空时为 fixed，修改后只影响之后上传的文档
+	DocumentIDs []string  `json:"document_ids"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
 }
 
 type KnowledgeRepository interface {
-	AddDocument(kbID string, doc *document.Document) error
+	Create(kb *KnowledgeBase) error
+	Update(kb *KnowledgeBase) error
+	Delete(kbID string) error
+	List() ([]*KnowledgeBase, error)
+	AddDocument(kbID string, docID string) error
 	RemoveDocument(kbID string, docID string) error
 	FindByID(kbID string) (*KnowledgeBase, error)
 }
@@ -0,0 +1,126 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrRateLimited 请求被模型服务限流
+	ErrRateLimited = errors.New("llm rate limit exceeded")
+	// ErrContextLengthExceeded 提示词超出模型上下文长度
+	ErrContextLengthExceeded = errors.New("llm context length exceeded")
+	// ErrUnauthorized API Key 无效或无权限
+	ErrUnauthorized = errors.New("llm authentication failed")
+	// ErrModelNotFound 未注册的模型名称
+	ErrModelNotFound = errors.New("llm model not found")
+)
+
+// Message 对话消息
+type Message struct {
+	Role    string `json:"role"` // system/user/assistant
+	Content string `json:"content"`
+}
+
+// Usage token 用量统计
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// ChatResponse 一次对话补全的结果
+type ChatResponse struct {
+	Content      string
+	Model        string
+	FinishReason string
+	Usage        Usage
+}
+
+// StreamChunk 流式输出的一个增量片段
+// 正常结束时最后一个片段 Done 为 true 并携带 Usage(服务端提供时)
+// 出错时 Err 非空，之后通道关闭
+type StreamChunk struct {
+	Content      string
+	FinishReason string
+	Usage        *Usage
+	Done         bool
+	Err          error
+}
+
+// ModelInfo 描述模型及其提供方
+type ModelInfo struct {
+	Name     string `json:"name"`     // 配置中的模型名称
+	Provider string `json:"provider"` // openai/ollama/echo
+	Model    string `json:"model"`    // 提供方的模型标识
+}
+
+// ChatModel 定义对话模型接口，不同提供方通过适配器实现
+type ChatModel interface {
+	// Chat 一次性返回完整回答
+	Chat(ctx context.Context, messages []Message) (*ChatResponse, error)
+	// ChatStream 逐段返回回答，ctx 取消时中断上游请求并关闭通道
+	ChatStream(ctx context.Context, messages []Message) (<-chan StreamChunk, error)
+	// Info 返回模型信息
+	Info() ModelInfo
+}
+
+// Registry 按名称管理多个对话模型，调用方可按请求选择模型
+type Registry struct {
+	mu          sync.RWMutex
+	models      map[string]ChatModel
+	defaultName string
+}
+
+func NewRegistry(defaultName string) *Registry {
+	return &Registry{
+		models:      make(map[string]ChatModel),
+		defaultName: defaultName,
+	}
+}
+
+// Register 注册模型，同名模型会被覆盖；未设置默认模型时第一个注册的模型成为默认
+func (r *Registry) Register(name string, model ChatModel) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.models[name] = model
+	if r.defaultName == "" {
+		r.defaultName = name
+	}
+}
+
+// Get 按名称获取模型，name 为空时返回默认模型
+func (r *Registry) Get(name string) (ChatModel, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if name == "" {
+		name = r.defaultName
+	}
+	model, ok := r.models[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrModelNotFound, name)
+	}
+	return model, nil
+}
+
+// Default 返回默认模型名称
+func (r *Registry) Default() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.defaultName
+}
+
+// Models 返回所有已注册模型的信息，按名称排序
+func (r *Registry) Models() []ModelInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	infos := make([]ModelInfo, 0, len(r.models))
+	for _, model := range r.models {
+		infos = append(infos, model.Info())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
@@ -2,9 +2,10 @@ package query
 
 import (
 	"context"
+	"strings"
 
 	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/document"
-	deepseek "github.com/redhander/AIKnowledgeBaseMiddleware/internal/infrastructure/llm"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/llm"
 )
 
 // Query 定义用户查询的参数
@@ -12,6 +13,9 @@ type Query struct {
 	Text      string
 	Embedding []float32
 	TopK      int
+	Model     string // 使用的模型名称，为空时使用默认模型
+	// KnowledgeBaseID 检索的知识库，为空时使用默认知识库
+	KnowledgeBaseID string
 }
 
 // QueryResult 定义查询返回结果
@@ -21,51 +25,102 @@ type QueryResult struct {
 	Metadata map[string]interface{}
 }
 
+// QueryStream 定义流式查询结果：检索来源立即可用，回答通过 Chunks 逐段推送
+type QueryStream struct {
+	Sources []*document.Document
+	Chunks  <-chan llm.StreamChunk
+	Model   string
+}
+
 // QueryService 定义查询服务接口
 type QueryService interface {
 	Execute(ctx context.Context, query *Query) (*QueryResult, error)
 }
 
+// StreamingQueryService 定义支持流式回答的查询服务接口
+type StreamingQueryService interface {
+	QueryService
+	ExecuteStream(ctx context.Context, query *Query) (*QueryStream, error)
+}
+
 // ===== 以下是 RAG 查询服务实现 =====
 
 // RAGQueryService 是基于检索增强生成的查询服务实现
 type RAGQueryService struct {
-	LLM  *deepseek.Client
-	Repo document.DocumentRepository
+	Models *llm.Registry
+	Repo   document.DocumentRepository
 }
 
 // NewRAGQueryService 创建一个新的 RAG 查询服务实例
-func NewRAGQueryService(client *deepseek.Client, repo document.DocumentRepository) QueryService {
+func NewRAGQueryService(models *llm.Registry, repo document.DocumentRepository) StreamingQueryService {
 	return &RAGQueryService{
-		LLM:  client,
-		Repo: repo,
+		Models: models,
+		Repo:   repo,
 	}
 }
 
 // Execute 实现 QueryService 接口的执行方法
 func (s *RAGQueryService) Execute(ctx context.Context, q *Query) (*QueryResult, error) {
-	// 示例逻辑：搜索相关文档
-	docs, err := s.Repo.Search(ctx, q.Embedding, q.TopK)
+	model, err := s.Models.Get(q.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	// 搜索相关文档
+	docs, err := s.Repo.Search(ctx, q.KnowledgeBaseID, q.Embedding, q.TopK)
 	if err != nil {
 		return nil, err
 	}
 
 	// 拼接上下文并调用 LLM 生成回答
-	var contextText string
-	for _, doc := range docs {
-		contextText += doc.Content + "\n"
+	resp, err := model.Chat(ctx, buildMessages(q.Text, docs))
+	if err != nil {
+		return nil, err
 	}
 
-	prompt := "根据以下信息回答问题：\n" + contextText + "\n问题：" + q.Text
+	return &QueryResult{
+		Answer:  resp.Content,
+		Sources: docs,
+		Metadata: map[string]interface{}{
+			"model": model.Info().Name,
+			"usage": resp.Usage,
+		},
+	}, nil
+}
 
-	answer, err := s.LLM.Generate(ctx, prompt)
+// ExecuteStream 检索相关文档后以流式方式生成回答
+func (s *RAGQueryService) ExecuteStream(ctx context.Context, q *Query) (*QueryStream, error) {
+	model, err := s.Models.Get(q.Model)
 	if err != nil {
 		return nil, err
 	}
 
-	return &QueryResult{
-		Answer:   answer,
-		Sources:  docs,
-		Metadata: map[string]interface{}{"model": s.LLM.Model()},
+	docs, err := s.Repo.Search(ctx, q.KnowledgeBaseID, q.Embedding, q.TopK)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks, err := model.ChatStream(ctx, buildMessages(q.Text, docs))
+	if err != nil {
+		return nil, err
+	}
+
+	return &QueryStream{
+		Sources: docs,
+		Chunks:  chunks,
+		Model:   model.Info().Name,
 	}, nil
 }
+
+// buildMessages 将检索到的文档拼接为上下文提示词
+func buildMessages(question string, docs []*document.Document) []llm.Message {
+	var contextText strings.Builder
+	for _, doc := range docs {
+		// 标注出处，便于回答引用页码或行号
+		contextText.WriteString("[" + doc.Metadata.Citation() + "]\n")
+		contextText.WriteString(doc.TextWithHeadings())
+		contextText.WriteString("\n")
+	}
+	prompt := "根据以下信息回答问题：\n" + contextText.String() + "\n问题：" + question
+	return []llm.Message{{Role: "user", Content: prompt}}
+}
@@ -0,0 +1,276 @@
+// Package archive 遍历 zip、tar 和 tar.gz 归档中的文件，防范解压炸弹、路径穿越和过深的嵌套
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+var (
+	// ErrLimitExceeded 条目数、解压后大小或压缩比超出限制，整个归档被拒绝
+	ErrLimitExceeded = errors.New("archive exceeds extraction limits")
+	// ErrUnsupportedFormat 不是 zip、tar 或 tar.gz 归档，或归档已损坏
+	ErrUnsupportedFormat = errors.New("unsupported archive format")
+	// ErrUnsafePath 条目路径为绝对路径或包含 ".."
+	ErrUnsafePath = errors.New("unsafe path in archive")
+	// ErrNotRegular 条目是符号链接、硬链接或设备文件
+	ErrNotRegular = errors.New("not a regular file")
+	// ErrNestingTooDeep 嵌套归档超过最大层数
+	ErrNestingTooDeep = errors.New("archive nesting too deep")
+)
+
+// Limits 解压上限，0 表示不限制
+type Limits struct {
+	MaxEntries   int   // 文件条目数(包括嵌套归档中的条目)
+	MaxTotalSize int64 // 解压后的总字节数
+	MaxEntrySize int64 // 单个条目解压后的字节数
+	MaxDepth     int   // 嵌套归档的最大层数，0 表示不展开嵌套归档
+	MaxRatio     int   // zip 条目的最大压缩比
+}
+
+// Entry 归档中的一个文件，Err 非空时表示该条目被跳过的原因，此时不可读取
+type Entry struct {
+	// Path 归档内的路径，嵌套归档中的条目以归档自身的路径为前缀，如 "docs/a.zip/b.txt"
+	Path string
+	Err  error
+	r    io.Reader
+}
+
+func (e *Entry) Read(p []byte) (int, error) {
+	if e.r == nil {
+		return 0, e.Err
+	}
+	return e.r.Read(p)
+}
+
+var (
+	zipMagic  = []byte("PK\x03\x04")
+	gzipMagic = []byte{0x1f, 0x8b}
+	tarMagic  = []byte("ustar") // 位于偏移 257
+)
+
+// IsArchive 按文件名判断是否为支持的归档格式
+func IsArchive(name string) bool {
+	name = strings.ToLower(name)
+	for _, ext := range []string{".zip", ".tar", ".tgz", ".tar.gz"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// Walk 按顺序对归档中的每个文件调用 fn，目录和 macOS 生成的 __MACOSX、._* 文件被忽略
+// 名称为归档的条目在 MaxDepth 以内时展开，否则以 ErrNestingTooDeep 跳过；嵌套归档损坏时以 ErrUnsupportedFormat 跳过
+// 超出条目数、总大小或压缩比限制时停止遍历并返回 ErrLimitExceeded，fn 返回错误时停止遍历并返回该错误
+func Walk(r io.ReaderAt, size int64, limits Limits, fn func(*Entry) error) error {
+	w := &walker{limits: limits, fn: fn}
+	return w.walk(r, size, "", 0)
+}
+
+type walker struct {
+	limits  Limits
+	fn      func(*Entry) error
+	entries int
+	total   int64
+}
+
+func (w *walker) walk(r io.ReaderAt, size int64, prefix string, depth int) error {
+	header := make([]byte, 262)
+	n, _ := r.ReadAt(header, 0)
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, zipMagic):
+		return w.walkZip(r, size, prefix, depth)
+	case bytes.HasPrefix(header, gzipMagic):
+		gz, err := gzip.NewReader(io.NewSectionReader(r, 0, size))
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+		}
+		defer gz.Close()
+		return w.walkTar(gz, prefix, depth)
+	case len(header) == 262 && bytes.Equal(header[257:262], tarMagic):
+		return w.walkTar(io.NewSectionReader(r, 0, size), prefix, depth)
+	}
+	return ErrUnsupportedFormat
+}
+
+func (w *walker) walkZip(r io.ReaderAt, size int64, prefix string, depth int) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	}
+	for _, f := range zr.File {
+		mode := f.Mode()
+		if mode.IsDir() || ignored(f.Name) {
+			continue
+		}
+		if !mode.IsRegular() {
+			if err := w.skip(prefix+f.Name, ErrNotRegular); err != nil {
+				return err
+			}
+			continue
+		}
+		// 先按文件头中的大小拒绝，实际读取时仍按读出的字节数校验
+		if err := w.checkDeclared(f); err != nil {
+			return err
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrUnsupportedFormat, f.Name, err)
+		}
+		err = w.entry(f.Name, rc, prefix, depth)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkDeclared 按 zip 文件头中的大小校验单个条目大小和压缩比
+func (w *walker) checkDeclared(f *zip.File) error {
+	if w.limits.MaxEntrySize > 0 && f.UncompressedSize64 > uint64(w.limits.MaxEntrySize) {
+		return fmt.Errorf("%w: %s is %d bytes uncompressed (max %d)", ErrLimitExceeded, f.Name, f.UncompressedSize64, w.limits.MaxEntrySize)
+	}
+	if w.limits.MaxRatio > 0 && f.CompressedSize64 > 0 && f.UncompressedSize64/f.CompressedSize64 > uint64(w.limits.MaxRatio) {
+		return fmt.Errorf("%w: %s has compression ratio %d (max %d)", ErrLimitExceeded, f.Name, f.UncompressedSize64/f.CompressedSize64, w.limits.MaxRatio)
+	}
+	return nil
+}
+
+func (w *walker) walkTar(r io.Reader, prefix string, depth int) error {
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+		}
+		switch h.Typeflag {
+		case tar.TypeDir, tar.TypeXGlobalHeader:
+			continue
+		case tar.TypeReg:
+		default:
+			if err := w.skip(prefix+h.Name, ErrNotRegular); err != nil {
+				return err
+			}
+			continue
+		}
+		if ignored(h.Name) {
+			continue
+		}
+		if w.limits.MaxEntrySize > 0 && h.Size > w.limits.MaxEntrySize {
+			return fmt.Errorf("%w: %s is %d bytes (max %d)", ErrLimitExceeded, h.Name, h.Size, w.limits.MaxEntrySize)
+		}
+		if err := w.entry(h.Name, tr, prefix, depth); err != nil {
+			return err
+		}
+	}
+}
+
+// entry 校验路径后将条目交给 fn，嵌套归档写入临时文件后展开
+func (w *walker) entry(name string, r io.Reader, prefix string, depth int) error {
+	clean, ok := cleanPath(name)
+	if !ok {
+		return w.skip(prefix+name, ErrUnsafePath)
+	}
+	if err := w.count(); err != nil {
+		return err
+	}
+	entryPath := prefix + clean
+	limited := &limitReader{r: r, w: w, name: entryPath}
+
+	if !IsArchive(clean) {
+		return w.fn(&Entry{Path: entryPath, r: limited})
+	}
+	if depth >= w.limits.MaxDepth {
+		return w.fn(&Entry{Path: entryPath, Err: ErrNestingTooDeep})
+	}
+	tmp, err := os.CreateTemp("", "archive-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+	size, err := io.Copy(tmp, limited)
+	if err != nil {
+		return err
+	}
+	err = w.walk(tmp, size, entryPath+"/", depth+1)
+	// 嵌套归档损坏或格式不支持时只跳过该条目，超出限制和 fn 返回的错误仍停止遍历
+	if errors.Is(err, ErrUnsupportedFormat) {
+		return w.fn(&Entry{Path: entryPath, Err: err})
+	}
+	return err
+}
+
+func (w *walker) skip(name string, reason error) error {
+	if err := w.count(); err != nil {
+		return err
+	}
+	return w.fn(&Entry{Path: name, Err: reason})
+}
+
+func (w *walker) count() error {
+	w.entries++
+	if w.limits.MaxEntries > 0 && w.entries > w.limits.MaxEntries {
+		return fmt.Errorf("%w: more than %d entries", ErrLimitExceeded, w.limits.MaxEntries)
+	}
+	return nil
+}
+
+// limitReader 按实际读出的字节数校验单个条目和归档总大小，不依赖文件头中可能伪造的大小
+type limitReader struct {
+	r    io.Reader
+	w    *walker
+	name string
+	n    int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	l.w.total += int64(n)
+	limits := l.w.limits
+	if limits.MaxEntrySize > 0 && l.n > limits.MaxEntrySize {
+		return n, fmt.Errorf("%w: %s exceeds %d bytes", ErrLimitExceeded, l.name, limits.MaxEntrySize)
+	}
+	if limits.MaxTotalSize > 0 && l.w.total > limits.MaxTotalSize {
+		return n, fmt.Errorf("%w: more than %d bytes uncompressed", ErrLimitExceeded, limits.MaxTotalSize)
+	}
+	return n, err
+}
+
+// cleanPath 统一分隔符并规范化路径，绝对路径、盘符和包含 ".." 的路径视为不安全
+func cleanPath(name string) (string, bool) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if name == "" || strings.HasPrefix(name, "/") || (len(name) >= 2 && name[1] == ':') {
+		return "", false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", false
+		}
+	}
+	return path.Clean(name), true
+}
+
+// ignored macOS 压缩时附带的资源文件
+func ignored(name string) bool {
+	name = strings.ReplaceAll(name, "\\", "/")
+	return strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(path.Base(name), "._") || path.Base(name) == ".DS_Store"
+}
@@ -0,0 +1,326 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// walked 遍历得到的一个条目，content 为读出的内容
+type walked struct {
+	path    string
+	content string
+	err     error
+}
+
+func walkAll(t *testing.T, data []byte, limits Limits) ([]walked, error) {
+	t.Helper()
+	var got []walked
+	err := Walk(bytes.NewReader(data), int64(len(data)), limits, func(e *Entry) error {
+		if e.Err != nil {
+			got = append(got, walked{path: e.Path, err: e.Err})
+			return nil
+		}
+		// 与暂存条目时一样用 io.Copy 读取
+		var content strings.Builder
+		if _, err := io.Copy(&content, e); err != nil {
+			return err
+		}
+		got = append(got, walked{path: e.Path, content: content.String()})
+		return nil
+	})
+	return got, err
+}
+
+type file struct {
+	name    string
+	content string
+}
+
+func zipOf(t *testing.T, files ...file) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, f.content)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarOf(t *testing.T, headers ...*tar.Header) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, h := range headers {
+		content := h.Linkname
+		if h.Typeflag == tar.TypeReg {
+			h.Linkname = ""
+			h.Size = int64(len(content))
+		}
+		if h.Mode == 0 {
+			h.Mode = 0o644
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if h.Typeflag == tar.TypeReg {
+			io.WriteString(tw, content)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// regular 普通文件的 tar 头，内容暂存在 Linkname 中由 tarOf 写出
+func regular(name, content string) *tar.Header {
+	return &tar.Header{Name: name, Typeflag: tar.TypeReg, Linkname: content}
+}
+
+func TestWalkZipAndTarGz(t *testing.T) {
+	want := []walked{{path: "docs/a.txt", content: "alpha"}, {path: "b.md", content: "# beta"}}
+
+	data := zipOf(t, file{"docs/", ""}, file{"docs/a.txt", "alpha"}, file{"__MACOSX/docs/._a.txt", "junk"}, file{"b.md", "# beta"})
+	got, err := walkAll(t, data, Limits{})
+	if err != nil {
+		t.Fatalf("Walk zip: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("zip entries = %+v, want %+v", got, want)
+	}
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(tarOf(t, &tar.Header{Name: "docs/", Typeflag: tar.TypeDir, Mode: 0o755}, regular("docs/a.txt", "alpha"), regular("b.md", "# beta")))
+	zw.Close()
+	got, err = walkAll(t, gz.Bytes(), Limits{})
+	if err != nil {
+		t.Fatalf("Walk tar.gz: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tar.gz entries = %+v, want %+v", got, want)
+	}
+}
+
+func TestWalkRejectsUnknownFormat(t *testing.T) {
+	if _, err := walkAll(t, []byte("just some text"), Limits{}); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatalf("err = %v, want ErrUnsupportedFormat", err)
+	}
+}
+
+func TestWalkSkipsUnsafePaths(t *testing.T) {
+	data := tarOf(t,
+		regular("../evil.txt", "x"),
+		regular("/etc/passwd", "x"),
+		regular(`C:\Windows\evil.txt`, "x"),
+		regular("a/../../evil.txt", "x"),
+		regular("ok/./a.txt", "fine"),
+	)
+	got, err := walkAll(t, data, Limits{})
+	if err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+	want := []walked{
+		{path: "../evil.txt", err: ErrUnsafePath},
+		{path: "/etc/passwd", err: ErrUnsafePath},
+		{path: `C:\Windows\evil.txt`, err: ErrUnsafePath},
+		{path: "a/../../evil.txt", err: ErrUnsafePath},
+		{path: "ok/a.txt", content: "fine"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %+v, want %+v", got, want)
+	}
+}
+
+func TestWalkSkipsSymlinks(t *testing.T) {
+	data := tarOf(t,
+		&tar.Header{Name: "passwd", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"},
+		&tar.Header{Name: "hard", Typeflag: tar.TypeLink, Linkname: "a.txt"},
+		regular("a.txt", "alpha"),
+	)
+	got, err := walkAll(t, data, Limits{})
+	if err != nil {
+		t.Fatalf("Walk tar: %v", err)
+	}
+	want := []walked{{path: "passwd", err: ErrNotRegular}, {path: "hard", err: ErrNotRegular}, {path: "a.txt", content: "alpha"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tar entries = %+v, want %+v", got, want)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	h := &zip.FileHeader{Name: "link"}
+	h.SetMode(fs.ModeSymlink | 0o777)
+	w, _ := zw.CreateHeader(h)
+	io.WriteString(w, "/etc/passwd")
+	zw.Close()
+	got, err = walkAll(t, buf.Bytes(), Limits{})
+	if err != nil {
+		t.Fatalf("Walk zip: %v", err)
+	}
+	if want := []walked{{path: "link", err: ErrNotRegular}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("zip entries = %+v, want %+v", got, want)
+	}
+}
+
+func TestWalkNestingDepth(t *testing.T) {
+	inner := zipOf(t, file{"a.txt", "alpha"})
+	middle := zipOf(t, file{"inner.zip", string(inner)})
+	outer := zipOf(t, file{"docs/middle.zip", string(middle)}, file{"b.txt", "beta"})
+
+	tests := []struct {
+		depth int
+		want  []walked
+	}{
+		{0, []walked{{path: "docs/middle.zip", err: ErrNestingTooDeep}, {path: "b.txt", content: "beta"}}},
+		{1, []walked{{path: "docs/middle.zip/inner.zip", err: ErrNestingTooDeep}, {path: "b.txt", content: "beta"}}},
+		{2, []walked{{path: "docs/middle.zip/inner.zip/a.txt", content: "alpha"}, {path: "b.txt", content: "beta"}}},
+	}
+	for _, tt := range tests {
+		got, err := walkAll(t, outer, Limits{MaxDepth: tt.depth})
+		if err != nil {
+			t.Fatalf("MaxDepth %d: %v", tt.depth, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("MaxDepth %d: entries = %+v, want %+v", tt.depth, got, tt.want)
+		}
+	}
+}
+
+func TestWalkSkipsCorruptNestedArchive(t *testing.T) {
+	data := zipOf(t, file{"bad.zip", "not really a zip"}, file{"b.txt", "beta"})
+	got, err := walkAll(t, data, Limits{MaxDepth: 1})
+	if err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+	if len(got) != 2 || got[0].path != "bad.zip" || !errors.Is(got[0].err, ErrUnsupportedFormat) || got[1] != (walked{path: "b.txt", content: "beta"}) {
+		t.Errorf("entries = %+v, want bad.zip skipped with ErrUnsupportedFormat and b.txt read", got)
+	}
+}
+
+func TestWalkNestedLimitAbortsWalk(t *testing.T) {
+	inner := zipOf(t, file{"a.txt", "alpha"}, file{"b.txt", "beta"})
+	data := zipOf(t, file{"inner.zip", string(inner)})
+	if _, err := walkAll(t, data, Limits{MaxDepth: 1, MaxEntries: 2}); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("err = %v, want ErrLimitExceeded", err)
+	}
+}
+
+func TestWalkRejectsCompressionRatioBomb(t *testing.T) {
+	data := zipOf(t, file{"zeros.txt", strings.Repeat("\x00", 1<<20)})
+	if _, err := walkAll(t, data, Limits{MaxRatio: 100}); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("err = %v, want ErrLimitExceeded", err)
+	}
+	if _, err := walkAll(t, data, Limits{}); err != nil {
+		t.Fatalf("without MaxRatio: %v", err)
+	}
+}
+
+// lyingZip 返回一个文件头声明解压后只有 declared 字节、实际内容为 content 的 zip
+func lyingZip(t *testing.T, name, content string, declared uint64) []byte {
+	t.Helper()
+	var compressed bytes.Buffer
+	fw, _ := flate.NewWriter(&compressed, flate.BestCompression)
+	io.WriteString(fw, content)
+	fw.Close()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               name,
+		Method:             zip.Deflate,
+		CRC32:              crc32.ChecksumIEEE([]byte(content)),
+		CompressedSize64:   uint64(compressed.Len()),
+		UncompressedSize64: declared,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(compressed.Bytes())
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestWalkRejectsLyingZipHeader(t *testing.T) {
+	// 文件头声明的大小在限制以内，实际内容超出
+	data := lyingZip(t, "big.txt", strings.Repeat("a", 64<<10), 10)
+	_, err := walkAll(t, data, Limits{MaxEntrySize: 1 << 10})
+	if !errors.Is(err, ErrLimitExceeded) && !errors.Is(err, zip.ErrFormat) {
+		t.Fatalf("err = %v, want the entry rejected", err)
+	}
+}
+
+func TestLimitReaderCountsBytesActuallyRead(t *testing.T) {
+	// 不依赖文件头中的大小：底层读取器读出多少就计多少
+	w := &walker{limits: Limits{MaxEntrySize: 1000, MaxTotalSize: 1500}}
+	first := &limitReader{r: strings.NewReader(strings.Repeat("a", 800)), w: w, name: "a.txt"}
+	if _, err := io.Copy(io.Discard, first); err != nil {
+		t.Fatalf("first entry: %v", err)
+	}
+	big := &limitReader{r: strings.NewReader(strings.Repeat("b", 1200)), w: w, name: "b.txt"}
+	if _, err := io.Copy(io.Discard, big); !errors.Is(err, ErrLimitExceeded) || !strings.Contains(err.Error(), "b.txt") {
+		t.Fatalf("entry size: err = %v, want ErrLimitExceeded for b.txt", err)
+	}
+
+	w = &walker{limits: Limits{MaxTotalSize: 1500}}
+	for i, name := range []string{"a.txt", "b.txt"} {
+		l := &limitReader{r: strings.NewReader(strings.Repeat("x", 800)), w: w, name: name}
+		_, err := io.Copy(io.Discard, l)
+		if i == 0 && err != nil {
+			t.Fatalf("first entry: %v", err)
+		}
+		if i == 1 && !errors.Is(err, ErrLimitExceeded) {
+			t.Fatalf("total size: err = %v, want ErrLimitExceeded", err)
+		}
+	}
+}
+
+func TestWalkNestedArchiveCountsTowardsTotal(t *testing.T) {
+	inner := zipOf(t, file{"a.txt", strings.Repeat("a", 800)})
+	data := zipOf(t, file{"b.txt", strings.Repeat("b", 800)}, file{"inner.zip", string(inner)})
+	if _, err := walkAll(t, data, Limits{MaxDepth: 1, MaxTotalSize: 1500}); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("err = %v, want ErrLimitExceeded", err)
+	}
+}
+
+func TestWalkTotalSize(t *testing.T) {
+	var headers []*tar.Header
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		headers = append(headers, regular(name, strings.Repeat("x", 400)))
+	}
+	data := tarOf(t, headers...)
+
+	if _, err := walkAll(t, data, Limits{MaxEntrySize: 500, MaxTotalSize: 1000}); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("err = %v, want ErrLimitExceeded", err)
+	}
+	got, err := walkAll(t, data, Limits{MaxEntrySize: 500, MaxTotalSize: 1200})
+	if err != nil {
+		t.Fatalf("within limits: %v", err)
+	}
+	if len(got) != 3 {
+		t.Errorf("got %d entries, want 3", len(got))
+	}
+	if _, err := walkAll(t, data, Limits{MaxEntrySize: 300}); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("MaxEntrySize: err = %v, want ErrLimitExceeded", err)
+	}
+	if _, err := walkAll(t, data, Limits{MaxEntries: 2}); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("MaxEntries: err = %v, want ErrLimitExceeded", err)
+	}
+}
@@ -3,7 +3,11 @@ package config
 import (
 	"fmt"
 	"io/ioutil"
+	"math"
+	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v2"
@@ -13,10 +17,13 @@ import (
 type Config struct {
 	Server    ServerConfig    `yaml:"server"`
 	Logging   LoggingConfig   `yaml:"logging"`
+	Storage   StorageConfig   `yaml:"storage"`
 	Milvus    MilvusConfig    `yaml:"milvus"`
 	Embedding EmbeddingConfig `yaml:"embedding"`
 	DeepSeek  DeepSeekConfig  `yaml:"deepseek"`
+	LLM       LLMConfig       `yaml:"llm"`
 	Document  DocumentConfig  `yaml:"document"`
+	Ingestion IngestionConfig `yaml:"ingestion"`
 }
 
 // ServerConfig HTTP服务器配置
@@ -32,12 +39,28 @@ type LoggingConfig struct {
 	Console     bool   `yaml:"console"`     // 是否同时输出到控制台
 }
 
+// 向量存储后端
+const (
+	StorageBackendMilvus = "milvus"
+	StorageBackendMemory = "memory"
+)
+
+// StorageConfig 向量存储配置
+type StorageConfig struct {
+	Backend  string `yaml:"backend"`   // milvus/memory，默认 milvus
+	FilePath string `yaml:"file_path"` // memory 后端的快照文件路径，为空时仅保存在内存
+	Metric   string `yaml:"metric"`    // memory 后端的距离度量: L2/COSINE
+	// KnowledgeFile 知识库元数据文件，为空时仅保存在内存(重启后丢失)
+	KnowledgeFile string `yaml:"knowledge_file"`
+}
+
 // MilvusConfig Milvus向量数据库配置
 type MilvusConfig struct {
 	Address        string    `yaml:"address"`
 	Username       string    `yaml:"username"`
 	Password       string    `yaml:"password"`
 	CollectionName string    `yaml:"collection_name"`
+	Metric         string    `yaml:"metric"` // 向量距离度量: L2/IP/COSINE，默认 L2
 	TLS            TLSConfig `yaml:"tls"`
 }
 
@@ -49,26 +72,116 @@ type TLSConfig struct {
 
 // EmbeddingConfig 文本嵌入配置
 type EmbeddingConfig struct {
+	Provider  string        `yaml:"provider"` // huggingface/openai/ollama，默认 huggingface
 	ModelName string        `yaml:"model_name"`
 	APIKey    string        `yaml:"api_key"`
 	Timeout   time.Duration `yaml:"timeout"`
 	ApiURL    string        `yaml:"api_url"`
 	Model     string        `yaml:"model"`
+
+	BatchSize   int `yaml:"batch_size"`  // 单次请求携带的文本数
+	Concurrency int `yaml:"concurrency"` // 同时进行的批量请求数
+	MaxRetries  int `yaml:"max_retries"` // 瞬时错误的最大重试次数
+
+	Cache EmbeddingCacheConfig `yaml:"cache"`
+}
+
+// EmbeddingCacheConfig 嵌入向量本地缓存配置
+type EmbeddingCacheConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	Dir        string `yaml:"dir"`         // 缓存目录
+	MaxEntries int    `yaml:"max_entries"` // 最大条目数，0 表示不限制
+	MaxSizeMB  int    `yaml:"max_size_mb"` // 最大占用空间(MB)，0 表示不限制
 }
 
 // DeepSeekConfig DeepSeek LLM配置
 type DeepSeekConfig struct {
-	BaseURL string        `yaml:"base_url"`
-	APIKey  string        `yaml:"api_key"`
-	Model   string        `yaml:"model"`
-	Timeout time.Duration `yaml:"timeout"`
+	BaseURL     string        `yaml:"base_url"`
+	APIKey      string        `yaml:"api_key"`
+	Model       string        `yaml:"model"`
+	Timeout     time.Duration `yaml:"timeout"`
+	Temperature *float64      `yaml:"temperature"` // 采样温度，未配置时使用服务端默认值
+	MaxTokens   int           `yaml:"max_tokens"`  // 最大生成 token 数，0 表示不限制
+}
+
+// 大模型提供方
+const (
+	LLMProviderOpenAI = "openai" // OpenAI 兼容的 /v1/chat/completions
+	LLMProviderOllama = "ollama" // Ollama 原生 /api/chat
+	LLMProviderEcho   = "echo"   // 回显假模型，用于测试
+)
+
+// LLMConfig 大模型配置，未配置 models 时使用 deepseek 配置作为唯一模型
+type LLMConfig struct {
+	Default string           `yaml:"default"` // 默认模型名称
+	Models  []LLMModelConfig `yaml:"models"`
+}
+
+// LLMModelConfig 单个模型配置
+type LLMModelConfig struct {
+	Name        string        `yaml:"name"`     // 模型名称，请求中据此选择模型
+	Provider    string        `yaml:"provider"` // openai/ollama/echo
+	BaseURL     string        `yaml:"base_url"`
+	APIKey      string        `yaml:"api_key"`
+	Model       string        `yaml:"model"` // 提供方的模型标识
+	Timeout     time.Duration `yaml:"timeout"`
+	Temperature *float64      `yaml:"temperature"`
+	MaxTokens   int           `yaml:"max_tokens"`
 }
 
 // DocumentConfig 文档处理配置
 type DocumentConfig struct {
-	ChunkSize    int    `yaml:"chunk_size"`    // 文本分块大小
-	ChunkOverlap int    `yaml:"chunk_overlap"` // 分块重叠大小
-	MaxFileSize  string `yaml:"max_file_size"` // 最大文件大小(如10MB)
+	ChunkSize    int    `yaml:"chunk_size"`    // 文本分块大小，配置 tokenizer 时以 token 计，否则以字节计
+	ChunkOverlap int    `yaml:"chunk_overlap"` // 分块重叠大小，单位同 chunk_size
+	Tokenizer    string `yaml:"tokenizer"`     // 嵌入模型的 tokenizer.json、vocab.txt 或模型目录
+	// SplitMode 分块方式: auto(默认，按语言选择)/words/cjk
+	SplitMode       string `yaml:"split_mode"`
+	CJKChunkSize    int    `yaml:"cjk_chunk_size"`    // 中日文分块字符数，0表示沿用 chunk_size
+	CJKChunkOverlap int    `yaml:"cjk_chunk_overlap"` // 中日文分块重叠字符数
+	MaxFileSize     string `yaml:"max_file_size"`     // 最大文件大小(如10MB)
+	// ExtensionLimits 按扩展名覆盖最大文件大小，如 {".pdf": "50MB"}
+	ExtensionLimits map[string]string `yaml:"extension_limits"`
+	MaxPages        int               `yaml:"max_pages"`  // 单个PDF最大页数(演示文稿按幻灯片数计)，0表示不限制
+	MaxSheets       int               `yaml:"max_sheets"` // 单个表格文件最大工作表数，0表示不限制
+	MaxChunks       int               `yaml:"max_chunks"` // 单个文档最大分块数，0表示不限制
+
+	// SpreadsheetFormat 表格行的输出格式: records(默认，"表头: 值")/markdown(Markdown 表格，分块开头重复表头)
+	SpreadsheetFormat string                 `yaml:"spreadsheet_format"`
+	Semantic          SemanticSplitterConfig `yaml:"semantic"`
+}
+
+// SemanticSplitterConfig 语义分块配置，知识库的 splitter 为 semantic 时使用
+type SemanticSplitterConfig struct {
+	BreakpointPercentile float64 `yaml:"breakpoint_percentile"` // 相邻句子距离超过该百分位时断开，默认95
+	MinChunkSize         int     `yaml:"min_chunk_size"`        // 最小分块大小，单位同 chunk_size，0表示 max_chunk_size 的1/4
+	MaxChunkSize         int     `yaml:"max_chunk_size"`        // 最大分块大小，0表示沿用 chunk_size
+}
+
+// IngestionConfig 后台入库任务配置
+type IngestionConfig struct {
+	Workers    int    `yaml:"workers"`     // 并行处理的任务数，默认2
+	QueueSize  int    `yaml:"queue_size"`  // 排队任务上限，默认100
+	StagingDir string `yaml:"staging_dir"` // 上传文件暂存目录
+	JobFile    string `yaml:"job_file"`    // 任务状态文件，为空时仅保存在内存(重启后丢失)
+	MaxJobs    int    `yaml:"max_jobs"`    // 保留的已结束任务数，超出时删除最早结束的任务，默认1000
+
+	Archive ArchiveConfig `yaml:"archive"`
+}
+
+// ArchiveConfig 上传 zip、tar、tar.gz 归档时的解压限制，归档内单个文件的大小上限沿用 document.max_file_size
+type ArchiveConfig struct {
+	MaxEntries   int    `yaml:"max_entries"`    // 最大文件数(包括嵌套归档中的文件)，默认1000
+	MaxTotalSize string `yaml:"max_total_size"` // 解压后的最大总大小，默认500MB
+	MaxDepth     int    `yaml:"max_depth"`      // 展开嵌套归档的最大层数，0表示不展开
+	MaxRatio     int    `yaml:"max_ratio"`      // zip 中单个文件的最大压缩比，默认100
+}
+
+// GetMaxTotalSizeBytes 解析解压后的最大总大小，未配置时默认500MB
+func (a *ArchiveConfig) GetMaxTotalSizeBytes() (int64, error) {
+	if strings.TrimSpace(a.MaxTotalSize) == "" {
+		return 500 * 1024 * 1024, nil
+	}
+	return ParseSize(a.MaxTotalSize)
 }
 
 // Load 从YAML文件加载配置
@@ -106,6 +219,11 @@ func (c *Config) Sanitized() Config {
 	sanitized.Embedding.APIKey = "***"
 	sanitized.DeepSeek.APIKey = "***"
 	sanitized.Milvus.Password = "***"
+	sanitized.LLM.Models = make([]LLMModelConfig, len(c.LLM.Models))
+	for i, m := range c.LLM.Models {
+		m.APIKey = "***"
+		sanitized.LLM.Models[i] = m
+	}
 	return sanitized
 }
 
@@ -116,25 +234,60 @@ func (c *Config) validate() error {
 		return fmt.Errorf("server address is required")
 	}
 
-	// Milvus配置验证
-	if c.Milvus.Address == "" {
-		return fmt.Errorf("milvus address is required")
+	// 存储配置验证
+	if c.Storage.Backend == "" {
+		c.Storage.Backend = StorageBackendMilvus
 	}
-	if c.Milvus.CollectionName == "" {
-		return fmt.Errorf("milvus collection name is required")
+	switch c.Storage.Backend {
+	case StorageBackendMilvus:
+		if c.Milvus.Address == "" {
+			return fmt.Errorf("milvus address is required")
+		}
+		if c.Milvus.CollectionName == "" {
+			return fmt.Errorf("milvus collection name is required")
+		}
+		switch c.Milvus.Metric {
+		case "", "L2", "IP", "COSINE":
+		default:
+			return fmt.Errorf("unsupported milvus metric: %s", c.Milvus.Metric)
+		}
+	case StorageBackendMemory:
+		switch c.Storage.Metric {
+		case "", "L2", "COSINE":
+		default:
+			return fmt.Errorf("unsupported storage metric: %s", c.Storage.Metric)
+		}
+	default:
+		return fmt.Errorf("unsupported storage backend: %s", c.Storage.Backend)
 	}
 
 	// 嵌入模型验证
 	if c.Embedding.ModelName == "" {
 		return fmt.Errorf("embedding model name is required")
 	}
-
-	// DeepSeek验证
-	if c.DeepSeek.BaseURL == "" {
-		return fmt.Errorf("deepseek base URL is required")
+	switch c.Embedding.Provider {
+	case "", "huggingface", "openai", "ollama":
+	default:
+		return fmt.Errorf("unsupported embedding provider: %s", c.Embedding.Provider)
+	}
+	if c.Embedding.ApiURL == "" {
+		return fmt.Errorf("embedding api url is required")
 	}
-	if c.DeepSeek.Model == "" {
-		return fmt.Errorf("deepseek model is required")
+	if c.Embedding.Cache.Enabled {
+		if c.Embedding.Cache.Dir == "" {
+			return fmt.Errorf("embedding cache dir is required when cache is enabled")
+		}
+		if c.Embedding.Cache.MaxEntries < 0 || c.Embedding.Cache.MaxSizeMB < 0 {
+			return fmt.Errorf("embedding cache limits cannot be negative")
+		}
+	}
+	if c.Embedding.BatchSize < 0 || c.Embedding.Concurrency < 0 || c.Embedding.MaxRetries < 0 {
+		return fmt.Errorf("embedding batch size, concurrency and max retries cannot be negative")
+	}
+
+	// 大模型验证
+	if err := c.validateLLM(); err != nil {
+		return err
 	}
 
 	// 文档处理验证
@@ -147,13 +300,196 @@ func (c *Config) validate() error {
 	if c.Document.ChunkOverlap >= c.Document.ChunkSize {
 		return fmt.Errorf("chunk overlap must be smaller than chunk size")
 	}
+	if _, err := c.Document.GetMaxFileSizeBytes(); err != nil {
+		return fmt.Errorf("invalid max file size: %w", err)
+	}
+	if _, err := c.Document.GetExtensionLimitBytes(); err != nil {
+		return fmt.Errorf("invalid extension limit: %w", err)
+	}
+	switch c.Document.SplitMode {
+	case "", "auto", "words", "cjk":
+	default:
+		return fmt.Errorf("unsupported split mode: %s", c.Document.SplitMode)
+	}
+	switch c.Document.SpreadsheetFormat {
+	case "", "records", "markdown":
+	default:
+		return fmt.Errorf("unsupported spreadsheet format: %s", c.Document.SpreadsheetFormat)
+	}
+	if c.Document.CJKChunkSize < 0 || c.Document.CJKChunkOverlap < 0 {
+		return fmt.Errorf("cjk chunk size and overlap cannot be negative")
+	}
+	if c.Document.CJKChunkSize > 0 && c.Document.CJKChunkOverlap >= c.Document.CJKChunkSize {
+		return fmt.Errorf("cjk chunk overlap must be smaller than cjk chunk size")
+	}
+	if c.Document.MaxPages < 0 || c.Document.MaxSheets < 0 || c.Document.MaxChunks < 0 {
+		return fmt.Errorf("document page, sheet and chunk limits cannot be negative")
+	}
+	if sem := c.Document.Semantic; sem.BreakpointPercentile < 0 || sem.BreakpointPercentile > 100 {
+		return fmt.Errorf("semantic breakpoint percentile must be between 0 and 100")
+	}
+	if sem := c.Document.Semantic; sem.MinChunkSize < 0 || sem.MaxChunkSize < 0 {
+		return fmt.Errorf("semantic chunk sizes cannot be negative")
+	}
+	if sem := c.Document.Semantic; sem.MaxChunkSize > 0 && sem.MinChunkSize > sem.MaxChunkSize {
+		return fmt.Errorf("semantic min chunk size must not exceed max chunk size")
+	}
+
+	// 入库任务验证
+	if c.Ingestion.Workers == 0 {
+		c.Ingestion.Workers = 2
+	}
+	if c.Ingestion.QueueSize == 0 {
+		c.Ingestion.QueueSize = 100
+	}
+	if c.Ingestion.MaxJobs == 0 {
+		c.Ingestion.MaxJobs = 1000
+	}
+	if c.Ingestion.Workers < 0 || c.Ingestion.QueueSize < 0 || c.Ingestion.MaxJobs < 0 {
+		return fmt.Errorf("ingestion workers, queue size and max jobs cannot be negative")
+	}
+	if c.Ingestion.StagingDir == "" {
+		c.Ingestion.StagingDir = filepath.Join(os.TempDir(), "kb-uploads")
+	}
+	if c.Ingestion.Archive.MaxEntries == 0 {
+		c.Ingestion.Archive.MaxEntries = 1000
+	}
+	if c.Ingestion.Archive.MaxRatio == 0 {
+		c.Ingestion.Archive.MaxRatio = 100
+	}
+	if a := c.Ingestion.Archive; a.MaxEntries < 0 || a.MaxDepth < 0 || a.MaxRatio < 0 {
+		return fmt.Errorf("archive entry, depth and ratio limits cannot be negative")
+	}
+
+	return nil
+}
+
+// validateLLM 校验大模型配置，未配置 llm.models 时由 deepseek 配置生成默认模型
+func (c *Config) validateLLM() error {
+	if len(c.LLM.Models) == 0 {
+		if c.DeepSeek.BaseURL == "" {
+			return fmt.Errorf("deepseek base URL is required")
+		}
+		if c.DeepSeek.Model == "" {
+			return fmt.Errorf("deepseek model is required")
+		}
+		c.LLM.Models = []LLMModelConfig{{
+			Name:        c.DeepSeek.Model,
+			Provider:    LLMProviderOpenAI,
+			BaseURL:     c.DeepSeek.BaseURL,
+			APIKey:      c.DeepSeek.APIKey,
+			Model:       c.DeepSeek.Model,
+			Timeout:     c.DeepSeek.Timeout,
+			Temperature: c.DeepSeek.Temperature,
+			MaxTokens:   c.DeepSeek.MaxTokens,
+		}}
+	}
+
+	names := make(map[string]bool)
+	for i := range c.LLM.Models {
+		m := &c.LLM.Models[i]
+		if m.Provider == "" {
+			m.Provider = LLMProviderOpenAI
+		}
+		if m.Name == "" {
+			m.Name = m.Model
+		}
+		if m.Name == "" {
+			return fmt.Errorf("llm model #%d: name is required", i)
+		}
+		if names[m.Name] {
+			return fmt.Errorf("llm model %s: duplicate name", m.Name)
+		}
+		names[m.Name] = true
+
+		switch m.Provider {
+		case LLMProviderOpenAI, LLMProviderOllama:
+			if m.BaseURL == "" {
+				return fmt.Errorf("llm model %s: base URL is required", m.Name)
+			}
+			if m.Model == "" {
+				return fmt.Errorf("llm model %s: model is required", m.Name)
+			}
+		case LLMProviderEcho:
+		default:
+			return fmt.Errorf("llm model %s: unsupported provider %s", m.Name, m.Provider)
+		}
+		if m.MaxTokens < 0 {
+			return fmt.Errorf("llm model %s: max tokens cannot be negative", m.Name)
+		}
+	}
+
+	if c.LLM.Default == "" {
+		c.LLM.Default = c.LLM.Models[0].Name
+	}
+	if !names[c.LLM.Default] {
+		return fmt.Errorf("default llm model %s is not configured", c.LLM.Default)
+	}
 
 	return nil
 }
 
 // GetMaxFileSizeBytes 解析最大文件大小字符串为字节数
+// 未配置时默认10MB
 func (d *DocumentConfig) GetMaxFileSizeBytes() (int64, error) {
-	// 实现字符串如"10MB"到字节数的转换
-	// 示例实现(需根据实际需求完善):
-	return 10 * 1024 * 1024, nil // 默认10MB
+	if strings.TrimSpace(d.MaxFileSize) == "" {
+		return 10 * 1024 * 1024, nil
+	}
+	return ParseSize(d.MaxFileSize)
+}
+
+// GetExtensionLimitBytes 解析按扩展名配置的最大文件大小，扩展名统一为小写并带点
+func (d *DocumentConfig) GetExtensionLimitBytes() (map[string]int64, error) {
+	limits := make(map[string]int64, len(d.ExtensionLimits))
+	for ext, size := range d.ExtensionLimits {
+		n, err := ParseSize(size)
+		if err != nil {
+			return nil, fmt.Errorf("extension %s: %w", ext, err)
+		}
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		limits[ext] = n
+	}
+	return limits, nil
+}
+
+// sizeUnits 大小单位，按1024进制换算
+var sizeUnits = map[string]float64{
+	"":   1,
+	"B":  1,
+	"K":  1 << 10,
+	"KB": 1 << 10,
+	"M":  1 << 20,
+	"MB": 1 << 20,
+	"G":  1 << 30,
+	"GB": 1 << 30,
+	"T":  1 << 40,
+	"TB": 1 << 40,
+}
+
+// ParseSize 解析 "500KB"、"1.5GB"、"1024" 等大小字符串为字节数，单位不区分大小写，KiB/MiB 等同 KB/MB
+func ParseSize(s string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	i := 0
+	for i < len(str) && (str[i] >= '0' && str[i] <= '9' || str[i] == '.') {
+		i++
+	}
+	number, unit := str[:i], strings.TrimSpace(str[i:])
+	unit = strings.Replace(unit, "IB", "B", 1)
+
+	multiplier, ok := sizeUnits[unit]
+	if number == "" || !ok {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", s, err)
+	}
+	bytes := value * multiplier
+	if bytes <= 0 || bytes > math.MaxInt64 {
+		return 0, fmt.Errorf("size %q out of range", s)
+	}
+	return int64(bytes), nil
 }
@@ -0,0 +1,218 @@
+package embedding
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 缓存目录结构: <dir>/model 记录模型名称，<dir>/entries/<key前2位>/<key> 保存向量
+const (
+	cacheModelFile  = "model"
+	cacheEntriesDir = "entries"
+)
+
+// diskCache 以文件形式保存向量的本地缓存，按最近使用淘汰
+type diskCache struct {
+	dir        string
+	maxEntries int   // 最大条目数，0 表示不限制
+	maxBytes   int64 // 最大总字节数，0 表示不限制
+
+	mu      sync.Mutex
+	lru     *list.List // 队首为最近使用
+	entries map[string]*list.Element
+	bytes   int64
+}
+
+type cacheEntry struct {
+	key  string
+	size int64
+}
+
+// openDiskCache 打开缓存目录，记录的模型与 model 不一致时清空已有条目
+func openDiskCache(dir, model string, maxEntries int, maxBytes int64) (*diskCache, error) {
+	c := &diskCache{
+		dir:        dir,
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	modelPath := filepath.Join(dir, cacheModelFile)
+	recorded, err := os.ReadFile(modelPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read cache model file: %w", err)
+	}
+	if strings.TrimSpace(string(recorded)) != model {
+		// 模型变更后旧向量全部失效
+		if err := os.RemoveAll(filepath.Join(dir, cacheEntriesDir)); err != nil {
+			return nil, fmt.Errorf("failed to invalidate cache: %w", err)
+		}
+		if err := os.WriteFile(modelPath, []byte(model), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write cache model file: %w", err)
+		}
+	}
+
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.evictLocked()
+	c.mu.Unlock()
+
+	return c, nil
+}
+
+// load 扫描已有条目，按修改时间重建最近使用顺序
+func (c *diskCache) load() error {
+	type fileInfo struct {
+		key     string
+		size    int64
+		modTime time.Time
+	}
+	var files []fileInfo
+
+	root := filepath.Join(c.dir, cacheEntriesDir)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		// 只识别 64 位十六进制键，忽略临时文件
+		if info.IsDir() || len(info.Name()) != sha256.Size*2 {
+			return nil
+		}
+		files = append(files, fileInfo{key: info.Name(), size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan cache directory: %w", err)
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, f := range files {
+		c.entries[f.key] = c.lru.PushFront(&cacheEntry{key: f.key, size: f.size})
+		c.bytes += f.size
+	}
+	return nil
+}
+
+func (c *diskCache) path(key string) string {
+	return filepath.Join(c.dir, cacheEntriesDir, key[:2], key)
+}
+
+// Get 读取缓存的向量
+func (c *diskCache) Get(key string) ([]float32, bool) {
+	c.mu.Lock()
+	elem, ok := c.entries[key]
+	if ok {
+		c.lru.MoveToFront(elem)
+	}
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(c.path(key))
+	if err != nil || len(data)%4 != 0 || len(data) == 0 {
+		c.remove(key)
+		return nil, false
+	}
+	// 更新修改时间，重启后仍能保持最近使用顺序
+	now := time.Now()
+	_ = os.Chtimes(c.path(key), now, now)
+
+	vector := make([]float32, len(data)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))
+	}
+	return vector, true
+}
+
+// Put 写入向量，超出容量时淘汰最久未使用的条目
+func (c *diskCache) Put(key string, vector []float32) error {
+	data := make([]byte, len(vector)*4)
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(data[i*4:], math.Float32bits(v))
+	}
+
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	// 先写临时文件再重命名，避免读到写了一半的向量
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		c.bytes += int64(len(data)) - entry.size
+		entry.size = int64(len(data))
+		c.lru.MoveToFront(elem)
+	} else {
+		c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, size: int64(len(data))})
+		c.bytes += int64(len(data))
+	}
+	c.evictLocked()
+	return nil
+}
+
+// Len 返回条目数和总字节数
+func (c *diskCache) Len() (int, int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries), c.bytes
+}
+
+func (c *diskCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.removeLocked(elem)
+	}
+}
+
+func (c *diskCache) evictLocked() {
+	for c.lru.Len() > 0 &&
+		((c.maxEntries > 0 && c.lru.Len() > c.maxEntries) || (c.maxBytes > 0 && c.bytes > c.maxBytes)) {
+		c.removeLocked(c.lru.Back())
+	}
+}
+
+func (c *diskCache) removeLocked(elem *list.Element) {
+	entry := elem.Value.(*cacheEntry)
+	c.lru.Remove(elem)
+	delete(c.entries, entry.key)
+	c.bytes -= entry.size
+	os.Remove(c.path(entry.key))
+}
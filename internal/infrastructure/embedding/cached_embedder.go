@@ -0,0 +1,163 @@
+package embedding
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync/atomic"
+
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/embedding"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/infrastructure/logger"
+	"golang.org/x/text/unicode/norm"
+)
+
+// CacheStats 嵌入缓存统计
+type CacheStats struct {
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+	Entries int   `json:"entries"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// CachedEmbedder 为任意 Embedder 增加本地磁盘缓存，
+// 以 (模型名称, 规范化文本的 SHA-256) 作为键，相同内容不会重复请求嵌入服务
+type CachedEmbedder struct {
+	inner  embedding.Embedder
+	model  string
+	store  *diskCache
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+var _ embedding.Embedder = (*CachedEmbedder)(nil)
+
+// NewCachedEmbedder 在 dir 下打开缓存，model 与缓存记录的模型不一致时清空旧缓存
+// maxEntries/maxBytes 为 0 表示不限制
+func NewCachedEmbedder(inner embedding.Embedder, model, dir string, maxEntries int, maxBytes int64) (*CachedEmbedder, error) {
+	store, err := openDiskCache(dir, model, maxEntries, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	return &CachedEmbedder{
+		inner: inner,
+		model: model,
+		store: store,
+	}, nil
+}
+
+func (c *CachedEmbedder) Embed(ctx context.Context, text string) (*embedding.Embedding, error) {
+	key := c.key(text)
+	if vector, ok := c.store.Get(key); ok {
+		c.hits.Add(1)
+		return &embedding.Embedding{Vector: vector, Model: c.model}, nil
+	}
+	c.misses.Add(1)
+
+	emb, err := c.inner.Embed(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	c.put(key, emb)
+	return emb, nil
+}
+
+// EmbedBatch 仅将未命中缓存的文本交给内部 Embedder，批内重复文本只嵌入一次
+func (c *CachedEmbedder) EmbedBatch(ctx context.Context, texts []string) ([]*embedding.Embedding, error) {
+	embeddings := make([]*embedding.Embedding, len(texts))
+	keys := make([]string, len(texts))
+
+	var missTexts []string
+	missPositions := make(map[string][]int) // 键 -> 原始下标
+	var missKeys []string
+	for i, text := range texts {
+		keys[i] = c.key(text)
+		if vector, ok := c.store.Get(keys[i]); ok {
+			c.hits.Add(1)
+			embeddings[i] = &embedding.Embedding{Vector: vector, Model: c.model}
+			continue
+		}
+		c.misses.Add(1)
+		if _, seen := missPositions[keys[i]]; !seen {
+			missTexts = append(missTexts, text)
+			missKeys = append(missKeys, keys[i])
+		}
+		missPositions[keys[i]] = append(missPositions[keys[i]], i)
+	}
+
+	if len(missTexts) == 0 {
+		return embeddings, nil
+	}
+
+	results, err := c.inner.EmbedBatch(ctx, missTexts)
+	var innerErr *embedding.BatchError
+	if err != nil && !errors.As(err, &innerErr) {
+		return nil, err
+	}
+
+	// 将内部批次的结果和失败下标映射回原始下标
+	batchErr := &embedding.BatchError{Total: len(texts), Failures: make(map[int]error)}
+	for j, key := range missKeys {
+		var emb *embedding.Embedding
+		if j < len(results) {
+			emb = results[j]
+		}
+		var failure error
+		if innerErr != nil {
+			failure = innerErr.Failures[j]
+		}
+		if failure == nil && emb != nil {
+			c.put(key, emb)
+		}
+		for _, idx := range missPositions[key] {
+			if failure != nil || emb == nil {
+				if failure == nil {
+					failure = errors.New("embedder returned no vector")
+				}
+				batchErr.Failures[idx] = failure
+				continue
+			}
+			embeddings[idx] = emb
+		}
+	}
+
+	if len(batchErr.Failures) > 0 {
+		return embeddings, batchErr
+	}
+	return embeddings, nil
+}
+
+// Stats 返回命中统计和缓存占用
+func (c *CachedEmbedder) Stats() CacheStats {
+	entries, bytes := c.store.Len()
+	return CacheStats{
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Entries: entries,
+		Bytes:   bytes,
+	}
+}
+
+func (c *CachedEmbedder) put(key string, emb *embedding.Embedding) {
+	if emb == nil || len(emb.Vector) == 0 {
+		return
+	}
+	if err := c.store.Put(key, emb.Vector); err != nil {
+		logger.Warnf("Failed to write embedding cache: %v", err)
+	}
+}
+
+// key 由模型名称和规范化文本计算缓存键
+func (c *CachedEmbedder) key(text string) string {
+	h := sha256.New()
+	h.Write([]byte(c.model))
+	h.Write([]byte{0})
+	h.Write([]byte(normalizeText(text)))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// normalizeText 统一 Unicode 组合形式并折叠空白，避免仅空白差异导致缓存未命中
+func normalizeText(text string) string {
+	return strings.Join(strings.Fields(norm.NFC.String(text)), " ")
+}
@@ -0,0 +1,215 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/embedding"
+)
+
+// countingEmbedder 记录收到的文本，第 n 次请求返回 [文本长度, n]
+type countingEmbedder struct {
+	texts []string
+	fail  map[string]bool // 批量嵌入时失败的文本
+}
+
+func (e *countingEmbedder) Embed(_ context.Context, text string) (*embedding.Embedding, error) {
+	e.texts = append(e.texts, text)
+	return &embedding.Embedding{Vector: []float32{float32(len(text)), float32(len(e.texts))}}, nil
+}
+
+func (e *countingEmbedder) EmbedBatch(ctx context.Context, texts []string) ([]*embedding.Embedding, error) {
+	results := make([]*embedding.Embedding, len(texts))
+	batchErr := &embedding.BatchError{Total: len(texts), Failures: make(map[int]error)}
+	for i, text := range texts {
+		if e.fail[text] {
+			e.texts = append(e.texts, text)
+			batchErr.Failures[i] = errors.New("embedding failed")
+			continue
+		}
+		results[i], _ = e.Embed(ctx, text)
+	}
+	if len(batchErr.Failures) > 0 {
+		return results, batchErr
+	}
+	return results, nil
+}
+
+func newCached(t *testing.T, inner embedding.Embedder, model, dir string, maxEntries int, maxBytes int64) *CachedEmbedder {
+	t.Helper()
+	c, err := NewCachedEmbedder(inner, model, dir, maxEntries, maxBytes)
+	if err != nil {
+		t.Fatalf("NewCachedEmbedder: %v", err)
+	}
+	return c
+}
+
+func embed(t *testing.T, c *CachedEmbedder, text string) []float32 {
+	t.Helper()
+	emb, err := c.Embed(context.Background(), text)
+	if err != nil {
+		t.Fatalf("Embed(%q): %v", text, err)
+	}
+	return emb.Vector
+}
+
+func TestCachedEmbedderHitsAndStats(t *testing.T) {
+	inner := &countingEmbedder{}
+	c := newCached(t, inner, "minilm", t.TempDir(), 0, 0)
+
+	first := embed(t, c, "hello")
+	second := embed(t, c, "hello")
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("cached vector = %v, want %v", second, first)
+	}
+	embed(t, c, "world")
+
+	if len(inner.texts) != 2 {
+		t.Errorf("inner embedder called for %q, want hello and world once", inner.texts)
+	}
+	want := CacheStats{Hits: 1, Misses: 2, Entries: 2, Bytes: 16}
+	if got := c.Stats(); got != want {
+		t.Errorf("Stats = %+v, want %+v", got, want)
+	}
+}
+
+func TestCachedEmbedderNormalizesKey(t *testing.T) {
+	inner := &countingEmbedder{}
+	c := newCached(t, inner, "minilm", t.TempDir(), 0, 0)
+
+	// 空白差异和 Unicode 组合形式(NFD 的 e+\u0301 与 NFC 的 é)不同的文本共用一个缓存条目
+	for _, text := range []string{"café  au\tlait", " cafe\u0301 au lait\n", "café au lait"} {
+		embed(t, c, text)
+	}
+	if len(inner.texts) != 1 {
+		t.Errorf("inner embedder called for %q, want once", inner.texts)
+	}
+	// 大小写不同视为不同文本
+	embed(t, c, "Café au lait")
+	if len(inner.texts) != 2 {
+		t.Errorf("inner embedder called for %q, want a miss for different case", inner.texts)
+	}
+	if got := c.Stats(); got.Hits != 2 || got.Misses != 2 {
+		t.Errorf("Stats = %+v, want 2 hits and 2 misses", got)
+	}
+}
+
+func TestCachedEmbedderInvalidatesOnModelChange(t *testing.T) {
+	dir := t.TempDir()
+	inner := &countingEmbedder{}
+	embed(t, newCached(t, inner, "minilm", dir, 0, 0), "hello")
+
+	// 同一模型重新打开时沿用磁盘上的缓存
+	reopened := newCached(t, inner, "minilm", dir, 0, 0)
+	if got := reopened.Stats(); got.Entries != 1 {
+		t.Fatalf("reopened Stats = %+v, want the persisted entry", got)
+	}
+	embed(t, reopened, "hello")
+	if len(inner.texts) != 1 {
+		t.Errorf("inner embedder called for %q after reopening, want a cache hit", inner.texts)
+	}
+
+	// 换模型后旧条目被清空，换回原模型也不再有效
+	for _, model := range []string{"bge-small", "minilm"} {
+		c := newCached(t, inner, model, dir, 0, 0)
+		if got := c.Stats(); got.Entries != 0 {
+			t.Errorf("%s: Stats = %+v, want an empty cache", model, got)
+		}
+		before := len(inner.texts)
+		embed(t, c, "hello")
+		if len(inner.texts) != before+1 {
+			t.Errorf("%s: want a miss after the model changed", model)
+		}
+	}
+	recorded, err := os.ReadFile(filepath.Join(dir, cacheModelFile))
+	if err != nil || string(recorded) != "minilm" {
+		t.Errorf("recorded model = %q, %v", recorded, err)
+	}
+}
+
+func TestCachedEmbedderEvictsLeastRecentlyUsed(t *testing.T) {
+	inner := &countingEmbedder{}
+	c := newCached(t, inner, "minilm", t.TempDir(), 2, 0)
+
+	embed(t, c, "a")
+	embed(t, c, "b")
+	embed(t, c, "a") // a 成为最近使用
+	embed(t, c, "c") // 淘汰 b
+	if got := c.Stats(); got.Entries != 2 || got.Bytes != 16 {
+		t.Errorf("Stats = %+v, want 2 entries", got)
+	}
+	if _, err := os.Stat(c.store.path(c.key("b"))); !os.IsNotExist(err) {
+		t.Errorf("evicted entry file still exists: %v", err)
+	}
+
+	before := len(inner.texts)
+	embed(t, c, "a")
+	embed(t, c, "c")
+	if len(inner.texts) != before {
+		t.Errorf("a and c should still be cached, inner embedder called for %q", inner.texts[before:])
+	}
+	embed(t, c, "b")
+	if len(inner.texts) != before+1 {
+		t.Error("b should have been evicted")
+	}
+}
+
+func TestCachedEmbedderEvictsBySize(t *testing.T) {
+	// 每个向量2维，占8字节
+	c := newCached(t, &countingEmbedder{}, "minilm", t.TempDir(), 0, 20)
+	for _, text := range []string{"a", "b", "c"} {
+		embed(t, c, text)
+	}
+	if got := c.Stats(); got.Entries != 2 || got.Bytes != 16 {
+		t.Errorf("Stats = %+v, want 2 entries within 20 bytes", got)
+	}
+}
+
+func TestDiskCacheRestoresRecencyOnReopen(t *testing.T) {
+	dir := t.TempDir()
+	inner := &countingEmbedder{}
+	c := newCached(t, inner, "minilm", dir, 0, 0)
+	embed(t, c, "old")
+	embed(t, c, "recent")
+	// 修改时间记录使用顺序
+	now := time.Now()
+	os.Chtimes(c.store.path(c.key("old")), now.Add(-time.Hour), now.Add(-time.Hour))
+	os.Chtimes(c.store.path(c.key("recent")), now, now)
+
+	reopened := newCached(t, inner, "minilm", dir, 1, 0)
+	if got := reopened.Stats(); got.Entries != 1 {
+		t.Fatalf("Stats = %+v, want 1 entry", got)
+	}
+	before := len(inner.texts)
+	embed(t, reopened, "recent")
+	if len(inner.texts) != before {
+		t.Error("the most recently used entry should survive reopening with a smaller limit")
+	}
+}
+
+func TestCachedEmbedderBatch(t *testing.T) {
+	inner := &countingEmbedder{fail: map[string]bool{"bad": true}}
+	c := newCached(t, inner, "minilm", t.TempDir(), 0, 0)
+	cached := embed(t, c, "a")
+
+	results, err := c.EmbedBatch(context.Background(), []string{"a", "b", "bad", "b"})
+	var batchErr *embedding.BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Failures) != 1 || batchErr.Failures[2] == nil {
+		t.Fatalf("err = %v, want a BatchError for index 2", err)
+	}
+	// 已缓存的 a 不再请求，批内重复的 b 只嵌入一次
+	if want := []string{"a", "b", "bad"}; !reflect.DeepEqual(inner.texts, want) {
+		t.Errorf("inner embedder called for %q, want %q", inner.texts, want)
+	}
+	if !reflect.DeepEqual(results[0].Vector, cached) || results[1] == nil || results[1] != results[3] || results[2] != nil {
+		t.Errorf("results = %v", results)
+	}
+	if got := c.Stats(); got.Hits != 1 || got.Misses != 4 || got.Entries != 2 {
+		t.Errorf("Stats = %+v, want 1 hit, 4 misses and 2 entries", got)
+	}
+}
@@ -0,0 +1,23 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/embedding"
+)
+
+// dimensionProbeText 用于探测向量维度的固定文本
+const dimensionProbeText = "dimension probe"
+
+// ProbeDimension 通过嵌入一段固定文本获取嵌入模型输出的向量维度
+func ProbeDimension(ctx context.Context, embedder embedding.Embedder) (int, error) {
+	emb, err := embedder.Embed(ctx, dimensionProbeText)
+	if err != nil {
+		return 0, fmt.Errorf("failed to probe embedding dimension: %w", err)
+	}
+	if emb == nil || len(emb.Vector) == 0 {
+		return 0, fmt.Errorf("failed to probe embedding dimension: empty vector")
+	}
+	return len(emb.Vector), nil
+}
@@ -0,0 +1,321 @@
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/embedding"
+)
+
+// 嵌入服务提供方
+const (
+	ProviderHuggingFace = "huggingface" // 本地推理服务 {"texts": [...]}
+	ProviderOpenAI      = "openai"      // OpenAI /v1/embeddings 及兼容服务(vLLM、LocalAI、TEI)
+	ProviderOllama      = "ollama"      // Ollama /api/embed
+)
+
+// 批量嵌入默认参数
+const (
+	defaultBatchSize    = 32
+	defaultConcurrency  = 4
+	defaultMaxRetries   = 3
+	defaultRetryBackoff = 500 * time.Millisecond
+	defaultTimeout      = 30 * time.Second
+)
+
+// NewEmbedder 按提供方创建嵌入器
+func NewEmbedder(provider, apiURL, apiKey, model string, opts ...Option) (embedding.Embedder, error) {
+	switch provider {
+	case "", ProviderHuggingFace:
+		return NewHuggingFaceEmbedder(apiURL, apiKey, model, opts...), nil
+	case ProviderOpenAI:
+		return NewOpenAIEmbedder(apiURL, apiKey, model, opts...), nil
+	case ProviderOllama:
+		return NewOllamaEmbedder(apiURL, apiKey, model, opts...), nil
+	default:
+		return nil, fmt.Errorf("unsupported embedding provider: %s", provider)
+	}
+}
+
+// Option 配置嵌入器的批量、并发、重试和超时参数
+type Option func(*batchEmbedder)
+
+// WithBatchSize 设置单次请求携带的文本数，<=0 时忽略
+func WithBatchSize(size int) Option {
+	return func(e *batchEmbedder) {
+		if size > 0 {
+			e.batchSize = size
+		}
+	}
+}
+
+// WithConcurrency 设置同时进行的批次数，<=0 时忽略
+func WithConcurrency(n int) Option {
+	return func(e *batchEmbedder) {
+		if n > 0 {
+			e.concurrency = n
+		}
+	}
+}
+
+// WithRetry 设置瞬时错误的重试次数和首次退避时间，<=0 的参数保持默认值
+func WithRetry(maxRetries int, backoff time.Duration) Option {
+	return func(e *batchEmbedder) {
+		if maxRetries > 0 {
+			e.maxRetries = maxRetries
+		}
+		if backoff > 0 {
+			e.retryBackoff = backoff
+		}
+	}
+}
+
+// WithTimeout 设置单次 HTTP 请求超时时间，<=0 时使用默认值
+func WithTimeout(timeout time.Duration) Option {
+	return func(e *batchEmbedder) {
+		if timeout > 0 {
+			e.httpClient.Timeout = timeout
+		}
+	}
+}
+
+// WithHTTPClient 使用自定义 HTTP 客户端
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(e *batchEmbedder) {
+		if httpClient != nil {
+			e.httpClient = httpClient
+		}
+	}
+}
+
+// batchEmbedder 实现分批、并发和重试，单次请求由各提供方的 embedTexts 完成
+type batchEmbedder struct {
+	apiKey       string
+	model        string
+	batchSize    int           // 单次请求携带的文本数
+	concurrency  int           // 同时进行的批次数
+	maxRetries   int           // 瞬时错误的最大重试次数
+	retryBackoff time.Duration // 首次重试等待时间，之后指数增长
+	httpClient   *http.Client
+	embedTexts   func(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+func newBatchEmbedder(apiKey, model string, opts []Option) *batchEmbedder {
+	e := &batchEmbedder{
+		apiKey:       apiKey,
+		model:        model,
+		batchSize:    defaultBatchSize,
+		concurrency:  defaultConcurrency,
+		maxRetries:   defaultMaxRetries,
+		retryBackoff: defaultRetryBackoff,
+		httpClient:   &http.Client{Timeout: defaultTimeout},
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// statusError 嵌入服务返回的非 200 响应
+type statusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("inference server returned status code %d: %s", e.StatusCode, e.Body)
+}
+
+// transportError 网络层错误(连接失败、读取中断等)
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string {
+	return fmt.Sprintf("HTTP request failed: %v", e.err)
+}
+
+func (e *transportError) Unwrap() error {
+	return e.err
+}
+
+func (e *batchEmbedder) Embed(ctx context.Context, text string) (*embedding.Embedding, error) {
+	if text == "" {
+		return nil, fmt.Errorf("cannot embed empty text")
+	}
+
+	vectors, err := e.embedWithRetry(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+
+	return &embedding.Embedding{
+		Vector: vectors[0],
+		Model:  e.model,
+	}, nil
+}
+
+// EmbedBatch 按 batchSize 分批请求嵌入服务，最多 concurrency 个批次并发
+// 部分批次失败时返回 *embedding.BatchError，成功的结果仍按原顺序返回
+func (e *batchEmbedder) EmbedBatch(ctx context.Context, texts []string) ([]*embedding.Embedding, error) {
+	embeddings := make([]*embedding.Embedding, len(texts))
+	batchErr := &embedding.BatchError{Total: len(texts), Failures: make(map[int]error)}
+
+	// 空文本直接记为失败，不参与请求
+	var pending []int
+	for i, text := range texts {
+		if text == "" {
+			batchErr.Failures[i] = fmt.Errorf("cannot embed empty text")
+			continue
+		}
+		pending = append(pending, i)
+	}
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, e.concurrency)
+	)
+	for start := 0; start < len(pending); start += e.batchSize {
+		end := start + e.batchSize
+		if end > len(pending) {
+			end = len(pending)
+		}
+		indexes := pending[start:end]
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			mu.Lock()
+			for _, idx := range pending[start:] {
+				batchErr.Failures[idx] = ctx.Err()
+			}
+			mu.Unlock()
+			wg.Wait()
+			return embeddings, batchErr
+		}
+
+		wg.Add(1)
+		go func(indexes []int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			batch := make([]string, len(indexes))
+			for i, idx := range indexes {
+				batch[i] = texts[idx]
+			}
+			vectors, err := e.embedWithRetry(ctx, batch)
+
+			mu.Lock()
+			defer mu.Unlock()
+			for i, idx := range indexes {
+				if err != nil {
+					batchErr.Failures[idx] = err
+					continue
+				}
+				embeddings[idx] = &embedding.Embedding{
+					Vector: vectors[i],
+					Model:  e.model,
+				}
+			}
+		}(indexes)
+	}
+	wg.Wait()
+
+	if len(batchErr.Failures) > 0 {
+		return embeddings, batchErr
+	}
+	return embeddings, nil
+}
+
+// embedWithRetry 请求嵌入服务，对网络错误、429 和 5xx 进行指数退避重试
+func (e *batchEmbedder) embedWithRetry(ctx context.Context, texts []string) ([][]float32, error) {
+	backoff := e.retryBackoff
+	for attempt := 0; ; attempt++ {
+		vectors, err := e.embedTexts(ctx, texts)
+		if err == nil {
+			if err := checkVectors(vectors, len(texts)); err != nil {
+				return nil, err
+			}
+			return vectors, nil
+		}
+		if attempt >= e.maxRetries || !isTransient(err) || ctx.Err() != nil {
+			return nil, err
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// postJSON 发送 JSON 请求并将响应解码到 out，配置了 API Key 时以 Bearer 方式携带
+func (e *batchEmbedder) postJSON(ctx context.Context, url string, in, out interface{}) error {
+	bodyBytes, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return &transportError{err: err}
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return &transportError{err: fmt.Errorf("failed to read response body: %w", err)}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return &statusError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return nil
+}
+
+// checkVectors 校验返回的向量数量与内容
+func checkVectors(vectors [][]float32, expected int) error {
+	if len(vectors) != expected {
+		return fmt.Errorf("expected %d embedding vectors, got %d", expected, len(vectors))
+	}
+	for i, vector := range vectors {
+		if len(vector) == 0 {
+			return fmt.Errorf("received empty embedding vector for text %d", i)
+		}
+	}
+	return nil
+}
+
+// isTransient 判断错误是否值得重试：网络错误、429 和 5xx
+// 调用方 ctx 的取消由 embedWithRetry 单独判断，单次请求超时仍会重试
+func isTransient(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.StatusCode == http.StatusTooManyRequests || se.StatusCode >= 500
+	}
+	var te *transportError
+	return errors.As(err, &te)
+}
@@ -0,0 +1,43 @@
+package embedding
+
+import (
+	"context"
+	"strings"
+)
+
+// OllamaEmbedder 调用 Ollama 的 /api/embed 接口
+type OllamaEmbedder struct {
+	*batchEmbedder
+	baseURL string
+}
+
+func NewOllamaEmbedder(baseURL, apiKey, model string, opts ...Option) *OllamaEmbedder {
+	e := &OllamaEmbedder{
+		batchEmbedder: newBatchEmbedder(apiKey, model, opts),
+		baseURL:       baseURL,
+	}
+	e.batchEmbedder.embedTexts = e.embedTexts
+	return e
+}
+
+type ollamaEmbedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type ollamaEmbedResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+}
+
+func (e *OllamaEmbedder) embedTexts(ctx context.Context, texts []string) ([][]float32, error) {
+	url := strings.TrimRight(e.baseURL, "/")
+	if !strings.HasSuffix(url, "/api/embed") {
+		url += "/api/embed"
+	}
+
+	var respData ollamaEmbedResponse
+	if err := e.postJSON(ctx, url, ollamaEmbedRequest{Model: e.model, Input: texts}, &respData); err != nil {
+		return nil, err
+	}
+	return respData.Embeddings, nil
+}
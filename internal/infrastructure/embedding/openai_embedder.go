@@ -0,0 +1,65 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// OpenAIEmbedder 调用 OpenAI 格式的 /v1/embeddings 接口，
+// 同样适用于 vLLM、LocalAI、TEI 等兼容服务
+type OpenAIEmbedder struct {
+	*batchEmbedder
+	baseURL string
+}
+
+func NewOpenAIEmbedder(baseURL, apiKey, model string, opts ...Option) *OpenAIEmbedder {
+	e := &OpenAIEmbedder{
+		batchEmbedder: newBatchEmbedder(apiKey, model, opts),
+		baseURL:       baseURL,
+	}
+	e.batchEmbedder.embedTexts = e.embedTexts
+	return e
+}
+
+type openAIEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type openAIEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// endpoint 拼接 embeddings 地址，兼容 base_url 是否带 /v1
+func (e *OpenAIEmbedder) endpoint() string {
+	base := strings.TrimRight(e.baseURL, "/")
+	if strings.HasSuffix(base, "/embeddings") {
+		return base
+	}
+	if strings.HasSuffix(base, "/v1") {
+		return base + "/embeddings"
+	}
+	return base + "/v1/embeddings"
+}
+
+func (e *OpenAIEmbedder) embedTexts(ctx context.Context, texts []string) ([][]float32, error) {
+	var respData openAIEmbeddingResponse
+	err := e.postJSON(ctx, e.endpoint(), openAIEmbeddingRequest{Model: e.model, Input: texts}, &respData)
+	if err != nil {
+		return nil, err
+	}
+
+	// 按 index 还原顺序，服务端不保证返回顺序
+	vectors := make([][]float32, len(texts))
+	for _, item := range respData.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding index %d out of range", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	return vectors, nil
+}
@@ -2,20 +2,29 @@ package deepseek
 
 import (
 	"context"
+	"net/http"
+	"time"
+
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/llm"
 )
 
-// Client 是 DeepSeek 的客户端实现
+// ProviderOpenAI OpenAI 兼容接口(DeepSeek API、Ollama /v1 等)的提供方标识
+const ProviderOpenAI = "openai"
+
+// Client 是 DeepSeek 的客户端实现，同时作为 OpenAI 兼容提供方实现 llm.ChatModel
 type Client struct {
 	service *DeepSeekQueryService
+	name    string
 }
+
+var _ llm.ChatModel = (*Client)(nil)
+
 type ClientOption func(*Client)
 
 // NewClient 创建一个新的 DeepSeek 客户端
 func NewClient(baseURL string, opts ...ClientOption) *Client {
 	client := &Client{
-		service: &DeepSeekQueryService{
-			baseURL: baseURL,
-		},
+		service: NewDeepSeekQueryService(baseURL, "", ""),
 	}
 
 	for _, opt := range opts {
@@ -24,6 +33,14 @@ func NewClient(baseURL string, opts ...ClientOption) *Client {
 
 	return client
 }
+
+// WithName 设置模型在配置中的名称，默认与模型标识相同
+func WithName(name string) ClientOption {
+	return func(c *Client) {
+		c.name = name
+	}
+}
+
 func WithAPIKey(apiKey string) ClientOption {
 	return func(c *Client) {
 		c.service.apiKey = apiKey
@@ -36,13 +53,68 @@ func WithModel(model string) ClientOption {
 	}
 }
 
+// WithTimeout 设置单次请求超时时间，<=0 时使用默认值
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		if timeout > 0 {
+			c.service.httpClient.Timeout = timeout
+		}
+	}
+}
+
+// WithTemperature 设置采样温度
+func WithTemperature(temperature float64) ClientOption {
+	return func(c *Client) {
+		c.service.temperature = &temperature
+	}
+}
+
+// WithMaxTokens 设置最大生成 token 数，0 表示使用服务端默认值
+func WithMaxTokens(maxTokens int) ClientOption {
+	return func(c *Client) {
+		c.service.maxTokens = maxTokens
+	}
+}
+
+// WithHTTPClient 使用自定义 HTTP 客户端
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) {
+		if httpClient != nil {
+			c.service.httpClient = httpClient
+		}
+	}
+}
+
 // Generate 调用 DeepSeek 生成回答
 func (c *Client) Generate(ctx context.Context, prompt string) (string, error) {
 	// 这里调用 DeepSeekQueryService 的实现
 	return c.service.Generate(ctx, prompt)
 }
 
+// Chat 以多轮消息调用 DeepSeek，返回回答及用量
+func (c *Client) Chat(ctx context.Context, messages []llm.Message) (*llm.ChatResponse, error) {
+	return c.service.Chat(ctx, messages)
+}
+
+// ChatStream 以流式方式调用 DeepSeek，逐段返回回答
+func (c *Client) ChatStream(ctx context.Context, messages []llm.Message) (<-chan llm.StreamChunk, error) {
+	return c.service.ChatStream(ctx, messages)
+}
+
 // Model 返回当前使用的模型名称
 func (c *Client) Model() string {
 	return c.service.model
 }
+
+// Info 返回模型信息
+func (c *Client) Info() llm.ModelInfo {
+	name := c.name
+	if name == "" {
+		name = c.service.model
+	}
+	return llm.ModelInfo{
+		Name:     name,
+		Provider: ProviderOpenAI,
+		Model:    c.service.model,
+	}
+}
@@ -1,26 +1,173 @@
 package deepseek
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/llm"
 )
 
+// 默认请求超时时间
+const defaultTimeout = 60 * time.Second
+
+// chatCompletionRequest OpenAI 兼容的 /v1/chat/completions 请求体
+type chatCompletionRequest struct {
+	Model       string        `json:"model"`
+	Messages    []llm.Message `json:"messages"`
+	Temperature *float64      `json:"temperature,omitempty"`
+	MaxTokens   int           `json:"max_tokens,omitempty"`
+	Stream      bool          `json:"stream"`
+}
+
+type chatCompletionResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message      llm.Message `json:"message"`
+		FinishReason string      `json:"finish_reason"`
+	} `json:"choices"`
+	Usage llm.Usage `json:"usage"`
+}
+
+type errorResponse struct {
+	Error struct {
+		Message string          `json:"message"`
+		Type    string          `json:"type"`
+		Code    json.RawMessage `json:"code"`
+	} `json:"error"`
+}
+
 type DeepSeekQueryService struct {
-	baseURL string
-	apiKey  string
-	model   string
+	baseURL     string
+	apiKey      string
+	model       string
+	temperature *float64
+	maxTokens   int
+	httpClient  *http.Client
 }
 
 func NewDeepSeekQueryService(baseURL, apiKey, model string) *DeepSeekQueryService {
 	return &DeepSeekQueryService{
-		baseURL: baseURL,
-		apiKey:  apiKey,
-		model:   model,
+		baseURL:    baseURL,
+		apiKey:     apiKey,
+		model:      model,
+		httpClient: &http.Client{Timeout: defaultTimeout},
 	}
 }
 
+// Generate 以单轮 user 消息调用模型并返回回答文本
 func (s *DeepSeekQueryService) Generate(ctx context.Context, prompt string) (string, error) {
-	// 实现具体的 DeepSeek API 调用逻辑
-	// 这里需要根据 DeepSeek 的 API 文档实现
-	// 返回生成的文本和可能的错误
-	return "Generated response", nil
+	resp, err := s.Chat(ctx, []llm.Message{{Role: "user", Content: prompt}})
+	if err != nil {
+		return "", err
+	}
+	return resp.Content, nil
+}
+
+// Chat 调用 OpenAI 兼容的 /v1/chat/completions 接口
+func (s *DeepSeekQueryService) Chat(ctx context.Context, messages []llm.Message) (*llm.ChatResponse, error) {
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("messages cannot be empty")
+	}
+
+	bodyBytes, err := json.Marshal(chatCompletionRequest{
+		Model:       s.model,
+		Messages:    messages,
+		Temperature: s.temperature,
+		MaxTokens:   s.maxTokens,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	req, err := s.newRequest(ctx, bodyBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, parseAPIError(resp, respBody)
+	}
+
+	var respData chatCompletionResponse
+	if err := json.Unmarshal(respBody, &respData); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if len(respData.Choices) == 0 {
+		return nil, fmt.Errorf("llm returned no choices")
+	}
+
+	choice := respData.Choices[0]
+	return &llm.ChatResponse{
+		Content:      choice.Message.Content,
+		Model:        respData.Model,
+		FinishReason: choice.FinishReason,
+		Usage:        respData.Usage,
+	}, nil
+}
+
+// endpoint 拼接 chat completions 地址，兼容 base_url 是否带 /v1
+func (s *DeepSeekQueryService) endpoint() string {
+	base := strings.TrimRight(s.baseURL, "/")
+	if strings.HasSuffix(base, "/chat/completions") {
+		return base
+	}
+	if strings.HasSuffix(base, "/v1") {
+		return base + "/chat/completions"
+	}
+	return base + "/v1/chat/completions"
+}
+
+func (s *DeepSeekQueryService) newRequest(ctx context.Context, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+	return req, nil
+}
+
+// parseAPIError 解析错误响应，兼容 OpenAI 风格 {"error": {...}} 及纯文本
+func parseAPIError(resp *http.Response, body []byte) error {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+
+	var errResp errorResponse
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error.Message != "" {
+		apiErr.Message = errResp.Error.Message
+		apiErr.Type = errResp.Error.Type
+		apiErr.Code = strings.Trim(string(errResp.Error.Code), `"`)
+		if apiErr.Code == "null" {
+			apiErr.Code = ""
+		}
+	} else {
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			apiErr.RetryAfter = time.Duration(seconds) * time.Second
+		}
+	}
+
+	return apiErr
 }
@@ -0,0 +1,206 @@
+package deepseek
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/llm"
+)
+
+func TestChatSendsRequestAndParsesResponse(t *testing.T) {
+	var gotPath, gotAuth, gotContentType string
+	var gotBody map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotAuth = r.Header.Get("Authorization")
+		gotContentType = r.Header.Get("Content-Type")
+		if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"model": "deepseek-chat",
+			"choices": [{"message": {"role": "assistant", "content": "Paris"}, "finish_reason": "stop"}],
+			"usage": {"prompt_tokens": 12, "completion_tokens": 1, "total_tokens": 13}
+		}`))
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL,
+		WithAPIKey("sk-test"),
+		WithModel("deepseek-chat"),
+		WithTemperature(0.2),
+		WithMaxTokens(256),
+	)
+	resp, err := client.Chat(context.Background(), []llm.Message{
+		{Role: "system", Content: "Answer briefly."},
+		{Role: "user", Content: "Capital of France?"},
+	})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	if gotPath != "/v1/chat/completions" {
+		t.Errorf("path = %q, want /v1/chat/completions", gotPath)
+	}
+	if gotAuth != "Bearer sk-test" {
+		t.Errorf("Authorization = %q, want Bearer sk-test", gotAuth)
+	}
+	if gotContentType != "application/json" {
+		t.Errorf("Content-Type = %q", gotContentType)
+	}
+	if gotBody["model"] != "deepseek-chat" || gotBody["temperature"] != 0.2 || gotBody["max_tokens"] != float64(256) || gotBody["stream"] != false {
+		t.Errorf("request body = %v", gotBody)
+	}
+	if messages, _ := gotBody["messages"].([]interface{}); len(messages) != 2 {
+		t.Errorf("messages = %v, want 2 messages", gotBody["messages"])
+	}
+
+	if resp.Content != "Paris" || resp.Model != "deepseek-chat" || resp.FinishReason != "stop" {
+		t.Errorf("response = %+v", resp)
+	}
+	if resp.Usage.TotalTokens != 13 {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+}
+
+func TestGenerateOmitsUnsetOptions(t *testing.T) {
+	var gotBody map[string]interface{}
+	var gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "ok"}}]}`))
+	}))
+	defer srv.Close()
+
+	// base_url 已带 /v1 时不重复拼接
+	client := NewClient(srv.URL+"/v1", WithModel("llama3"))
+	answer, err := client.Generate(context.Background(), "ping")
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if answer != "ok" {
+		t.Errorf("answer = %q, want ok", answer)
+	}
+	if gotAuth != "" {
+		t.Errorf("Authorization = %q, want none without API key", gotAuth)
+	}
+	if _, ok := gotBody["temperature"]; ok {
+		t.Errorf("temperature sent although not configured: %v", gotBody)
+	}
+	if _, ok := gotBody["max_tokens"]; ok {
+		t.Errorf("max_tokens sent although not configured: %v", gotBody)
+	}
+}
+
+func TestChatTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	client := NewClient(srv.URL, WithTimeout(50*time.Millisecond))
+	start := time.Now()
+	_, err := client.Generate(context.Background(), "slow")
+	if err == nil {
+		t.Fatal("expected timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("request took %v, timeout not honored", elapsed)
+	}
+}
+
+func TestChatTypedErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		retryAfter string
+		want       error
+	}{
+		{
+			name:       "rate limited",
+			status:     http.StatusTooManyRequests,
+			body:       `{"error": {"message": "Rate limit reached", "type": "rate_limit_error"}}`,
+			retryAfter: "7",
+			want:       ErrRateLimited,
+		},
+		{
+			name:   "context length code",
+			status: http.StatusBadRequest,
+			body:   `{"error": {"message": "too long", "type": "invalid_request_error", "code": "context_length_exceeded"}}`,
+			want:   ErrContextLengthExceeded,
+		},
+		{
+			name:   "context length message",
+			status: http.StatusBadRequest,
+			body:   `{"error": {"message": "This model's maximum context length is 65536 tokens", "code": null}}`,
+			want:   ErrContextLengthExceeded,
+		},
+		{
+			name:   "unauthorized",
+			status: http.StatusUnauthorized,
+			body:   `{"error": {"message": "Authentication Fails (invalid api key)", "type": "authentication_error"}}`,
+			want:   ErrUnauthorized,
+		},
+		{
+			name:   "forbidden",
+			status: http.StatusForbidden,
+			body:   "forbidden",
+			want:   ErrUnauthorized,
+		},
+		{
+			name:   "server error",
+			status: http.StatusInternalServerError,
+			body:   "internal error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			_, err := NewClient(srv.URL).Generate(context.Background(), "hi")
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("error %v is not an *APIError", err)
+			}
+			if apiErr.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", apiErr.StatusCode, tt.status)
+			}
+			for _, sentinel := range []error{ErrRateLimited, ErrContextLengthExceeded, ErrUnauthorized} {
+				if got, want := errors.Is(err, sentinel), sentinel == tt.want; got != want {
+					t.Errorf("errors.Is(err, %v) = %v, want %v", sentinel, got, want)
+				}
+			}
+			if tt.retryAfter != "" && apiErr.RetryAfter != 7*time.Second {
+				t.Errorf("RetryAfter = %v, want 7s", apiErr.RetryAfter)
+			}
+			if !strings.Contains(err.Error(), strings.TrimSpace(apiErr.Message)) {
+				t.Errorf("error message %q does not include API message %q", err.Error(), apiErr.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package deepseek
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/llm"
+)
+
+// 与领域层共用的哨兵错误，便于调用方不依赖具体提供方判断错误类型
+var (
+	// ErrRateLimited 请求被限流(HTTP 429)
+	ErrRateLimited = llm.ErrRateLimited
+	// ErrContextLengthExceeded 提示词超出模型上下文长度
+	ErrContextLengthExceeded = llm.ErrContextLengthExceeded
+	// ErrUnauthorized API Key 无效或无权限(HTTP 401/403)
+	ErrUnauthorized = llm.ErrUnauthorized
+)
+
+// APIError 表示 LLM 服务返回的非 2xx 响应
+type APIError struct {
+	StatusCode int
+	Type       string
+	Code       string
+	Message    string
+	RetryAfter time.Duration // 限流时服务端建议的重试间隔，未提供时为0
+}
+
+func (e *APIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("llm api error (status %d, code %s): %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("llm api error (status %d): %s", e.StatusCode, e.Message)
+}
+
+// Unwrap 将 APIError 映射到对应的哨兵错误，便于调用方使用 errors.Is 判断
+func (e *APIError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden:
+		return ErrUnauthorized
+	case isContextLengthError(e.Code, e.Message):
+		return ErrContextLengthExceeded
+	default:
+		return nil
+	}
+}
+
+// isContextLengthError 兼容 OpenAI/DeepSeek/Ollama 不同的上下文超长报错
+func isContextLengthError(code, message string) bool {
+	if code == "context_length_exceeded" {
+		return true
+	}
+	msg := strings.ToLower(message)
+	return strings.Contains(msg, "maximum context length") ||
+		strings.Contains(msg, "context length") ||
+		strings.Contains(msg, "context window")
+}
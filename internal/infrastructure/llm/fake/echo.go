@@ -0,0 +1,102 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/llm"
+)
+
+// Provider 回显模型的提供方标识
+const Provider = "echo"
+
+// EchoModel 是确定性的假模型，回显最后一条 user 消息，用于测试和离线开发
+type EchoModel struct {
+	name   string
+	prefix string
+}
+
+var _ llm.ChatModel = (*EchoModel)(nil)
+
+// NewEchoModel 创建回显模型，prefix 会被加在回答前
+func NewEchoModel(name, prefix string) *EchoModel {
+	if name == "" {
+		name = Provider
+	}
+	return &EchoModel{name: name, prefix: prefix}
+}
+
+func (m *EchoModel) Chat(ctx context.Context, messages []llm.Message) (*llm.ChatResponse, error) {
+	answer, err := m.answer(messages)
+	if err != nil {
+		return nil, err
+	}
+	return &llm.ChatResponse{
+		Content:      answer,
+		Model:        m.name,
+		FinishReason: "stop",
+		Usage:        m.usage(messages, answer),
+	}, nil
+}
+
+// ChatStream 按空白切分回答逐段输出
+func (m *EchoModel) ChatStream(ctx context.Context, messages []llm.Message) (<-chan llm.StreamChunk, error) {
+	answer, err := m.answer(messages)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make(chan llm.StreamChunk)
+	go func() {
+		defer close(chunks)
+		for _, word := range strings.SplitAfter(answer, " ") {
+			if word == "" {
+				continue
+			}
+			select {
+			case chunks <- llm.StreamChunk{Content: word}:
+			case <-ctx.Done():
+				return
+			}
+		}
+		usage := m.usage(messages, answer)
+		select {
+		case chunks <- llm.StreamChunk{Done: true, FinishReason: "stop", Usage: &usage}:
+		case <-ctx.Done():
+		}
+	}()
+	return chunks, nil
+}
+
+func (m *EchoModel) Info() llm.ModelInfo {
+	return llm.ModelInfo{
+		Name:     m.name,
+		Provider: Provider,
+		Model:    Provider,
+	}
+}
+
+func (m *EchoModel) answer(messages []llm.Message) (string, error) {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return m.prefix + messages[i].Content, nil
+		}
+	}
+	return "", fmt.Errorf("no user message to echo")
+}
+
+// usage 以字符数近似 token 数，保证结果确定
+func (m *EchoModel) usage(messages []llm.Message, answer string) llm.Usage {
+	prompt := 0
+	for _, msg := range messages {
+		prompt += utf8.RuneCountInString(msg.Content)
+	}
+	completion := utf8.RuneCountInString(answer)
+	return llm.Usage{
+		PromptTokens:     prompt,
+		CompletionTokens: completion,
+		TotalTokens:      prompt + completion,
+	}
+}
@@ -0,0 +1,98 @@
+package deepseek
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/llm"
+)
+
+// SendFunc 发送一个流式片段，ctx 取消后返回 false
+type SendFunc func(chunk llm.StreamChunk) bool
+
+// LineStream 逐行读取的流式请求，供 SSE 和 NDJSON 接口共用
+type LineStream struct {
+	// NewRequest 以 StreamLines 派生的 ctx 创建请求，ctx 取消时中断上游请求
+	NewRequest func(ctx context.Context) (*http.Request, error)
+	// ParseError 将非 2xx 响应转换为错误
+	ParseError func(resp *http.Response, body []byte) error
+	// HandleLine 处理一行响应，返回 false 时停止读取，此时由 HandleLine 负责发送最后的片段
+	HandleLine func(line []byte, send SendFunc) bool
+	// OnEOF 响应读完仍未停止时调用(可选)，用于发送结束片段或报告流被截断
+	OnEOF func(send SendFunc)
+}
+
+// StreamLines 发送流式请求，在后台逐行读取响应并返回片段通道，ctx 取消时中断上游请求并关闭通道
+// 流式响应耗时不可预知，httpClient.Timeout 仅约束等待响应头的时间，收到响应头后只由 ctx 控制
+func StreamLines(ctx context.Context, httpClient *http.Client, s LineStream) (<-chan llm.StreamChunk, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	req, err := s.NewRequest(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	streamClient := *httpClient
+	streamClient.Timeout = 0
+	timeout := httpClient.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	timer := time.AfterFunc(timeout, cancel)
+	resp, err := streamClient.Do(req)
+	// 读取响应体之前停止计时；计时器已触发时请求已被取消，不再读取
+	if !timer.Stop() && err == nil {
+		resp.Body.Close()
+		err = fmt.Errorf("no response headers within %v", timeout)
+	}
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer cancel()
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, s.ParseError(resp, respBody)
+	}
+
+	chunks := make(chan llm.StreamChunk)
+	go func() {
+		defer close(chunks)
+		defer cancel()
+		defer resp.Body.Close()
+
+		send := func(chunk llm.StreamChunk) bool {
+			select {
+			case chunks <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			if !s.HandleLine(scanner.Bytes(), send) {
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			if ctx.Err() == nil {
+				send(llm.StreamChunk{Err: fmt.Errorf("failed to read stream: %w", err)})
+			}
+			return
+		}
+		if s.OnEOF != nil && ctx.Err() == nil {
+			s.OnEOF(send)
+		}
+	}()
+
+	return chunks, nil
+}
@@ -0,0 +1,88 @@
+package deepseek
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/llm"
+)
+
+func collect(t *testing.T, chunks <-chan llm.StreamChunk) (string, llm.StreamChunk) {
+	t.Helper()
+	var content strings.Builder
+	for chunk := range chunks {
+		if chunk.Err != nil || chunk.Done {
+			return content.String(), chunk
+		}
+		content.WriteString(chunk.Content)
+	}
+	t.Fatal("stream closed without a final chunk")
+	return "", llm.StreamChunk{}
+}
+
+func TestChatStreamOutlivesHeaderTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		for i, piece := range []string{"Hel", "lo"} {
+			fmt.Fprintf(w, "data: {\"choices\": [{\"delta\": {\"content\": %q}}]}\n\n", piece)
+			flusher.Flush()
+			if i == 0 {
+				// 响应头已发出，之后的间隔超过 Timeout 也不应中断
+				time.Sleep(150 * time.Millisecond)
+			}
+		}
+		fmt.Fprint(w, "data: {\"choices\": [{\"delta\": {}, \"finish_reason\": \"stop\"}], \"usage\": {\"total_tokens\": 5}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, WithTimeout(50*time.Millisecond))
+	chunks, err := client.ChatStream(context.Background(), []llm.Message{{Role: "user", Content: "hi"}})
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+	content, last := collect(t, chunks)
+	if last.Err != nil {
+		t.Fatalf("stream error: %v", last.Err)
+	}
+	if content != "Hello" || last.FinishReason != "stop" || last.Usage == nil || last.Usage.TotalTokens != 5 {
+		t.Errorf("content = %q, final chunk = %+v", content, last)
+	}
+}
+
+func TestChatStreamHeaderTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	client := NewClient(srv.URL, WithTimeout(50*time.Millisecond))
+	if _, err := client.ChatStream(context.Background(), []llm.Message{{Role: "user", Content: "hi"}}); err == nil {
+		t.Fatal("expected timeout waiting for response headers")
+	}
+}
+
+func TestChatStreamAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error": {"message": "slow down"}}`))
+	}))
+	defer srv.Close()
+
+	_, err := NewClient(srv.URL).ChatStream(context.Background(), []llm.Message{{Role: "user", Content: "hi"}})
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("err = %v, want ErrRateLimited", err)
+	}
+}
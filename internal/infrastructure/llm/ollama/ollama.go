@@ -0,0 +1,274 @@
+package ollama
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/llm"
+	deepseek "github.com/redhander/AIKnowledgeBaseMiddleware/internal/infrastructure/llm"
+)
+
+// Provider Ollama 原生接口的提供方标识
+const Provider = "ollama"
+
+// 默认请求超时时间
+const defaultTimeout = 60 * time.Second
+
+// Client 调用 Ollama 原生 /api/chat 接口，实现 llm.ChatModel
+type Client struct {
+	baseURL     string
+	name        string
+	model       string
+	temperature *float64
+	maxTokens   int
+	httpClient  *http.Client
+}
+
+var _ llm.ChatModel = (*Client)(nil)
+
+type ClientOption func(*Client)
+
+// NewClient 创建 Ollama 客户端，baseURL 形如 http://localhost:11434
+func NewClient(baseURL, model string, opts ...ClientOption) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		model:      model,
+		httpClient: &http.Client{Timeout: defaultTimeout},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithName 设置模型在配置中的名称，默认与模型标识相同
+func WithName(name string) ClientOption {
+	return func(c *Client) {
+		c.name = name
+	}
+}
+
+// WithTimeout 设置单次请求超时时间，<=0 时使用默认值
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		if timeout > 0 {
+			c.httpClient.Timeout = timeout
+		}
+	}
+}
+
+// WithTemperature 设置采样温度
+func WithTemperature(temperature float64) ClientOption {
+	return func(c *Client) {
+		c.temperature = &temperature
+	}
+}
+
+// WithMaxTokens 设置最大生成 token 数(num_predict)，0 表示使用模型默认值
+func WithMaxTokens(maxTokens int) ClientOption {
+	return func(c *Client) {
+		c.maxTokens = maxTokens
+	}
+}
+
+// WithHTTPClient 使用自定义 HTTP 客户端
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) {
+		if httpClient != nil {
+			c.httpClient = httpClient
+		}
+	}
+}
+
+type chatOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	NumPredict  int      `json:"num_predict,omitempty"`
+}
+
+type chatRequest struct {
+	Model    string        `json:"model"`
+	Messages []llm.Message `json:"messages"`
+	Stream   bool          `json:"stream"`
+	Options  *chatOptions  `json:"options,omitempty"`
+}
+
+type chatResponse struct {
+	Model           string      `json:"model"`
+	Message         llm.Message `json:"message"`
+	Done            bool        `json:"done"`
+	DoneReason      string      `json:"done_reason"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+	EvalCount       int         `json:"eval_count"`
+	Error           string      `json:"error"`
+}
+
+func (r *chatResponse) usage() llm.Usage {
+	return llm.Usage{
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
+		TotalTokens:      r.PromptEvalCount + r.EvalCount,
+	}
+}
+
+// APIError 表示 Ollama 返回的非 2xx 响应
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("ollama api error (status %d): %s", e.StatusCode, e.Message)
+}
+
+// Unwrap 将 APIError 映射到领域层的哨兵错误
+func (e *APIError) Unwrap() error {
+	msg := strings.ToLower(e.Message)
+	switch {
+	case e.StatusCode == http.StatusTooManyRequests:
+		return llm.ErrRateLimited
+	case e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden:
+		return llm.ErrUnauthorized
+	case strings.Contains(msg, "context length") || strings.Contains(msg, "context window"):
+		return llm.ErrContextLengthExceeded
+	default:
+		return nil
+	}
+}
+
+// Chat 调用 /api/chat 一次性返回回答
+func (c *Client) Chat(ctx context.Context, messages []llm.Message) (*llm.ChatResponse, error) {
+	req, err := c.newRequest(ctx, messages, false)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, parseAPIError(resp.StatusCode, respBody)
+	}
+
+	var respData chatResponse
+	if err := json.Unmarshal(respBody, &respData); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if respData.Error != "" {
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: respData.Error}
+	}
+
+	return &llm.ChatResponse{
+		Content:      respData.Message.Content,
+		Model:        respData.Model,
+		FinishReason: respData.DoneReason,
+		Usage:        respData.usage(),
+	}, nil
+}
+
+// ChatStream 调用 /api/chat 并逐行解析 NDJSON 流
+func (c *Client) ChatStream(ctx context.Context, messages []llm.Message) (<-chan llm.StreamChunk, error) {
+	return deepseek.StreamLines(ctx, c.httpClient, deepseek.LineStream{
+		NewRequest: func(ctx context.Context) (*http.Request, error) {
+			return c.newRequest(ctx, messages, true)
+		},
+		ParseError: func(resp *http.Response, body []byte) error {
+			return parseAPIError(resp.StatusCode, body)
+		},
+		HandleLine: func(line []byte, send deepseek.SendFunc) bool {
+			line = bytes.TrimSpace(line)
+			if len(line) == 0 {
+				return true
+			}
+
+			var event chatResponse
+			if err := json.Unmarshal(line, &event); err != nil {
+				send(llm.StreamChunk{Err: fmt.Errorf("failed to decode stream event: %w", err)})
+				return false
+			}
+			if event.Error != "" {
+				send(llm.StreamChunk{Err: &APIError{StatusCode: http.StatusOK, Message: event.Error}})
+				return false
+			}
+			if event.Message.Content != "" {
+				if !send(llm.StreamChunk{Content: event.Message.Content}) {
+					return false
+				}
+			}
+			if event.Done {
+				usage := event.usage()
+				send(llm.StreamChunk{Done: true, FinishReason: event.DoneReason, Usage: &usage})
+				return false
+			}
+			return true
+		},
+		OnEOF: func(send deepseek.SendFunc) {
+			send(llm.StreamChunk{Err: fmt.Errorf("stream ended before completion")})
+		},
+	})
+}
+
+// Info 返回模型信息
+func (c *Client) Info() llm.ModelInfo {
+	name := c.name
+	if name == "" {
+		name = c.model
+	}
+	return llm.ModelInfo{
+		Name:     name,
+		Provider: Provider,
+		Model:    c.model,
+	}
+}
+
+func (c *Client) newRequest(ctx context.Context, messages []llm.Message, stream bool) (*http.Request, error) {
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("messages cannot be empty")
+	}
+
+	reqBody := chatRequest{
+		Model:    c.model,
+		Messages: messages,
+		Stream:   stream,
+	}
+	if c.temperature != nil || c.maxTokens > 0 {
+		reqBody.Options = &chatOptions{
+			Temperature: c.temperature,
+			NumPredict:  c.maxTokens,
+		}
+	}
+	bodyBytes, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/chat", bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// parseAPIError 解析 Ollama 错误响应 {"error": "..."}
+func parseAPIError(statusCode int, body []byte) error {
+	var errResp struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error != "" {
+		return &APIError{StatusCode: statusCode, Message: errResp.Error}
+	}
+	return &APIError{StatusCode: statusCode, Message: strings.TrimSpace(string(body))}
+}
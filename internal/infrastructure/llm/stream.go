@@ -0,0 +1,104 @@
+package deepseek
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/llm"
+)
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type chatCompletionStreamRequest struct {
+	chatCompletionRequest
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+}
+
+type chatCompletionStreamResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *llm.Usage `json:"usage"`
+}
+
+// ChatStream 以流式方式调用 /v1/chat/completions，返回增量片段通道
+// ctx 取消时会中断上游请求并关闭通道
+func (s *DeepSeekQueryService) ChatStream(ctx context.Context, messages []llm.Message) (<-chan llm.StreamChunk, error) {
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("messages cannot be empty")
+	}
+
+	reqBody := chatCompletionStreamRequest{
+		chatCompletionRequest: chatCompletionRequest{
+			Model:       s.model,
+			Messages:    messages,
+			Temperature: s.temperature,
+			MaxTokens:   s.maxTokens,
+			Stream:      true,
+		},
+		StreamOptions: &streamOptions{IncludeUsage: true},
+	}
+	bodyBytes, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	var usage *llm.Usage
+	var finishReason string
+	return StreamLines(ctx, s.httpClient, LineStream{
+		NewRequest: func(ctx context.Context) (*http.Request, error) {
+			req, err := s.newRequest(ctx, bodyBytes)
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Accept", "text/event-stream")
+			return req, nil
+		},
+		ParseError: parseAPIError,
+		HandleLine: func(line []byte, send SendFunc) bool {
+			text := strings.TrimSpace(string(line))
+			if !strings.HasPrefix(text, "data:") {
+				return true
+			}
+			data := strings.TrimSpace(strings.TrimPrefix(text, "data:"))
+			if data == "[DONE]" {
+				send(llm.StreamChunk{Done: true, FinishReason: finishReason, Usage: usage})
+				return false
+			}
+
+			var event chatCompletionStreamResponse
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				send(llm.StreamChunk{Err: fmt.Errorf("failed to decode stream event: %w", err)})
+				return false
+			}
+			if event.Usage != nil {
+				usage = event.Usage
+			}
+			for _, choice := range event.Choices {
+				if choice.FinishReason != nil {
+					finishReason = *choice.FinishReason
+				}
+				if choice.Delta.Content == "" {
+					continue
+				}
+				if !send(llm.StreamChunk{Content: choice.Delta.Content}) {
+					return false
+				}
+			}
+			return true
+		},
+		// 未收到 [DONE] 时以读完响应作为结束
+		OnEOF: func(send SendFunc) {
+			send(llm.StreamChunk{Done: true, FinishReason: finishReason, Usage: usage})
+		},
+	})
+}
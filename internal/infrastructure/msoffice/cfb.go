@@ -0,0 +1,94 @@
+// Package msoffice 读取 Office 97-2003 二进制格式(复合文档)中的文本内容
+package msoffice
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/richardlehane/mscfb"
+)
+
+var (
+	// ErrEncrypted 文档设置了打开密码
+	ErrEncrypted = errors.New("encrypted document is not supported")
+	// ErrUnsupportedFormat 不是可识别的 Office 97-2003 文档，或版本过旧
+	ErrUnsupportedFormat = errors.New("unsupported office document format")
+)
+
+// readStreams 读取复合文档中指定名称的数据流，缺少 required 中的流时返回 ErrUnsupportedFormat
+func readStreams(r io.ReaderAt, required []string, optional ...string) (map[string][]byte, error) {
+	doc, err := mscfb.New(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	}
+
+	wanted := make(map[string]bool)
+	for _, name := range append(append([]string(nil), required...), optional...) {
+		wanted[name] = true
+	}
+	streams := make(map[string][]byte)
+	for entry, err := doc.Next(); err == nil; entry, err = doc.Next() {
+		if !wanted[entry.Name] || len(entry.Path) > 0 {
+			continue
+		}
+		data, err := io.ReadAll(entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stream %s: %w", entry.Name, err)
+		}
+		streams[entry.Name] = data
+	}
+	for _, name := range required {
+		if _, ok := streams[name]; !ok {
+			return nil, fmt.Errorf("%w: missing %s stream", ErrUnsupportedFormat, name)
+		}
+	}
+	return streams, nil
+}
+
+func le16(b []byte) int {
+	if len(b) < 2 {
+		return 0
+	}
+	return int(binary.LittleEndian.Uint16(b))
+}
+
+func le32(b []byte) uint32 {
+	if len(b) < 4 {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+// Format 复合文档所属的 Office 应用
+type Format int
+
+const (
+	FormatUnknown Format = iota
+	FormatWord
+	FormatExcel
+	FormatPowerPoint
+)
+
+// Identify 按复合文档中的数据流名称识别 Word、Excel 或 PowerPoint 文档
+func Identify(r io.ReaderAt) (Format, error) {
+	doc, err := mscfb.New(r)
+	if err != nil {
+		return FormatUnknown, fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	}
+	for entry, err := doc.Next(); err == nil; entry, err = doc.Next() {
+		if len(entry.Path) > 0 {
+			continue
+		}
+		switch entry.Name {
+		case "WordDocument":
+			return FormatWord, nil
+		case "Workbook", "Book":
+			return FormatExcel, nil
+		case "PowerPoint Document":
+			return FormatPowerPoint, nil
+		}
+	}
+	return FormatUnknown, nil
+}
@@ -0,0 +1,400 @@
+package msoffice
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Word 97-2003 正文中的特殊字符
+const (
+	chCellMark   = 0x07 // 单元格结束，所在段落为行结束段落(TTP)时表示行结束
+	chTab        = 0x09
+	chLineBreak  = 0x0B
+	chPageBreak  = 0x0C
+	chParagraph  = 0x0D
+	chFieldBegin = 0x13
+	chFieldSep   = 0x14
+	chFieldEnd   = 0x15
+	chNBHyphen   = 0x1E
+)
+
+// FIB 中的字段
+const (
+	fibMagic         = 0xA5EC
+	fibWord97        = 0x00C1
+	fibEncrypted     = 0x0100
+	fibWhichTable    = 0x0200
+	fcPlcfBtePapxIdx = 13 // FibRgFcLcb97 中 fcPlcfBtePapx/lcbPlcfBtePapx 的序号
+	fcClxIdx         = 33 // FibRgFcLcb97 中 fcClx/lcbClx 的序号
+)
+
+// 段落属性
+const (
+	sprmPFInTable  = 0x2416
+	sprmPFTtp      = 0x2417
+	sprmPFInnerTtp = 0x244C
+	sprmTDefTable  = 0xD608
+	fkpSize        = 512
+)
+
+// Paragraph Word 文档正文中的一个段落或表格
+type Paragraph struct {
+	Text  string
+	Level int  // 标题层级 1-9，非标题为0
+	Table bool // 表格，每行一行文本，单元格以制表符分隔
+}
+
+// ReadDoc 读取 Word 97-2003 文档的正文(不含页眉页脚、脚注和批注)，返回非空段落
+// 连续的表格行合并为一个 Table 段落；域只保留显示结果；使用内置标题样式(Heading 1-9)的段落设置 Level
+func ReadDoc(r io.ReaderAt) ([]Paragraph, error) {
+	streams, err := readStreams(r, []string{"WordDocument"}, "0Table", "1Table")
+	if err != nil {
+		return nil, err
+	}
+	return parseDoc(streams)
+}
+
+// parseDoc 解析 WordDocument 流及其引用的 Table 流
+func parseDoc(streams map[string][]byte) ([]Paragraph, error) {
+	wd := streams["WordDocument"]
+	if len(wd) < 34 || le16(wd) != fibMagic {
+		return nil, fmt.Errorf("%w: invalid WordDocument header", ErrUnsupportedFormat)
+	}
+	if le16(wd[2:]) < fibWord97 {
+		return nil, fmt.Errorf("%w: Word 95 or earlier document", ErrUnsupportedFormat)
+	}
+	flags := le16(wd[0x0A:])
+	if flags&fibEncrypted != 0 {
+		return nil, ErrEncrypted
+	}
+	tableName := "0Table"
+	if flags&fibWhichTable != 0 {
+		tableName = "1Table"
+	}
+	table, ok := streams[tableName]
+	if !ok {
+		return nil, fmt.Errorf("%w: missing %s stream", ErrUnsupportedFormat, tableName)
+	}
+
+	// FibBase 之后依次为 FibRgW、FibRgLw、FibRgFcLcb，各自以长度开头
+	pos := 32
+	pos += 2 + le16(wd[pos:])*2
+	if pos+2+16 > len(wd) {
+		return nil, fmt.Errorf("%w: truncated FIB", ErrUnsupportedFormat)
+	}
+	ccpText := int(le32(wd[pos+2+12:]))
+	pos += 2 + le16(wd[pos:])*4
+	if pos+2 > len(wd) || le16(wd[pos:]) <= fcClxIdx || pos+2+(fcClxIdx+1)*8 > len(wd) {
+		return nil, fmt.Errorf("%w: truncated FIB", ErrUnsupportedFormat)
+	}
+	fcLcb := wd[pos+2:]
+
+	clx, err := tableData(table, fcLcb, fcClxIdx)
+	if err != nil {
+		return nil, err
+	}
+	pieces, err := readPieceTable(clx)
+	if err != nil {
+		return nil, err
+	}
+	text, err := pieces.text(wd, ccpText)
+	if err != nil {
+		return nil, err
+	}
+	// 段落属性缺失时仍可输出文本，只是无法识别标题和表格行
+	var props paragraphProps
+	if plc, err := tableData(table, fcLcb, fcPlcfBtePapxIdx); err == nil {
+		props = readParagraphProps(wd, plc)
+	}
+	return docParagraphs(text, props), nil
+}
+
+// tableData 返回 FibRgFcLcb 中第 idx 项指向的 Table 流数据
+func tableData(table, fcLcb []byte, idx int) ([]byte, error) {
+	fc, lcb := int(le32(fcLcb[idx*8:])), int(le32(fcLcb[idx*8+4:]))
+	if fc < 0 || lcb <= 0 || fc+lcb > len(table) {
+		return nil, fmt.Errorf("%w: invalid table stream offset", ErrUnsupportedFormat)
+	}
+	return table[fc : fc+lcb], nil
+}
+
+// piece 片段表中的一段文本：字符位置 [cpStart, cpEnd)，compressed 为单字节(cp1252)存储
+type piece struct {
+	cpStart, cpEnd int
+	fc             int
+	compressed     bool
+}
+
+type pieceTable []piece
+
+// readPieceTable 跳过 Clx 中的格式(Prc)，读取片段表(Pcdt)
+func readPieceTable(clx []byte) (pieceTable, error) {
+	for i := 0; i < len(clx); {
+		switch clx[i] {
+		case 0x01:
+			i += 3 + le16(clx[i+1:])
+		case 0x02:
+			if i+5 > len(clx) {
+				return nil, fmt.Errorf("%w: truncated piece table", ErrUnsupportedFormat)
+			}
+			lcb := int(le32(clx[i+1:]))
+			plc := clx[i+5:]
+			if lcb < 4 || lcb > len(plc) || (lcb-4)%12 != 0 {
+				return nil, fmt.Errorf("%w: invalid piece table size", ErrUnsupportedFormat)
+			}
+			// PlcPcd: n+1 个字符位置，之后为 n 个8字节的片段描述
+			n := (lcb - 4) / 12
+			pieces := make(pieceTable, n)
+			for k := range pieces {
+				fc := le32(plc[4*(n+1)+8*k+2:])
+				pieces[k] = piece{
+					cpStart:    int(le32(plc[4*k:])),
+					cpEnd:      int(le32(plc[4*(k+1):])),
+					fc:         int(fc & 0x3FFFFFFF),
+					compressed: fc&0x40000000 != 0,
+				}
+			}
+			return pieces, nil
+		default:
+			return nil, fmt.Errorf("%w: invalid Clx", ErrUnsupportedFormat)
+		}
+	}
+	return nil, fmt.Errorf("%w: missing piece table", ErrUnsupportedFormat)
+}
+
+// docChar 正文字符及其在 WordDocument 流中的位置
+type docChar struct {
+	r  rune
+	fc int
+}
+
+// text 返回正文中前 ccp 个字符
+func (pt pieceTable) text(wd []byte, ccp int) ([]docChar, error) {
+	var chars []docChar
+	for _, p := range pt {
+		end := min(p.cpEnd, ccp)
+		if end <= p.cpStart {
+			continue
+		}
+		n := end - p.cpStart
+		// 每个字符至少占 WordDocument 流中的一个字节，片段相互重叠时总长度会超过流长度
+		if len(chars)+n > len(wd) {
+			return nil, fmt.Errorf("%w: piece table exceeds document size", ErrUnsupportedFormat)
+		}
+		if p.compressed {
+			start := p.fc / 2
+			if start+n > len(wd) {
+				return nil, fmt.Errorf("%w: piece out of range", ErrUnsupportedFormat)
+			}
+			dec := charmap.Windows1252
+			for i, b := range wd[start : start+n] {
+				chars = append(chars, docChar{r: dec.DecodeByte(b), fc: start + i})
+			}
+			continue
+		}
+		if p.fc+2*n > len(wd) {
+			return nil, fmt.Errorf("%w: piece out of range", ErrUnsupportedFormat)
+		}
+		// 代理对按两个字符存储，合并为一个字符
+		for i := 0; i < n; i++ {
+			fc := p.fc + 2*i
+			r := rune(le16(wd[fc:]))
+			if r >= 0xD800 && r < 0xDC00 && i+1 < n {
+				if lo := rune(le16(wd[fc+2:])); lo >= 0xDC00 && lo < 0xE000 {
+					r = 0x10000 + (r-0xD800)<<10 + (lo - 0xDC00)
+					i++
+				}
+			}
+			chars = append(chars, docChar{r: r, fc: fc})
+		}
+	}
+	return chars, nil
+}
+
+// paragraphProp 以 [fcStart, fcEnd) 结尾字符所在段落的属性
+type paragraphProp struct {
+	fcStart, fcEnd int
+	istd           int
+	inTable, ttp   bool
+}
+
+type paragraphProps []paragraphProp
+
+// readParagraphProps 从 PlcBtePapx 指向的各个 FKP 页读取段落的样式和表格属性
+func readParagraphProps(wd, plc []byte) paragraphProps {
+	if len(plc) < 4 || (len(plc)-4)%8 != 0 {
+		return nil
+	}
+	n := (len(plc) - 4) / 8
+	var props paragraphProps
+	for k := 0; k < n; k++ {
+		pn := int(le32(plc[4*(n+1)+4*k:]) & 0x3FFFFF)
+		if (pn+1)*fkpSize > len(wd) {
+			continue
+		}
+		page := wd[pn*fkpSize : (pn+1)*fkpSize]
+		crun := int(page[fkpSize-1])
+		for i := 0; i < crun && 4*(crun+1)+13*i < fkpSize-1; i++ {
+			prop := paragraphProp{fcStart: int(le32(page[4*i:])), fcEnd: int(le32(page[4*(i+1):]))}
+			if off := 2 * int(page[4*(crun+1)+13*i]); off > 0 {
+				prop.parse(papxInFkp(page, off))
+			}
+			props = append(props, prop)
+		}
+	}
+	sort.Slice(props, func(i, j int) bool { return props[i].fcStart < props[j].fcStart })
+	return props
+}
+
+// papxInFkp 返回 FKP 页中 off 处的 GrpPrlAndIstd
+func papxInFkp(page []byte, off int) []byte {
+	if off >= fkpSize-1 {
+		return nil
+	}
+	size, start := 2*int(page[off])-1, off+1
+	if page[off] == 0 {
+		size, start = 2*int(page[off+1]), off+2
+	}
+	if size < 0 || start+size > fkpSize-1 {
+		return nil
+	}
+	return page[start : start+size]
+}
+
+// parse 读取样式下标和表格相关的 Sprm
+func (p *paragraphProp) parse(grpprl []byte) {
+	if len(grpprl) < 2 {
+		return
+	}
+	p.istd = le16(grpprl)
+	for i := 2; i+2 <= len(grpprl); {
+		op := le16(grpprl[i:])
+		i += 2
+		size := sprmOperandSize(op, grpprl[i:])
+		if size < 0 || i+size > len(grpprl) {
+			return
+		}
+		if size > 0 && grpprl[i] != 0 {
+			switch op {
+			case sprmPFInTable:
+				p.inTable = true
+			case sprmPFTtp, sprmPFInnerTtp:
+				p.ttp = true
+			}
+		}
+		i += size
+	}
+}
+
+// sprmOperandSize 按 Sprm 的 spra 字段返回操作数长度，无法确定时返回 -1
+func sprmOperandSize(op int, operand []byte) int {
+	switch op >> 13 {
+	case 0, 1:
+		return 1
+	case 2, 4, 5:
+		return 2
+	case 3:
+		return 4
+	case 7:
+		return 3
+	default:
+		if op == sprmTDefTable {
+			if len(operand) < 2 {
+				return -1
+			}
+			return 1 + le16(operand)
+		}
+		if len(operand) < 1 {
+			return -1
+		}
+		return 1 + int(operand[0])
+	}
+}
+
+// find 返回 fc 处字符所在段落的属性
+func (props paragraphProps) find(fc int) paragraphProp {
+	i := sort.Search(len(props), func(i int) bool { return props[i].fcEnd > fc })
+	if i < len(props) && props[i].fcStart <= fc {
+		return props[i]
+	}
+	return paragraphProp{}
+}
+
+// docParagraphs 按段落标记和单元格标记拆分正文，单元格内的多个段落以空格连接，连续的表格行合并为一个段落
+func docParagraphs(chars []docChar, props paragraphProps) []Paragraph {
+	var paragraphs []Paragraph
+	var rows, cells, cellParas []string
+	var current strings.Builder
+	flushTable := func() {
+		// 缺少段落属性时无法识别行结束，剩余的单元格作为一行
+		if len(cells) > 0 {
+			rows = append(rows, strings.Join(cells, "\t"))
+			cells = nil
+		}
+		if len(rows) > 0 {
+			paragraphs = append(paragraphs, Paragraph{Text: strings.Join(rows, "\n"), Table: true})
+			rows = nil
+		}
+	}
+	take := func() string {
+		s := strings.TrimSpace(current.String())
+		current.Reset()
+		return s
+	}
+
+	// fields 为嵌套的域，true 表示处于域代码部分(不输出)
+	var fields []bool
+	for _, ch := range chars {
+		c := ch.r
+		switch {
+		case c == chFieldBegin:
+			fields = append(fields, true)
+		case c == chFieldSep:
+			if len(fields) > 0 {
+				fields[len(fields)-1] = false
+			}
+		case c == chFieldEnd:
+			if len(fields) > 0 {
+				fields = fields[:len(fields)-1]
+			}
+		case len(fields) > 0 && fields[len(fields)-1]:
+		case c == chCellMark && props.find(ch.fc).ttp:
+			if row := strings.Join(cells, "\t"); strings.TrimSpace(row) != "" {
+				rows = append(rows, row)
+			}
+			cells, cellParas = nil, nil
+			current.Reset()
+		case c == chCellMark:
+			cell := strings.Join(append(cellParas, take()), " ")
+			cells = append(cells, strings.Join(strings.Fields(cell), " "))
+			cellParas = nil
+		case c == chParagraph && props.find(ch.fc).inTable:
+			cellParas = append(cellParas, take())
+		case c == chParagraph || c == chPageBreak:
+			flushTable()
+			prop := props.find(ch.fc)
+			if s := take(); s != "" {
+				p := Paragraph{Text: s}
+				if prop.istd >= 1 && prop.istd <= 9 {
+					p.Level = prop.istd
+				}
+				paragraphs = append(paragraphs, p)
+			}
+		case c == chLineBreak:
+			current.WriteByte('\n')
+		case c == chNBHyphen:
+			current.WriteByte('-')
+		case c == chTab || c >= 0x20 && c != 0xFFFF:
+			current.WriteRune(c)
+		}
+	}
+	flushTable()
+	if s := take(); s != "" {
+		paragraphs = append(paragraphs, Paragraph{Text: s})
+	}
+	return paragraphs
+}
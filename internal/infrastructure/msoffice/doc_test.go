@@ -0,0 +1,104 @@
+package msoffice
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func readDocStreams(t testing.TB) map[string][]byte {
+	t.Helper()
+	f, err := os.Open("testdata/novpapplan.doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	streams, err := readStreams(f, []string{"WordDocument"}, "0Table", "1Table")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return streams
+}
+
+// withStream 返回将 name 流替换为 data 后的流集合
+func withStream(streams map[string][]byte, name string, data []byte) map[string][]byte {
+	out := make(map[string][]byte, len(streams))
+	for k, v := range streams {
+		out[k] = v
+	}
+	out[name] = data
+	return out
+}
+
+func TestReadDoc(t *testing.T) {
+	f, err := os.Open("testdata/novpapplan.doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	paras, err := ReadDoc(f)
+	if err != nil {
+		t.Fatalf("ReadDoc: %v", err)
+	}
+	want := []Paragraph{
+		{Text: "AIM: This research will offer some wider insights into the character of British imperialism during this period."},
+		{Text: "Primary", Level: 1},
+		{Text: "Memoirs", Level: 2},
+	}
+	if len(paras) < len(want) {
+		t.Fatalf("got %d paragraphs", len(paras))
+	}
+	for i, w := range want {
+		if paras[i] != w {
+			t.Errorf("paragraph %d = %+v, want %+v", i, paras[i], w)
+		}
+	}
+}
+
+func TestParseDocTruncated(t *testing.T) {
+	streams := readDocStreams(t)
+	for _, name := range []string{"WordDocument", "1Table"} {
+		data := streams[name]
+		for n := 0; n < len(data); n++ {
+			// 文件头附近逐字节截断，之后按步长截断
+			if n > 2048 && n%97 != 0 {
+				continue
+			}
+			noPanic(t, fmt.Sprintf("%s truncated at %d", name, n), func() {
+				parseDoc(withStream(streams, name, data[:n]))
+			})
+		}
+	}
+}
+
+func TestParseDocCorrupted(t *testing.T) {
+	streams := readDocStreams(t)
+	rng := rand.New(rand.NewSource(1))
+	for _, name := range []string{"WordDocument", "1Table"} {
+		data := streams[name]
+		for i := 0; i < 300; i++ {
+			corrupted := append([]byte(nil), data...)
+			// 文件头和片段表集中在开头，多数改动落在前 4KB
+			for j := 0; j < 8; j++ {
+				pos := rng.Intn(min(len(corrupted), 4096))
+				if j%2 == 1 {
+					pos = rng.Intn(len(corrupted))
+				}
+				corrupted[pos] = byte(rng.Intn(256))
+			}
+			noPanic(t, fmt.Sprintf("%s corruption %d", name, i), func() {
+				parseDoc(withStream(streams, name, corrupted))
+			})
+		}
+	}
+}
+
+func FuzzParseDoc(f *testing.F) {
+	streams := readDocStreams(f)
+	f.Add(streams["WordDocument"][:4096], streams["1Table"])
+	f.Fuzz(func(t *testing.T, wd, table []byte) {
+		parseDoc(map[string][]byte{"WordDocument": wd, "0Table": table, "1Table": table})
+	})
+}
@@ -0,0 +1,499 @@
+package msoffice
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// BIFF8 记录类型
+const (
+	recFormula    = 0x0006
+	recEOF        = 0x000A
+	recFilePass   = 0x002F
+	recDateMode   = 0x0022
+	recContinue   = 0x003C
+	recBoundSheet = 0x0085
+	recMulRK      = 0x00BD
+	recXF         = 0x00E0
+	recMergeCells = 0x00E5
+	recSST        = 0x00FC
+	recLabelSST   = 0x00FD
+	recNumber     = 0x0203
+	recLabel      = 0x0204
+	recBoolErr    = 0x0205
+	recString     = 0x0207
+	recRK         = 0x027E
+	recFormat     = 0x041E
+	recBOF        = 0x0809
+)
+
+// Sheet 工作表内容，Rows[r][c] 为单元格的显示文本
+type Sheet struct {
+	Name   string
+	Hidden bool
+	Rows   [][]string
+	Merges []CellRange
+}
+
+// CellRange 合并单元格区域，行列从0开始，包含两端
+type CellRange struct {
+	FirstRow, LastRow int
+	FirstCol, LastCol int
+}
+
+// workbook 工作簿全局信息：共享字符串表和日期格式
+type workbook struct {
+	strings   []string
+	formats   map[int]string // 自定义数字格式
+	xfFormats []int          // XF 下标 -> 数字格式ID
+	date1904  bool
+}
+
+// boundSheet 工作表在数据流中的位置
+type boundSheet struct {
+	name   string
+	offset int
+	hidden bool
+}
+
+// ReadXLS 读取 Excel 97-2003 (BIFF8) 工作簿中的工作表，图表和宏表被忽略
+// 公式单元格取文件中保存的计算结果，日期格式的数字转换为日期文本
+func ReadXLS(r io.ReaderAt) ([]Sheet, error) {
+	streams, err := readStreams(r, nil, "Workbook", "Book")
+	if err != nil {
+		return nil, err
+	}
+	data, ok := streams["Workbook"]
+	if !ok {
+		if _, ok := streams["Book"]; ok {
+			return nil, fmt.Errorf("%w: BIFF5 workbook (Excel 95)", ErrUnsupportedFormat)
+		}
+		return nil, fmt.Errorf("%w: missing Workbook stream", ErrUnsupportedFormat)
+	}
+	return parseWorkbook(data)
+}
+
+// parseWorkbook 解析 Workbook 流，记录被截断或长度字段有误时尽量读取已有内容而不越界
+func parseWorkbook(data []byte) ([]Sheet, error) {
+	wb := &workbook{formats: make(map[int]string)}
+	bounds, err := wb.readGlobals(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var sheets []Sheet
+	for _, b := range bounds {
+		sheet, err := wb.readSheet(data, b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read sheet %s: %w", b.name, err)
+		}
+		sheets = append(sheets, sheet)
+	}
+	return sheets, nil
+}
+
+// biffReader 顺序读取记录
+type biffReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *biffReader) next() (typ int, body []byte, ok bool) {
+	if r.pos+4 > len(r.data) {
+		return 0, nil, false
+	}
+	typ = le16(r.data[r.pos:])
+	end := r.pos + 4 + le16(r.data[r.pos+2:])
+	if end > len(r.data) {
+		end = len(r.data)
+	}
+	body = r.data[r.pos+4 : end]
+	r.pos = end
+	return typ, body, true
+}
+
+// peek 返回下一条记录的类型
+func (r *biffReader) peek() int {
+	if r.pos+4 > len(r.data) {
+		return -1
+	}
+	return le16(r.data[r.pos:])
+}
+
+// readGlobals 读取工作簿全局子流，返回工作表列表
+func (wb *workbook) readGlobals(data []byte) ([]boundSheet, error) {
+	r := &biffReader{data: data}
+	var sheets []boundSheet
+	first := true
+	for {
+		typ, body, ok := r.next()
+		if !ok {
+			return nil, fmt.Errorf("%w: truncated workbook globals", ErrUnsupportedFormat)
+		}
+		if first {
+			if typ != recBOF || le16(body) != 0x0600 {
+				return nil, fmt.Errorf("%w: not a BIFF8 workbook", ErrUnsupportedFormat)
+			}
+			first = false
+			continue
+		}
+
+		switch typ {
+		case recEOF:
+			return sheets, nil
+		case recFilePass:
+			return nil, ErrEncrypted
+		case recDateMode:
+			wb.date1904 = le16(body) == 1
+		case recFormat:
+			if len(body) > 2 {
+				s := readUnicodeString(body[2:], 2)
+				wb.formats[le16(body)] = s
+			}
+		case recXF:
+			if len(body) >= 4 {
+				wb.xfFormats = append(wb.xfFormats, le16(body[2:]))
+			}
+		case recBoundSheet:
+			// 只读取普通工作表(dt=0)，跳过图表和宏表
+			if len(body) < 8 || body[5] != 0 {
+				continue
+			}
+			name := readUnicodeString(body[6:], 1)
+			sheets = append(sheets, boundSheet{
+				name:   name,
+				offset: int(le32(body)),
+				hidden: body[4]&0x03 != 0,
+			})
+		case recSST:
+			segments := [][]byte{body}
+			for r.peek() == recContinue {
+				_, cont, _ := r.next()
+				segments = append(segments, cont)
+			}
+			wb.strings = readSST(segments)
+		}
+	}
+}
+
+// readSheet 读取工作表子流中的单元格和合并区域，嵌套的图表子流被跳过
+func (wb *workbook) readSheet(data []byte, b boundSheet) (Sheet, error) {
+	sheet := Sheet{Name: b.name, Hidden: b.hidden}
+	if b.offset < 0 || b.offset >= len(data) {
+		return sheet, fmt.Errorf("%w: invalid sheet offset", ErrUnsupportedFormat)
+	}
+
+	set := func(row, col int, value string) {
+		if value == "" {
+			return
+		}
+		for len(sheet.Rows) <= row {
+			sheet.Rows = append(sheet.Rows, nil)
+		}
+		for len(sheet.Rows[row]) <= col {
+			sheet.Rows[row] = append(sheet.Rows[row], "")
+		}
+		sheet.Rows[row][col] = value
+	}
+
+	r := &biffReader{data: data, pos: b.offset}
+	depth := 0
+	pendingRow, pendingCol := -1, -1 // 等待 STRING 记录的字符串公式单元格
+	for {
+		typ, body, ok := r.next()
+		if !ok {
+			return sheet, nil
+		}
+		switch typ {
+		case recBOF:
+			depth++
+			continue
+		case recEOF:
+			if depth--; depth <= 0 {
+				return sheet, nil
+			}
+			continue
+		}
+		if depth != 1 {
+			continue
+		}
+
+		switch typ {
+		case recLabelSST:
+			if len(body) < 10 {
+				continue
+			}
+			if idx := int(le32(body[6:])); idx < len(wb.strings) {
+				set(le16(body), le16(body[2:]), wb.strings[idx])
+			}
+		case recLabel:
+			if len(body) > 6 {
+				s := readUnicodeString(body[6:], 2)
+				set(le16(body), le16(body[2:]), s)
+			}
+		case recNumber:
+			if len(body) >= 14 {
+				v := math.Float64frombits(binary.LittleEndian.Uint64(body[6:]))
+				set(le16(body), le16(body[2:]), wb.formatNumber(v, le16(body[4:])))
+			}
+		case recRK:
+			if len(body) >= 10 {
+				set(le16(body), le16(body[2:]), wb.formatNumber(decodeRK(le32(body[6:])), le16(body[4:])))
+			}
+		case recMulRK:
+			if len(body) < 6 {
+				continue
+			}
+			row, col := le16(body), le16(body[2:])
+			for p := 4; p+6 <= len(body)-2; p += 6 {
+				set(row, col, wb.formatNumber(decodeRK(le32(body[p+2:])), le16(body[p:])))
+				col++
+			}
+		case recBoolErr:
+			if len(body) >= 8 {
+				set(le16(body), le16(body[2:]), boolErrText(body[6], body[7] != 0))
+			}
+		case recFormula:
+			if len(body) < 14 {
+				continue
+			}
+			row, col, value := le16(body), le16(body[2:]), body[6:14]
+			if le16(value[6:]) != 0xFFFF {
+				set(row, col, wb.formatNumber(math.Float64frombits(binary.LittleEndian.Uint64(value)), le16(body[4:])))
+				continue
+			}
+			switch value[0] {
+			case 0: // 字符串结果保存在随后的 STRING 记录中
+				pendingRow, pendingCol = row, col
+			case 1:
+				set(row, col, boolErrText(value[2], false))
+			case 2:
+				set(row, col, boolErrText(value[2], true))
+			}
+		case recString:
+			if pendingRow >= 0 {
+				s := readUnicodeString(body, 2)
+				set(pendingRow, pendingCol, s)
+				pendingRow, pendingCol = -1, -1
+			}
+		case recMergeCells:
+			n := le16(body)
+			for i := 0; i < n && 2+i*8+8 <= len(body); i++ {
+				ref := body[2+i*8:]
+				sheet.Merges = append(sheet.Merges, CellRange{
+					FirstRow: le16(ref), LastRow: le16(ref[2:]),
+					FirstCol: le16(ref[4:]), LastCol: le16(ref[6:]),
+				})
+			}
+		}
+	}
+}
+
+// readUnicodeString 读取 XLUnicodeString，lenSize 为长度字段的字节数(1或2)
+func readUnicodeString(b []byte, lenSize int) string {
+	if len(b) < lenSize+1 {
+		return ""
+	}
+	cch := int(b[0])
+	if lenSize == 2 {
+		cch = le16(b)
+	}
+	high := b[lenSize]&0x01 != 0
+	p := lenSize + 1
+	units := make([]uint16, 0, cch)
+	for i := 0; i < cch; i++ {
+		if high {
+			if p+2 > len(b) {
+				break
+			}
+			units = append(units, uint16(le16(b[p:])))
+			p += 2
+		} else {
+			if p >= len(b) {
+				break
+			}
+			units = append(units, uint16(b[p]))
+			p++
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// sstReader 读取跨越 CONTINUE 记录的共享字符串表
+type sstReader struct {
+	segments [][]byte
+	seg, off int
+}
+
+func (r *sstReader) eof() bool {
+	for r.seg < len(r.segments) && r.off >= len(r.segments[r.seg]) {
+		r.seg++
+		r.off = 0
+	}
+	return r.seg >= len(r.segments)
+}
+
+func (r *sstReader) byte() byte {
+	if r.eof() {
+		return 0
+	}
+	b := r.segments[r.seg][r.off]
+	r.off++
+	return b
+}
+
+func (r *sstReader) u16() int {
+	return int(r.byte()) | int(r.byte())<<8
+}
+
+func (r *sstReader) skip(n int) {
+	for i := 0; i < n && !r.eof(); i++ {
+		r.off++
+	}
+}
+
+// chars 读取 cch 个字符，字符跨越记录边界时下一条记录以新的压缩标志开头
+func (r *sstReader) chars(cch int, high bool) string {
+	units := make([]uint16, 0, cch)
+	for len(units) < cch {
+		// 字符串被截断时返回已读取的部分
+		if r.seg >= len(r.segments) {
+			break
+		}
+		if r.off >= len(r.segments[r.seg]) {
+			if r.seg+1 >= len(r.segments) {
+				break
+			}
+			r.seg, r.off = r.seg+1, 0
+			high = r.byte()&0x01 != 0
+			continue
+		}
+		if high {
+			units = append(units, uint16(r.u16()))
+		} else {
+			units = append(units, uint16(r.byte()))
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// readSST 解析共享字符串表(XLUnicodeRichExtendedString 数组)，忽略富文本格式和东亚注音信息
+func readSST(segments [][]byte) []string {
+	r := &sstReader{segments: segments}
+	r.skip(4) // cstTotal
+	lo, hi := r.u16(), r.u16()
+	count := lo | hi<<16
+	var strs []string
+	for i := 0; i < count && !r.eof(); i++ {
+		cch := r.u16()
+		flags := r.byte()
+		runs, ext := 0, 0
+		if flags&0x08 != 0 {
+			runs = r.u16()
+		}
+		if flags&0x04 != 0 {
+			lo, hi := r.u16(), r.u16()
+			ext = lo | hi<<16
+		}
+		strs = append(strs, r.chars(cch, flags&0x01 != 0))
+		r.skip(runs*4 + ext)
+	}
+	return strs
+}
+
+// decodeRK 解码 RK 压缩数字
+func decodeRK(rk uint32) float64 {
+	var v float64
+	if rk&0x02 != 0 {
+		v = float64(int32(rk) >> 2)
+	} else {
+		v = math.Float64frombits(uint64(rk&0xFFFFFFFC) << 32)
+	}
+	if rk&0x01 != 0 {
+		v /= 100
+	}
+	return v
+}
+
+// boolErrText 布尔值和错误值的显示文本
+func boolErrText(v byte, isErr bool) string {
+	if !isErr {
+		if v != 0 {
+			return "TRUE"
+		}
+		return "FALSE"
+	}
+	switch v {
+	case 0x00:
+		return "#NULL!"
+	case 0x07:
+		return "#DIV/0!"
+	case 0x0F:
+		return "#VALUE!"
+	case 0x17:
+		return "#REF!"
+	case 0x1D:
+		return "#NAME?"
+	case 0x24:
+		return "#NUM!"
+	default:
+		return "#N/A"
+	}
+}
+
+// formatNumber 日期格式的数字转换为日期文本，其余按最短形式输出
+func (wb *workbook) formatNumber(v float64, xf int) string {
+	if xf < len(wb.xfFormats) && wb.isDateFormat(wb.xfFormats[xf]) {
+		base := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+		if wb.date1904 {
+			base = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+		}
+		t := base.Add(time.Duration(math.Round(v*86400)) * time.Second)
+		if v == math.Trunc(v) {
+			return t.Format("2006-01-02")
+		}
+		if v < 1 {
+			return t.Format("15:04:05")
+		}
+		return t.Format("2006-01-02 15:04:05")
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// isDateFormat 判断数字格式是否为日期或时间：内置日期格式，或去掉引号、方括号和转义后含有日期时间占位符的自定义格式
+func (wb *workbook) isDateFormat(id int) bool {
+	switch {
+	case id >= 14 && id <= 22, id >= 27 && id <= 36, id >= 45 && id <= 47, id >= 50 && id <= 58:
+		return true
+	}
+	format, ok := wb.formats[id]
+	if !ok {
+		return false
+	}
+	var b strings.Builder
+	inQuote, inBracket, escaped := false, false, false
+	for _, c := range format {
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '"':
+			inQuote = !inQuote
+		case inQuote:
+		case c == '[':
+			inBracket = true
+		case c == ']':
+			inBracket = false
+		case !inBracket:
+			b.WriteRune(c)
+		}
+	}
+	return strings.ContainsAny(strings.ToLower(b.String()), "dmyhs")
+}
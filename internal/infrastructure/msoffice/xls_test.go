@@ -0,0 +1,116 @@
+package msoffice
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// testdata 中的 test.xls 和 novpapplan.doc 取自 github.com/richardlehane/mscfb 的测试数据
+
+func readWorkbookStream(t testing.TB) []byte {
+	t.Helper()
+	f, err := os.Open("testdata/test.xls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	streams, err := readStreams(f, []string{"Workbook"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return streams["Workbook"]
+}
+
+// noPanic 调用 fn，发生 panic 时以 name 报告失败
+func noPanic(t testing.TB, name string, fn func()) {
+	t.Helper()
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("%s: panic: %v", name, r)
+		}
+	}()
+	fn()
+}
+
+func TestReadXLS(t *testing.T) {
+	f, err := os.Open("testdata/test.xls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	sheets, err := ReadXLS(f)
+	if err != nil {
+		t.Fatalf("ReadXLS: %v", err)
+	}
+	var names []string
+	for _, s := range sheets {
+		names = append(names, s.Name)
+	}
+	if want := []string{"Test sheet 1", "Test sheet 2", "Sheet3"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("sheets = %q, want %q", names, want)
+	}
+	if got := sheets[0].Rows[0]; !reflect.DeepEqual(got, []string{"Test1", "Lorem", "Ipsum"}) {
+		t.Errorf("first row = %q", got)
+	}
+	if got := sheets[0].Rows[1]; !reflect.DeepEqual(got, []string{"Avocado", "1", "2"}) {
+		t.Errorf("second row = %q", got)
+	}
+}
+
+func TestParseWorkbookTruncated(t *testing.T) {
+	data := readWorkbookStream(t)
+	for n := 0; n < len(data); n++ {
+		noPanic(t, fmt.Sprintf("truncated at %d", n), func() {
+			parseWorkbook(data[:n])
+		})
+	}
+}
+
+func TestParseWorkbookCorrupted(t *testing.T) {
+	data := readWorkbookStream(t)
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		corrupted := append([]byte(nil), data...)
+		for j := 0; j < 8; j++ {
+			corrupted[rng.Intn(len(corrupted))] = byte(rng.Intn(256))
+		}
+		noPanic(t, fmt.Sprintf("corruption %d", i), func() {
+			parseWorkbook(corrupted)
+		})
+	}
+}
+
+func TestReadSSTTruncated(t *testing.T) {
+	// cstTotal=2, cstUnique=2, 然后是两个压缩存储的字符串 "hello" 和 "world"
+	sst := []byte{2, 0, 0, 0, 2, 0, 0, 0,
+		5, 0, 0, 'h', 'e', 'l', 'l', 'o',
+		5, 0, 0, 'w', 'o', 'r', 'l', 'd'}
+	if got := readSST([][]byte{sst}); !reflect.DeepEqual(got, []string{"hello", "world"}) {
+		t.Fatalf("readSST = %q", got)
+	}
+	// 第二个字符串跨越 CONTINUE 记录，续接部分以压缩标志开头
+	split := [][]byte{sst[:21], append([]byte{0}, sst[21:]...)}
+	if got := readSST(split); !reflect.DeepEqual(got, []string{"hello", "world"}) {
+		t.Fatalf("readSST across CONTINUE = %q", got)
+	}
+
+	for n := 0; n <= len(sst); n++ {
+		noPanic(t, fmt.Sprintf("truncated at %d", n), func() {
+			readSST([][]byte{sst[:n]})
+		})
+		noPanic(t, fmt.Sprintf("truncated at %d with empty CONTINUE", n), func() {
+			readSST([][]byte{sst[:n], {}})
+		})
+	}
+}
+
+func FuzzParseWorkbook(f *testing.F) {
+	f.Add(readWorkbookStream(f))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		parseWorkbook(data)
+	})
+}
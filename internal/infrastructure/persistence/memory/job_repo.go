@@ -0,0 +1,154 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/job"
+)
+
+// JobRepository 内嵌的入库任务存储，可选地持久化到本地 JSON 文件
+// 重启后未完成的任务都重新排队，处理中的进度只保存在内存中，不写入文件
+type JobRepository struct {
+	// MaxFinished 保留的已结束任务数，超出时删除最早结束的任务，0 表示不限制
+	MaxFinished int
+
+	mu       sync.RWMutex
+	jobs     map[string]*job.Job
+	filePath string // 为空时不持久化
+}
+
+// NewJobRepository 创建纯内存的任务存储
+func NewJobRepository() *JobRepository {
+	return &JobRepository{jobs: make(map[string]*job.Job)}
+}
+
+// NewFileJobRepository 创建以本地文件持久化的任务存储，文件存在时加载已有任务
+func NewFileJobRepository(filePath string) (*JobRepository, error) {
+	r := NewJobRepository()
+	r.filePath = filePath
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return r, nil
+		}
+		return nil, fmt.Errorf("failed to read job file: %w", err)
+	}
+
+	var jobs []*job.Job
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &jobs); err != nil {
+			return nil, fmt.Errorf("failed to decode job file: %w", err)
+		}
+	}
+	for _, j := range jobs {
+		r.jobs[j.ID] = j
+	}
+	return r, nil
+}
+
+// Save 保存任务，处理中的状态和分块数只更新内存，排队和结束时写入文件
+func (r *JobRepository) Save(j *job.Job) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := *j
+	r.jobs[j.ID] = &c
+	if inProgress(j.State) {
+		return nil
+	}
+	if j.State.Terminal() {
+		r.pruneLocked()
+	}
+	return r.persist()
+}
+
+// inProgress 处理中的状态，重启后重新排队，无需持久化
+func inProgress(s job.State) bool {
+	return s != job.StateQueued && !s.Terminal()
+}
+
+// pruneLocked 已结束的任务超过 MaxFinished 时删除最早结束的任务(调用方需持有写锁)
+func (r *JobRepository) pruneLocked() {
+	if r.MaxFinished <= 0 {
+		return
+	}
+	var finished []*job.Job
+	for _, j := range r.jobs {
+		if j.State.Terminal() {
+			finished = append(finished, j)
+		}
+	}
+	if len(finished) <= r.MaxFinished {
+		return
+	}
+	sort.Slice(finished, func(i, k int) bool {
+		return finishedAt(finished[i]).Before(finishedAt(finished[k]))
+	})
+	for _, j := range finished[:len(finished)-r.MaxFinished] {
+		delete(r.jobs, j.ID)
+	}
+}
+
+// finishedAt 结束时间，旧任务没有记录时按创建时间
+func finishedAt(j *job.Job) time.Time {
+	if j.FinishedAt != nil {
+		return *j.FinishedAt
+	}
+	return j.CreatedAt
+}
+
+func (r *JobRepository) FindByID(id string) (*job.Job, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	j, ok := r.jobs[id]
+	if !ok {
+		return nil, job.ErrNotFound
+	}
+	c := *j
+	return &c, nil
+}
+
+func (r *JobRepository) List() ([]*job.Job, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.sortedLocked(true), nil
+}
+
+// sortedLocked 按创建时间排序，clone 为 true 时返回副本
+func (r *JobRepository) sortedLocked(clone bool) []*job.Job {
+	jobs := make([]*job.Job, 0, len(r.jobs))
+	for _, j := range r.jobs {
+		if clone {
+			c := *j
+			j = &c
+		}
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(i, k int) bool {
+		if jobs[i].CreatedAt.Equal(jobs[k].CreatedAt) {
+			return jobs[i].ID < jobs[k].ID
+		}
+		return jobs[i].CreatedAt.Before(jobs[k].CreatedAt)
+	})
+	return jobs
+}
+
+// persist 将当前任务写入文件(调用方需持有写锁)
+func (r *JobRepository) persist() error {
+	if r.filePath == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(r.sortedLocked(false), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode jobs: %w", err)
+	}
+	return writeFileAtomic(r.filePath, data)
+}
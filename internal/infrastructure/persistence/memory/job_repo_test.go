@@ -0,0 +1,109 @@
+package memory
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/job"
+)
+
+// persistedStates 读取任务文件中各任务的状态
+func persistedStates(t *testing.T, path string) map[string]job.State {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var jobs []*job.Job
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		t.Fatal(err)
+	}
+	states := make(map[string]job.State, len(jobs))
+	for _, j := range jobs {
+		states[j.ID] = j.State
+	}
+	return states
+}
+
+func TestJobRepositoryKeepsProgressInMemory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	repo, err := NewFileJobRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := &job.Job{ID: "a", State: job.StateQueued, CreatedAt: time.Now()}
+	if err := repo.Save(j); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, state := range []job.State{job.StateParsing, job.StateEmbedding, job.StateStoring} {
+		j.State, j.Chunks = state, j.Chunks+10
+		if err := repo.Save(j); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := repo.FindByID("a"); got.State != state || got.Chunks != j.Chunks {
+			t.Errorf("FindByID = %s with %d chunks, want %s with %d", got.State, got.Chunks, state, j.Chunks)
+		}
+		// 文件中仍为排队状态，重启后重新执行
+		if got := persistedStates(t, path)["a"]; got != job.StateQueued {
+			t.Errorf("persisted state after %s = %s, want queued", state, got)
+		}
+	}
+
+	j.State = job.StateDone
+	if err := repo.Save(j); err != nil {
+		t.Fatal(err)
+	}
+	if got := persistedStates(t, path)["a"]; got != job.StateDone {
+		t.Errorf("persisted state = %s, want done", got)
+	}
+}
+
+func TestJobRepositoryPrunesFinishedJobs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	repo, err := NewFileJobRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.MaxFinished = 2
+
+	base := time.Now()
+	at := func(minutes int) *time.Time {
+		ts := base.Add(time.Duration(minutes) * time.Minute)
+		return &ts
+	}
+	// 按结束时间而非创建时间淘汰，排队中的任务不受限制
+	jobs := []*job.Job{
+		{ID: "queued", State: job.StateQueued, CreatedAt: base},
+		{ID: "first", State: job.StateDone, CreatedAt: base, FinishedAt: at(3)},
+		{ID: "second", State: job.StateFailed, CreatedAt: base.Add(time.Minute), FinishedAt: at(1)},
+		{ID: "third", State: job.StateCancelled, CreatedAt: base.Add(2 * time.Minute), FinishedAt: at(2)},
+	}
+	for _, j := range jobs {
+		if err := repo.Save(j); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := repo.FindByID("second"); !errors.Is(err, job.ErrNotFound) {
+		t.Errorf("FindByID(second) err = %v, want the earliest finished job pruned", err)
+	}
+	want := map[string]job.State{"queued": job.StateQueued, "first": job.StateDone, "third": job.StateCancelled}
+	list, _ := repo.List()
+	if len(list) != len(want) {
+		t.Errorf("List returned %d jobs, want %d", len(list), len(want))
+	}
+	persisted := persistedStates(t, path)
+	for id, state := range want {
+		if persisted[id] != state {
+			t.Errorf("persisted %s = %q, want %s", id, persisted[id], state)
+		}
+	}
+	if len(persisted) != len(want) {
+		t.Errorf("persisted jobs = %v, want %v", persisted, want)
+	}
+}
@@ -0,0 +1,213 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/document"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/knowledge"
+)
+
+// KnowledgeRepository 内嵌的知识库元数据存储，可选地持久化到本地 JSON 文件
+type KnowledgeRepository struct {
+	mu       sync.RWMutex
+	bases    map[string]*knowledge.KnowledgeBase
+	filePath string // 为空时不持久化
+}
+
+// NewKnowledgeRepository 创建纯内存的知识库存储
+func NewKnowledgeRepository() *KnowledgeRepository {
+	return &KnowledgeRepository{bases: make(map[string]*knowledge.KnowledgeBase)}
+}
+
+// NewFileKnowledgeRepository 创建以本地文件持久化的知识库存储，文件存在时加载已有数据
+func NewFileKnowledgeRepository(filePath string) (*KnowledgeRepository, error) {
+	r := NewKnowledgeRepository()
+	r.filePath = filePath
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return r, nil
+		}
+		return nil, fmt.Errorf("failed to read knowledge base file: %w", err)
+	}
+
+	var bases []*knowledge.KnowledgeBase
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &bases); err != nil {
+			return nil, fmt.Errorf("failed to decode knowledge base file: %w", err)
+		}
+	}
+	for _, kb := range bases {
+		r.bases[kb.ID] = kb
+	}
+	return r, nil
+}
+
+// Create 新建知识库，未指定ID时自动生成，名称不可重复
+func (r *KnowledgeRepository) Create(kb *knowledge.KnowledgeBase) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if kb.ID == "" {
+		id, err := document.NewID()
+		if err != nil {
+			return fmt.Errorf("failed to generate knowledge base id: %w", err)
+		}
+		kb.ID = id
+	}
+	if _, exists := r.bases[kb.ID]; exists {
+		return fmt.Errorf("knowledge base %s already exists", kb.ID)
+	}
+	if r.nameTakenLocked(kb.Name, kb.ID) {
+		return knowledge.ErrDuplicateName
+	}
+
+	now := time.Now()
+	if kb.CreatedAt.IsZero() {
+		kb.CreatedAt = now
+	}
+	kb.UpdatedAt = now
+	r.bases[kb.ID] = cloneKnowledgeBase(kb)
+	return r.persist()
+}
+
+// Update 更新知识库名称、描述和分块器
+func (r *KnowledgeRepository) Update(kb *knowledge.KnowledgeBase) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.bases[kb.ID]
+	if !ok {
+		return knowledge.ErrNotFound
+	}
+	if r.nameTakenLocked(kb.Name, kb.ID) {
+		return knowledge.ErrDuplicateName
+	}
+
+	existing.Name = kb.Name
+	existing.Description = kb.Description
+	existing.Splitter = kb.Splitter
+	existing.UpdatedAt = time.Now()
+	kb.UpdatedAt = existing.UpdatedAt
+	return r.persist()
+}
+
+func (r *KnowledgeRepository) Delete(kbID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.bases[kbID]; !ok {
+		return knowledge.ErrNotFound
+	}
+	delete(r.bases, kbID)
+	return r.persist()
+}
+
+// List 按创建时间返回全部知识库
+func (r *KnowledgeRepository) List() ([]*knowledge.KnowledgeBase, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	bases := make([]*knowledge.KnowledgeBase, 0, len(r.bases))
+	for _, kb := range r.bases {
+		bases = append(bases, cloneKnowledgeBase(kb))
+	}
+	sort.Slice(bases, func(i, j int) bool {
+		if bases[i].CreatedAt.Equal(bases[j].CreatedAt) {
+			return bases[i].ID < bases[j].ID
+		}
+		return bases[i].CreatedAt.Before(bases[j].CreatedAt)
+	})
+	return bases, nil
+}
+
+// FindByID 查找知识库，不存在时返回 knowledge.ErrNotFound
+func (r *KnowledgeRepository) FindByID(kbID string) (*knowledge.KnowledgeBase, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	kb, ok := r.bases[kbID]
+	if !ok {
+		return nil, knowledge.ErrNotFound
+	}
+	return cloneKnowledgeBase(kb), nil
+}
+
+// AddDocument 记录文档归属的知识库
+func (r *KnowledgeRepository) AddDocument(kbID string, docID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	kb, ok := r.bases[kbID]
+	if !ok {
+		return knowledge.ErrNotFound
+	}
+	for _, id := range kb.DocumentIDs {
+		if id == docID {
+			return nil
+		}
+	}
+	kb.DocumentIDs = append(kb.DocumentIDs, docID)
+	kb.UpdatedAt = time.Now()
+	return r.persist()
+}
+
+func (r *KnowledgeRepository) RemoveDocument(kbID string, docID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	kb, ok := r.bases[kbID]
+	if !ok {
+		return knowledge.ErrNotFound
+	}
+	for i, id := range kb.DocumentIDs {
+		if id == docID {
+			kb.DocumentIDs = append(kb.DocumentIDs[:i], kb.DocumentIDs[i+1:]...)
+			kb.UpdatedAt = time.Now()
+			return r.persist()
+		}
+	}
+	return nil
+}
+
+func (r *KnowledgeRepository) nameTakenLocked(name, exceptID string) bool {
+	for id, kb := range r.bases {
+		if id != exceptID && kb.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// persist 将当前数据写入文件(调用方需持有写锁)
+func (r *KnowledgeRepository) persist() error {
+	if r.filePath == "" {
+		return nil
+	}
+
+	bases := make([]*knowledge.KnowledgeBase, 0, len(r.bases))
+	for _, kb := range r.bases {
+		bases = append(bases, kb)
+	}
+	sort.Slice(bases, func(i, j int) bool { return bases[i].ID < bases[j].ID })
+
+	data, err := json.MarshalIndent(bases, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode knowledge bases: %w", err)
+	}
+	return writeFileAtomic(r.filePath, data)
+}
+
+func cloneKnowledgeBase(kb *knowledge.KnowledgeBase) *knowledge.KnowledgeBase {
+	c := *kb
+	if kb.DocumentIDs != nil {
+		c.DocumentIDs = append([]string(nil), kb.DocumentIDs...)
+	}
+	return &c
+}
@@ -0,0 +1,374 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/document"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/knowledge"
+)
+
+// Metric 向量距离度量方式
+type Metric string
+
+const (
+	MetricL2     Metric = "L2"     // 欧氏距离，Score 越小越相近
+	MetricCosine Metric = "COSINE" // 余弦相似度，Score 越大越相近
+)
+
+// MemoryDocumentRepository 是内嵌的 DocumentRepository 实现，
+// 在内存中暴力检索向量，可选地将快照持久化到本地文件。
+// 用于开发环境及无需 Milvus 的离线运行。
+type MemoryDocumentRepository struct {
+	mu       sync.RWMutex
+	docs     map[string]*document.Document
+	order    []string // 按写入顺序保存ID，保证检索结果稳定
+	metric   Metric
+	filePath string // 为空时不持久化
+}
+
+// NewMemoryDocumentRepository 创建纯内存存储库
+func NewMemoryDocumentRepository(metric Metric) *MemoryDocumentRepository {
+	if metric == "" {
+		metric = MetricL2
+	}
+	return &MemoryDocumentRepository{
+		docs:   make(map[string]*document.Document),
+		metric: metric,
+	}
+}
+
+// NewFileDocumentRepository 创建以本地文件为快照的存储库，文件存在时加载已有数据
+func NewFileDocumentRepository(filePath string, metric Metric) (*MemoryDocumentRepository, error) {
+	r := NewMemoryDocumentRepository(metric)
+	r.filePath = filePath
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return r, nil
+		}
+		return nil, fmt.Errorf("failed to read snapshot file: %w", err)
+	}
+
+	var docs []*document.Document
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &docs); err != nil {
+			return nil, fmt.Errorf("failed to decode snapshot file: %w", err)
+		}
+	}
+	for _, doc := range docs {
+		if _, exists := r.docs[doc.ID]; !exists {
+			r.order = append(r.order, doc.ID)
+		}
+		r.docs[doc.ID] = doc
+	}
+
+	return r, nil
+}
+
+func (r *MemoryDocumentRepository) Store(ctx context.Context, doc *document.Document) error {
+	return r.StoreBatch(ctx, []*document.Document{doc})
+}
+
+func (r *MemoryDocumentRepository) StoreBatch(ctx context.Context, docs []*document.Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	if err := validateBatch(docs); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.putLocked(docs); err != nil {
+		return err
+	}
+	return r.persist()
+}
+
+// putLocked 写入文档(调用方需持有写锁)
+func (r *MemoryDocumentRepository) putLocked(docs []*document.Document) error {
+	for _, doc := range docs {
+		if doc.ID == "" {
+			id, err := document.NewID()
+			if err != nil {
+				return fmt.Errorf("failed to generate document id: %w", err)
+			}
+			doc.ID = id
+		}
+		if doc.CreatedAt.IsZero() {
+			doc.CreatedAt = time.Now()
+		}
+
+		if _, exists := r.docs[doc.ID]; !exists {
+			r.order = append(r.order, doc.ID)
+		}
+		r.docs[doc.ID] = cloneDocument(doc)
+	}
+	return nil
+}
+
+// FindByID 按ID查找文档，不存在时返回 nil, nil
+func (r *MemoryDocumentRepository) FindByID(ctx context.Context, id string) (*document.Document, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	doc, ok := r.docs[id]
+	if !ok {
+		return nil, nil
+	}
+	return cloneDocument(doc), nil
+}
+
+// Search 暴力计算知识库内所有向量与 embedding 的距离，返回最相近的 topK 个文档
+func (r *MemoryDocumentRepository) Search(ctx context.Context, kbID string, embedding []float32, topK int) ([]*document.Document, error) {
+	if len(embedding) == 0 {
+		return nil, fmt.Errorf("search embedding is empty")
+	}
+	if topK <= 0 {
+		topK = 5
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	type scored struct {
+		doc   *document.Document
+		score float64
+	}
+	candidates := make([]scored, 0, len(r.docs))
+	for _, id := range r.order {
+		doc := r.docs[id]
+		if !inKnowledgeBase(doc, kbID) {
+			continue
+		}
+		if len(doc.Vector) != len(embedding) {
+			return nil, fmt.Errorf("vector dimension mismatch: stored %d, query %d", len(doc.Vector), len(embedding))
+		}
+		candidates = append(candidates, scored{doc: doc, score: r.score(embedding, doc.Vector)})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if r.metric == MetricCosine {
+			return candidates[i].score > candidates[j].score
+		}
+		return candidates[i].score < candidates[j].score
+	})
+
+	if len(candidates) > topK {
+		candidates = candidates[:topK]
+	}
+	// 与 Milvus 一致，检索结果不包含向量
+	hits := make([]*document.Document, len(candidates))
+	for i, c := range candidates {
+		hit := cloneDocument(c.doc)
+		hit.Vector = nil
+		hit.Score = c.score
+		hits[i] = hit
+	}
+	return hits, nil
+}
+
+// DeleteByKnowledgeBase 删除知识库内的全部文档
+func (r *MemoryDocumentRepository) DeleteByKnowledgeBase(ctx context.Context, kbID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.removeLocked(func(doc *document.Document) bool { return inKnowledgeBase(doc, kbID) })
+	return r.persist()
+}
+
+func (r *MemoryDocumentRepository) FindDocumentByHash(ctx context.Context, kbID string, fileHash string) (*document.DocumentInfo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, id := range r.order {
+		doc := r.docs[id]
+		if doc.Metadata.DocumentID != "" && doc.Metadata.FileHash == fileHash && inKnowledgeBase(doc, kbID) {
+			return document.InfoFromChunk(doc), nil
+		}
+	}
+	return nil, nil
+}
+
+// ListDocuments 按首次写入顺序列出知识库内的上传文档
+func (r *MemoryDocumentRepository) ListDocuments(ctx context.Context, kbID string) ([]*document.DocumentInfo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var infos []*document.DocumentInfo
+	seen := make(map[string]bool)
+	for _, id := range r.order {
+		doc := r.docs[id]
+		docID := doc.Metadata.DocumentID
+		if docID == "" || seen[docID] || !inKnowledgeBase(doc, kbID) {
+			continue
+		}
+		seen[docID] = true
+		infos = append(infos, document.InfoFromChunk(doc))
+	}
+	return infos, nil
+}
+
+func (r *MemoryDocumentRepository) FindChunks(ctx context.Context, documentID string) ([]*document.Document, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	chunks := make([]*document.Document, 0)
+	for _, id := range r.order {
+		if doc := r.docs[id]; doc.Metadata.DocumentID == documentID {
+			chunks = append(chunks, cloneDocument(doc))
+		}
+	}
+	sort.SliceStable(chunks, func(i, j int) bool {
+		return chunks[i].Metadata.ChunkIndex < chunks[j].Metadata.ChunkIndex
+	})
+	return chunks, nil
+}
+
+// ReplaceDocument 在同一把锁内删除旧分块并写入新分块
+func (r *MemoryDocumentRepository) ReplaceDocument(ctx context.Context, documentID string, chunks []*document.Document) error {
+	if len(chunks) == 0 {
+		return fmt.Errorf("replacement for document %s has no chunks", documentID)
+	}
+	if err := validateBatch(chunks); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.removeLocked(func(doc *document.Document) bool { return doc.Metadata.DocumentID == documentID })
+	if err := r.putLocked(chunks); err != nil {
+		return err
+	}
+	return r.persist()
+}
+
+func (r *MemoryDocumentRepository) DeleteDocument(ctx context.Context, documentID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	removed := r.removeLocked(func(doc *document.Document) bool { return doc.Metadata.DocumentID == documentID })
+	if removed == 0 {
+		return document.ErrNotFound
+	}
+	return r.persist()
+}
+
+// removeLocked 删除满足条件的文档并返回删除数量(调用方需持有写锁)
+func (r *MemoryDocumentRepository) removeLocked(match func(*document.Document) bool) int {
+	removed := 0
+	order := r.order[:0]
+	for _, id := range r.order {
+		if match(r.docs[id]) {
+			delete(r.docs, id)
+			removed++
+			continue
+		}
+		order = append(order, id)
+	}
+	r.order = order
+	return removed
+}
+
+func validateBatch(docs []*document.Document) error {
+	for i, doc := range docs {
+		if doc == nil {
+			return fmt.Errorf("document at index %d is nil", i)
+		}
+	}
+	dim := len(docs[0].Vector)
+	for i, doc := range docs {
+		if len(doc.Vector) == 0 {
+			return fmt.Errorf("document at index %d has no vector", i)
+		}
+		if len(doc.Vector) != dim {
+			return fmt.Errorf("document at index %d has vector dimension %d, expected %d", i, len(doc.Vector), dim)
+		}
+	}
+	return nil
+}
+
+// inKnowledgeBase 未标记知识库的文档归属默认知识库
+func inKnowledgeBase(doc *document.Document, kbID string) bool {
+	if kbID == "" {
+		kbID = knowledge.DefaultID
+	}
+	docKB := doc.Metadata.KnowledgeBaseID
+	if docKB == "" {
+		docKB = knowledge.DefaultID
+	}
+	return docKB == kbID
+}
+
+// Len 返回已存储的文档数量
+func (r *MemoryDocumentRepository) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.docs)
+}
+
+func (r *MemoryDocumentRepository) score(a, b []float32) float64 {
+	switch r.metric {
+	case MetricCosine:
+		var dot, normA, normB float64
+		for i := range a {
+			dot += float64(a[i]) * float64(b[i])
+			normA += float64(a[i]) * float64(a[i])
+			normB += float64(b[i]) * float64(b[i])
+		}
+		if normA == 0 || normB == 0 {
+			return 0
+		}
+		return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+	default:
+		// 与 Milvus 保持一致，L2 返回平方距离
+		var sum float64
+		for i := range a {
+			d := float64(a[i]) - float64(b[i])
+			sum += d * d
+		}
+		return sum
+	}
+}
+
+// persist 将当前数据写入快照文件(调用方需持有写锁)
+func (r *MemoryDocumentRepository) persist() error {
+	if r.filePath == "" {
+		return nil
+	}
+
+	docs := make([]*document.Document, 0, len(r.order))
+	for _, id := range r.order {
+		docs = append(docs, r.docs[id])
+	}
+	data, err := json.Marshal(docs)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	return writeFileAtomic(r.filePath, data)
+}
+
+func cloneDocument(doc *document.Document) *document.Document {
+	c := *doc
+	if doc.Vector != nil {
+		c.Vector = append([]float32(nil), doc.Vector...)
+	}
+	if doc.Metadata.Custom != nil {
+		c.Metadata.Custom = make(map[string]interface{}, len(doc.Metadata.Custom))
+		for k, v := range doc.Metadata.Custom {
+			c.Metadata.Custom[k] = v
+		}
+	}
+	return &c
+}
@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/document"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/document/documenttest"
+)
+
+func TestMemoryRepositoryContract(t *testing.T) {
+	documenttest.TestRepository(t, func(t *testing.T) document.DocumentRepository {
+		return NewMemoryDocumentRepository(MetricCosine)
+	})
+}
+
+func TestFileRepositoryContract(t *testing.T) {
+	documenttest.TestRepository(t, func(t *testing.T) document.DocumentRepository {
+		repo, err := NewFileDocumentRepository(filepath.Join(t.TempDir(), "documents.json"), MetricCosine)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return repo
+	})
+}
+
+func TestFileRepositoryReloadsSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "documents.json")
+	repo, err := NewFileDocumentRepository(path, MetricL2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := &document.Document{ID: "a", Content: "alpha", Vector: []float32{1, 2, 3}, Metadata: document.Metadata{DocumentID: "doc-a"}}
+	if err := repo.Store(context.Background(), doc); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+
+	reloaded, err := NewFileDocumentRepository(path, MetricL2)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	got, err := reloaded.FindByID(context.Background(), "a")
+	if err != nil || got == nil {
+		t.Fatalf("FindByID after reload = %v, %v", got, err)
+	}
+	if got.Content != "alpha" || !reflect.DeepEqual(got.Vector, doc.Vector) || got.Metadata.DocumentID != "doc-a" {
+		t.Errorf("reloaded document = %+v", got)
+	}
+}
+
+func TestSearchL2OrdersByDistance(t *testing.T) {
+	repo := NewMemoryDocumentRepository(MetricL2)
+	docs := []*document.Document{
+		{ID: "far", Vector: []float32{10, 0}},
+		{ID: "near", Vector: []float32{1, 1}},
+	}
+	if err := repo.StoreBatch(context.Background(), docs); err != nil {
+		t.Fatalf("StoreBatch: %v", err)
+	}
+	hits, err := repo.Search(context.Background(), "", []float32{1, 0}, 5)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(hits) != 2 || hits[0].ID != "near" || hits[0].Score != 1 || hits[1].Score != 81 {
+		t.Errorf("hits = %+v, want near (squared distance 1) before far (81)", hits)
+	}
+}
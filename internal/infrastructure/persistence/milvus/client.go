@@ -2,24 +2,63 @@ package persistence
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/milvus-io/milvus-sdk-go/v2/client"
 	"github.com/milvus-io/milvus-sdk-go/v2/entity"
-	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/document"
 	infrastructure "github.com/redhander/AIKnowledgeBaseMiddleware/internal/infrastructure/config"
 )
 
+// 集合属性中记录的嵌入模型信息，用于检测模型变更
+const (
+	propEmbeddingModel = "kb.embedding.model"
+	propEmbeddingDim   = "kb.embedding.dim"
+)
+
+// collectionProperty 自定义集合属性，用于 AlterCollection
+type collectionProperty struct {
+	key, value string
+}
+
+func (p collectionProperty) KeyValue() (string, string) {
+	return p.key, p.value
+}
+
+func (p collectionProperty) Valid() error {
+	return nil
+}
+
+// ErrSchemaMismatch 已存在集合的结构与当前嵌入模型不一致
+var ErrSchemaMismatch = errors.New("milvus collection schema mismatch")
+
+// CollectionSpec 描述集合需要匹配的嵌入模型
+type CollectionSpec struct {
+	Dimension int               // 向量维度
+	ModelName string            // 嵌入模型名称
+	Metric    entity.MetricType // 距离度量
+}
+
 type MilvusClient struct {
 	Client         client.Client
 	CollectionName string
+	Metric         entity.MetricType
+	Dimension      int
 }
 
 // Option defines a function type for configuring the Milvus client
 type Option func(*client.Config)
 
-func NewMilvusClient(ctx context.Context, cfg infrastructure.MilvusConfig) (*MilvusClient, error) {
+func NewMilvusClient(ctx context.Context, cfg infrastructure.MilvusConfig, spec CollectionSpec) (*MilvusClient, error) {
+	if spec.Dimension <= 0 {
+		return nil, fmt.Errorf("invalid embedding dimension: %d", spec.Dimension)
+	}
+	if spec.Metric == "" {
+		spec.Metric = entity.L2
+	}
+
 	// 创建Milvus客户端
 	milvusClient, err := client.NewClient(ctx, client.Config{
 		Address:  cfg.Address,
@@ -36,154 +75,171 @@ func NewMilvusClient(ctx context.Context, cfg infrastructure.MilvusConfig) (*Mil
 		return nil, fmt.Errorf("failed to check collection existence: %v", err)
 	}
 
-	// 如果集合不存在，则创建
+	// 集合不存在则按当前模型创建，已存在则校验结构是否一致
 	if !exists {
-		schema := &entity.Schema{
-			CollectionName: cfg.CollectionName,
-			Description:    "Enterprise knowledge documents",
-			AutoID:         false,
-			Fields: []*entity.Field{
-				{
-					Name:       "id",
-					DataType:   entity.FieldTypeVarChar,
-					PrimaryKey: true,
-					AutoID:     false,
-					TypeParams: map[string]string{
-						"max_length": "64",
-					},
-				},
-				{
-					Name:     "content",
-					DataType: entity.FieldTypeVarChar,
-					TypeParams: map[string]string{
-						"max_length": "65535",
-					},
-				},
-				{
-					Name:     "metadata",
-					DataType: entity.FieldTypeJSON,
-				},
-				{
-					Name:     "vector",
-					DataType: entity.FieldTypeFloatVector,
-					TypeParams: map[string]string{
-						"dim": "768", // 根据嵌入模型调整维度
-					},
-				},
-			},
-		}
-
-		err := milvusClient.CreateCollection(ctx, schema, entity.DefaultShardNumber)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create collection: %v", err)
-		}
-
-		// 创建向量索引
-		index, err := entity.NewIndexIvfFlat(entity.L2, 128)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create index: %v", err)
-		}
+		err = createCollection(ctx, milvusClient, cfg.CollectionName, spec)
+	} else {
+		err = verifyCollection(ctx, milvusClient, cfg.CollectionName, spec)
+	}
+	if err != nil {
+		milvusClient.Close()
+		return nil, err
+	}
 
-		err = milvusClient.CreateIndex(context.Background(), cfg.CollectionName, "vector", index, false)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create index: %v", err)
-		}
+	// 加载集合到内存，Search/Query 之前必须完成
+	if err := milvusClient.LoadCollection(ctx, cfg.CollectionName, false); err != nil {
+		milvusClient.Close()
+		return nil, fmt.Errorf("failed to load collection: %v", err)
 	}
 
 	return &MilvusClient{
 		Client:         milvusClient,
 		CollectionName: cfg.CollectionName,
+		Metric:         spec.Metric,
+		Dimension:      spec.Dimension,
 	}, nil
 }
 
-func (mc *MilvusClient) InsertDocuments(docs []document.Document) error {
-	// 准备数据
-	var ids []string
-	var contents []string
-	var metadatas []string
-	var vectors [][]float32
-
-	for _, doc := range docs {
-		ids = append(ids, doc.ID)
-		contents = append(contents, doc.Content)
-		metadataJSON, err := json.Marshal(doc.Metadata)
-		if err != nil {
-			return fmt.Errorf("failed to marshal metadata: %v", err)
-		}
-		metadatas = append(metadatas, string(metadataJSON))
-		vectors = append(vectors, doc.Vector)
+func createCollection(ctx context.Context, c client.Client, name string, spec CollectionSpec) error {
+	schema := &entity.Schema{
+		CollectionName: name,
+		Description:    "Enterprise knowledge documents",
+		AutoID:         false,
+		Fields: []*entity.Field{
+			{
+				Name:       fieldID,
+				DataType:   entity.FieldTypeVarChar,
+				PrimaryKey: true,
+				AutoID:     false,
+				TypeParams: map[string]string{
+					entity.TypeParamMaxLength: "64",
+				},
+			},
+			{
+				Name:     fieldContent,
+				DataType: entity.FieldTypeVarChar,
+				TypeParams: map[string]string{
+					entity.TypeParamMaxLength: "65535",
+				},
+			},
+			{
+				Name:     fieldMetadata,
+				DataType: entity.FieldTypeJSON,
+			},
+			{
+				Name:     fieldVector,
+				DataType: entity.FieldTypeFloatVector,
+				TypeParams: map[string]string{
+					entity.TypeParamDim: strconv.Itoa(spec.Dimension),
+				},
+			},
+		},
 	}
 
-	// 插入数据
-	idCol := entity.NewColumnVarChar("id", ids)
-	contentCol := entity.NewColumnVarChar("content", contents)
-	var metadataBytes [][]byte
-	for _, metadata := range metadatas {
-		metadataBytes = append(metadataBytes, []byte(metadata))
+	err := c.CreateCollection(ctx, schema, entity.DefaultShardNumber,
+		client.WithCollectionProperty(propEmbeddingModel, spec.ModelName),
+		client.WithCollectionProperty(propEmbeddingDim, strconv.Itoa(spec.Dimension)),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create collection: %v", err)
 	}
-	metadataCol := entity.NewColumnJSONBytes("metadata", metadataBytes)
-	vectorCol := entity.NewColumnFloatVector("vector", 768, vectors)
 
-	_, err := mc.Client.Insert(context.Background(), mc.CollectionName, "", idCol, contentCol, metadataCol, vectorCol)
+	return createVectorIndex(ctx, c, name, spec.Metric)
+}
+
+// 创建向量索引
+func createVectorIndex(ctx context.Context, c client.Client, name string, metric entity.MetricType) error {
+	index, err := entity.NewIndexIvfFlat(metric, 128)
 	if err != nil {
-		return fmt.Errorf("failed to insert documents: %v", err)
+		return fmt.Errorf("failed to create index: %v", err)
 	}
 
+	if err := c.CreateIndex(ctx, name, fieldVector, index, false); err != nil {
+		return fmt.Errorf("failed to create index: %v", err)
+	}
 	return nil
 }
 
-func (mc *MilvusClient) Search(queryVector []float32, topK int) ([]document.Document, error) {
-	// 搜索向量
-	sp, _ := entity.NewIndexIvfFlatSearchParam(16)
-	results, err := mc.Client.Search(
-		context.Background(),
-		mc.CollectionName,
-		[]string{},
-		"",
-		[]string{"id", "content", "metadata"},
-		[]entity.Vector{entity.FloatVector(queryVector)},
-		"vector",
-		entity.L2,
-		topK,
-		sp,
-	)
+// verifyCollection 校验已存在集合的字段、维度、度量和嵌入模型是否与 spec 一致
+func verifyCollection(ctx context.Context, c client.Client, name string, spec CollectionSpec) error {
+	coll, err := c.DescribeCollection(ctx, name)
 	if err != nil {
-		return nil, fmt.Errorf("failed to search: %v", err)
-	}
-
-	// 处理结果
-	var docs []document.Document
-	for _, result := range results {
-		for i := 0; i < result.ResultCount; i++ {
-			id, _ := result.IDs.GetAsString(i)
-			content, err := result.Fields.GetColumn("content").GetAsString(i)
-			if err != nil {
-				return nil, fmt.Errorf("failed to get content: %v", err)
-
-			}
-			metadataStr, err := result.Fields.GetColumn("metadata").GetAsString(i)
-			if err != nil {
-				return nil, fmt.Errorf("failed to get metadata as string: %v", err)
-			}
-			metadataJSON := []byte(metadataStr)
-
-			var metadata map[string]interface{}
-			if err := json.Unmarshal(metadataJSON, &metadata); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal metadata: %v", err)
-			}
-			//todo, 这里屏蔽了代码，需要搞清楚情况
-			//score := result.Scores[i]
-
-			docs = append(docs, document.Document{
-				ID:      id,
-				Content: content,
-				//Metadata: metadata,
-				//Score:    float64(score),
-			})
+		return fmt.Errorf("failed to describe collection: %v", err)
+	}
+
+	expected := map[string]entity.FieldType{
+		fieldID:       entity.FieldTypeVarChar,
+		fieldContent:  entity.FieldTypeVarChar,
+		fieldMetadata: entity.FieldTypeJSON,
+		fieldVector:   entity.FieldTypeFloatVector,
+	}
+	fields := make(map[string]*entity.Field)
+	if coll.Schema != nil {
+		for _, f := range coll.Schema.Fields {
+			fields[f.Name] = f
+		}
+	}
+	for fieldName, fieldType := range expected {
+		f, ok := fields[fieldName]
+		if !ok {
+			return fmt.Errorf("%w: collection %s is missing field %q", ErrSchemaMismatch, name, fieldName)
+		}
+		if f.DataType != fieldType {
+			return fmt.Errorf("%w: field %q of collection %s has type %s, expected %s",
+				ErrSchemaMismatch, fieldName, name, f.DataType.Name(), fieldType.Name())
 		}
 	}
+	if !fields[fieldID].PrimaryKey {
+		return fmt.Errorf("%w: field %q of collection %s is not the primary key", ErrSchemaMismatch, fieldID, name)
+	}
+
+	dim, err := strconv.Atoi(fields[fieldVector].TypeParams[entity.TypeParamDim])
+	if err != nil {
+		return fmt.Errorf("%w: collection %s has invalid vector dimension: %v", ErrSchemaMismatch, name, err)
+	}
+	if dim != spec.Dimension {
+		return fmt.Errorf("%w: collection %s stores %d-dim vectors but embedding model %q produces %d-dim vectors",
+			ErrSchemaMismatch, name, dim, spec.ModelName, spec.Dimension)
+	}
+
+	if model, ok := coll.Properties[propEmbeddingModel]; ok && model != spec.ModelName {
+		return fmt.Errorf("%w: collection %s was built with embedding model %q, current model is %q",
+			ErrSchemaMismatch, name, model, spec.ModelName)
+	}
+	// 创建时未记录嵌入模型的旧集合在校验通过后补记
+	dimValue := strconv.Itoa(spec.Dimension)
+	if coll.Properties[propEmbeddingModel] != spec.ModelName || coll.Properties[propEmbeddingDim] != dimValue {
+		err := c.AlterCollection(ctx, name,
+			collectionProperty{key: propEmbeddingModel, value: spec.ModelName},
+			collectionProperty{key: propEmbeddingDim, value: dimValue},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to record embedding model on collection: %v", err)
+		}
+	}
+
+	indexes, err := c.DescribeIndex(ctx, name, fieldVector)
+	if err != nil && !isIndexNotFound(err) {
+		return fmt.Errorf("failed to describe index: %v", err)
+	}
+	if len(indexes) == 0 {
+		// 旧集合可能未建索引，按当前度量补建
+		return createVectorIndex(ctx, c, name, spec.Metric)
+	}
+	if metric := indexes[0].Params()["metric_type"]; metric != "" && !strings.EqualFold(metric, string(spec.Metric)) {
+		return fmt.Errorf("%w: collection %s is indexed with metric %s, configured metric is %s",
+			ErrSchemaMismatch, name, metric, spec.Metric)
+	}
+
+	return nil
+}
 
-	return docs, nil
+// isIndexNotFound 判断 DescribeIndex 的错误是否表示字段尚未建索引
+// SDK 只保留服务端返回的错误信息，不同版本的 Milvus 分别返回 "index not found" 和 "index doesn't exist"
+func isIndexNotFound(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "index not found") || strings.Contains(msg, "index doesn't exist") ||
+		strings.Contains(msg, "index not exist")
 }
 
 // WithAddress sets the Milvus server address
@@ -0,0 +1,116 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/milvus-io/milvus-sdk-go/v2/client"
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+)
+
+// fakeCollection 提供 verifyCollection 用到的集合、索引接口
+type fakeCollection struct {
+	client.Client
+
+	dim          int
+	properties   map[string]string
+	indexes      []entity.Index
+	describeErr  error
+	indexCreated bool
+	altered      int
+}
+
+func newFakeCollection(dim int) *fakeCollection {
+	return &fakeCollection{properties: map[string]string{}, dim: dim}
+}
+
+func (f *fakeCollection) DescribeCollection(_ context.Context, name string) (*entity.Collection, error) {
+	schema := entity.NewSchema().WithName(name).
+		WithField(entity.NewField().WithName(fieldID).WithDataType(entity.FieldTypeVarChar).WithIsPrimaryKey(true)).
+		WithField(entity.NewField().WithName(fieldContent).WithDataType(entity.FieldTypeVarChar)).
+		WithField(entity.NewField().WithName(fieldMetadata).WithDataType(entity.FieldTypeJSON)).
+		WithField(entity.NewField().WithName(fieldVector).WithDataType(entity.FieldTypeFloatVector).WithDim(int64(f.dim)))
+	return &entity.Collection{Name: name, Schema: schema, Properties: f.properties}, nil
+}
+
+func (f *fakeCollection) DescribeIndex(context.Context, string, string, ...client.IndexOption) ([]entity.Index, error) {
+	return f.indexes, f.describeErr
+}
+
+func (f *fakeCollection) CreateIndex(context.Context, string, string, entity.Index, bool, ...client.IndexOption) error {
+	f.indexCreated = true
+	return nil
+}
+
+func (f *fakeCollection) AlterCollection(_ context.Context, _ string, attrs ...entity.CollectionAttribute) error {
+	f.altered++
+	for _, attr := range attrs {
+		k, v := attr.KeyValue()
+		f.properties[k] = v
+	}
+	return nil
+}
+
+func testSpec() CollectionSpec {
+	return CollectionSpec{ModelName: "all-MiniLM-L6-v2", Dimension: 3, Metric: entity.COSINE}
+}
+
+func TestVerifyCollectionCreatesMissingIndex(t *testing.T) {
+	fake := newFakeCollection(3)
+	fake.describeErr = errors.New("service failed: index not found[collection=documents]")
+
+	if err := verifyCollection(context.Background(), fake, "documents", testSpec()); err != nil {
+		t.Fatalf("verifyCollection: %v", err)
+	}
+	if !fake.indexCreated {
+		t.Error("index was not created")
+	}
+}
+
+func TestVerifyCollectionReturnsDescribeIndexError(t *testing.T) {
+	fake := newFakeCollection(3)
+	fake.describeErr = errors.New("rpc error: code = Unavailable")
+
+	if err := verifyCollection(context.Background(), fake, "documents", testSpec()); err == nil {
+		t.Fatal("expected DescribeIndex error")
+	}
+	if fake.indexCreated {
+		t.Error("index was created although DescribeIndex failed")
+	}
+}
+
+func TestVerifyCollectionRejectsDimensionMismatch(t *testing.T) {
+	fake := newFakeCollection(4)
+	err := verifyCollection(context.Background(), fake, "documents", testSpec())
+	if !errors.Is(err, ErrSchemaMismatch) {
+		t.Fatalf("err = %v, want ErrSchemaMismatch", err)
+	}
+}
+
+func TestVerifyCollectionRecordsEmbeddingModel(t *testing.T) {
+	fake := newFakeCollection(3)
+	fake.describeErr = errors.New("index not found")
+	spec := testSpec()
+
+	if err := verifyCollection(context.Background(), fake, "documents", spec); err != nil {
+		t.Fatalf("verifyCollection: %v", err)
+	}
+	if fake.properties[propEmbeddingModel] != spec.ModelName || fake.properties[propEmbeddingDim] != strconv.Itoa(spec.Dimension) {
+		t.Errorf("properties = %v", fake.properties)
+	}
+
+	// 已记录时不再修改
+	if err := verifyCollection(context.Background(), fake, "documents", spec); err != nil {
+		t.Fatalf("verifyCollection: %v", err)
+	}
+	if fake.altered != 1 {
+		t.Errorf("AlterCollection called %d times, want 1", fake.altered)
+	}
+
+	spec.ModelName = "bge-small-zh"
+	if err := verifyCollection(context.Background(), fake, "documents", spec); !errors.Is(err, ErrSchemaMismatch) {
+		t.Errorf("err = %v, want ErrSchemaMismatch after the model changed", err)
+	}
+}
@@ -2,47 +2,525 @@ package persistence
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/milvus-io/milvus-sdk-go/v2/client"
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
 	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/document"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/knowledge"
+)
+
+// 集合字段名
+const (
+	fieldID       = "id"
+	fieldContent  = "content"
+	fieldMetadata = "metadata"
+	fieldVector   = "vector"
 )
 
 type MilvusDocumentRepository struct {
 	Client         client.Client
 	CollectionName string
+	Metric         entity.MetricType
+	Dimension      int // 集合向量维度，为0时不校验
+
+	// 已确认存在的分区，避免每次写入都调用 HasPartition
+	partitions sync.Map
+}
+
+// defaultPartition Milvus 集合自带的默认分区，对应默认知识库
+const defaultPartition = "_default"
+
+// partitionName 每个知识库对应一个分区，默认知识库使用集合默认分区
+func partitionName(kbID string) (string, error) {
+	if kbID == "" || kbID == knowledge.DefaultID {
+		return defaultPartition, nil
+	}
+	for _, c := range kbID {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			return "", fmt.Errorf("invalid knowledge base id %q", kbID)
+		}
+	}
+	return "kb_" + kbID, nil
+}
+
+// existingPartition 返回知识库对应的分区，exists 为 false 表示知识库尚未写入任何文档
+func (r *MilvusDocumentRepository) existingPartition(ctx context.Context, kbID string) (string, bool, error) {
+	partition, err := partitionName(kbID)
+	if err != nil {
+		return "", false, err
+	}
+	if partition == defaultPartition {
+		return partition, true, nil
+	}
+	if _, ok := r.partitions.Load(partition); ok {
+		return partition, true, nil
+	}
+	exists, err := r.Client.HasPartition(ctx, r.CollectionName, partition)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to check partition %s: %w", partition, err)
+	}
+	return partition, exists, nil
+}
+
+// ensurePartition 分区不存在时创建并加载
+func (r *MilvusDocumentRepository) ensurePartition(ctx context.Context, partition string) error {
+	if partition == defaultPartition {
+		return nil
+	}
+	if _, ok := r.partitions.Load(partition); ok {
+		return nil
+	}
+
+	exists, err := r.Client.HasPartition(ctx, r.CollectionName, partition)
+	if err != nil {
+		return fmt.Errorf("failed to check partition %s: %w", partition, err)
+	}
+	if !exists {
+		if err := r.Client.CreatePartition(ctx, r.CollectionName, partition); err != nil {
+			return fmt.Errorf("failed to create partition %s: %w", partition, err)
+		}
+	}
+	if err := r.Client.LoadPartitions(ctx, r.CollectionName, []string{partition}, false); err != nil {
+		return fmt.Errorf("failed to load partition %s: %w", partition, err)
+	}
+	r.partitions.Store(partition, struct{}{})
+	return nil
 }
 
 func NewMilvusDocumentRepository(milvusClient *MilvusClient, collectionName string) *MilvusDocumentRepository {
+	metric := milvusClient.Metric
+	if metric == "" {
+		metric = entity.L2
+	}
 	return &MilvusDocumentRepository{
 		Client:         milvusClient.Client,
 		CollectionName: collectionName,
+		Metric:         metric,
+		Dimension:      milvusClient.Dimension,
 	}
 }
 
 func (r *MilvusDocumentRepository) Store(ctx context.Context, doc *document.Document) error {
-	// 实现Milvus存储逻辑
-	// ...
-	return nil
+	return r.StoreBatch(ctx, []*document.Document{doc})
 }
 
+// StoreBatch 按文档所属知识库写入对应分区
 func (r *MilvusDocumentRepository) StoreBatch(ctx context.Context, docs []*document.Document) error {
-	// 实现批量存储逻辑
-	// ...
+	if len(docs) == 0 {
+		return nil
+	}
+
+	var order []string
+	groups := make(map[string][]*document.Document)
+	for i, doc := range docs {
+		if doc == nil {
+			return fmt.Errorf("document at index %d is nil", i)
+		}
+		partition, err := partitionName(doc.Metadata.KnowledgeBaseID)
+		if err != nil {
+			return err
+		}
+		if _, ok := groups[partition]; !ok {
+			order = append(order, partition)
+		}
+		groups[partition] = append(groups[partition], doc)
+	}
+
+	for _, partition := range order {
+		if err := r.ensurePartition(ctx, partition); err != nil {
+			return err
+		}
+		if err := r.insert(ctx, partition, groups[partition]); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// Implement all required methods
-func (r *MilvusDocumentRepository) Save(ctx context.Context, doc *document.Document) error {
+func (r *MilvusDocumentRepository) insert(ctx context.Context, partition string, docs []*document.Document) error {
+
+	ids := make([]string, 0, len(docs))
+	contents := make([]string, 0, len(docs))
+	metadatas := make([][]byte, 0, len(docs))
+	vectors := make([][]float32, 0, len(docs))
+	dim := len(docs[0].Vector)
+	if r.Dimension > 0 && dim != r.Dimension {
+		return fmt.Errorf("vector dimension %d does not match collection dimension %d", dim, r.Dimension)
+	}
+
+	for i, doc := range docs {
+		if len(doc.Vector) == 0 {
+			return fmt.Errorf("document at index %d has no vector", i)
+		}
+		if len(doc.Vector) != dim {
+			return fmt.Errorf("document at index %d has vector dimension %d, expected %d", i, len(doc.Vector), dim)
+		}
+
+		// 未指定ID时自动生成
+		if doc.ID == "" {
+			id, err := document.NewID()
+			if err != nil {
+				return fmt.Errorf("failed to generate document id: %w", err)
+			}
+			doc.ID = id
+		}
+		if doc.CreatedAt.IsZero() {
+			doc.CreatedAt = time.Now()
+		}
+
+		metadataJSON, err := json.Marshal(doc.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to marshal metadata: %w", err)
+		}
+
+		ids = append(ids, doc.ID)
+		contents = append(contents, doc.Content)
+		metadatas = append(metadatas, metadataJSON)
+		vectors = append(vectors, doc.Vector)
+	}
+
+	// 使用 Upsert，指定已有ID的文档会被覆盖而不是产生重复主键
+	_, err := r.Client.Upsert(ctx, r.CollectionName, partition,
+		entity.NewColumnVarChar(fieldID, ids),
+		entity.NewColumnVarChar(fieldContent, contents),
+		entity.NewColumnJSONBytes(fieldMetadata, metadatas),
+		entity.NewColumnFloatVector(fieldVector, dim, vectors),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to upsert documents: %w", err)
+	}
+
 	return nil
-	// Implementation for saving document to Milvus
 }
 
+// Save 等同于 Store，保留以兼容旧调用方
+func (r *MilvusDocumentRepository) Save(ctx context.Context, doc *document.Document) error {
+	return r.Store(ctx, doc)
+}
+
+// FindByID 按主键查询文档，不存在时返回 nil, nil
 func (r *MilvusDocumentRepository) FindByID(ctx context.Context, id string) (*document.Document, error) {
-	return nil, nil
-	// Implementation for finding document by ID in Milvus
+	expr := fmt.Sprintf("%s == %s", fieldID, strconv.Quote(id))
+	rs, err := r.Client.Query(ctx, r.CollectionName, nil, expr,
+		[]string{fieldID, fieldContent, fieldMetadata, fieldVector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query document %s: %w", id, err)
+	}
+
+	docs, err := documentsFromResultSet(rs, rs.Len(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, nil
+	}
+	return docs[0], nil
+}
+
+// Search 在知识库对应分区内返回与 embedding 最相近的 topK 个文档
+// Score 为 Milvus 返回的距离：L2 越小越相近，IP/COSINE 越大越相近
+func (r *MilvusDocumentRepository) Search(ctx context.Context, kbID string, embedding []float32, topK int) ([]*document.Document, error) {
+	if len(embedding) == 0 {
+		return nil, fmt.Errorf("search embedding is empty")
+	}
+	if r.Dimension > 0 && len(embedding) != r.Dimension {
+		return nil, fmt.Errorf("query vector dimension %d does not match collection dimension %d", len(embedding), r.Dimension)
+	}
+	if topK <= 0 {
+		topK = 5
+	}
+	partition, exists, err := r.existingPartition(ctx, kbID)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	sp, err := entity.NewIndexIvfFlatSearchParam(16)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create search param: %w", err)
+	}
+
+	results, err := r.Client.Search(
+		ctx,
+		r.CollectionName,
+		[]string{partition},
+		"",
+		[]string{fieldContent, fieldMetadata},
+		[]entity.Vector{entity.FloatVector(embedding)},
+		fieldVector,
+		r.Metric,
+		topK,
+		sp,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+
+	var docs []*document.Document
+	for _, result := range results {
+		if result.Err != nil {
+			return nil, fmt.Errorf("search result error: %w", result.Err)
+		}
+
+		hits, err := documentsFromResultSet(result.Fields, result.ResultCount, result.IDs)
+		if err != nil {
+			return nil, err
+		}
+		for i, doc := range hits {
+			if i < len(result.Scores) {
+				doc.Score = float64(result.Scores[i])
+			}
+		}
+		docs = append(docs, hits...)
+	}
+
+	return docs, nil
+}
+
+// DeleteByKnowledgeBase 删除知识库的全部向量
+// 独立知识库直接删除分区，默认知识库清空默认分区中的数据
+func (r *MilvusDocumentRepository) DeleteByKnowledgeBase(ctx context.Context, kbID string) error {
+	partition, err := partitionName(kbID)
+	if err != nil {
+		return err
+	}
+
+	if partition == defaultPartition {
+		if err := r.Client.Delete(ctx, r.CollectionName, partition, fieldID+` != ""`); err != nil {
+			return fmt.Errorf("failed to delete documents of default knowledge base: %w", err)
+		}
+		return nil
+	}
+
+	exists, err := r.Client.HasPartition(ctx, r.CollectionName, partition)
+	if err != nil {
+		return fmt.Errorf("failed to check partition %s: %w", partition, err)
+	}
+	if !exists {
+		r.partitions.Delete(partition)
+		return nil
+	}
+	// 已加载的分区需先释放才能删除
+	if err := r.Client.ReleasePartitions(ctx, r.CollectionName, []string{partition}); err != nil {
+		return fmt.Errorf("failed to release partition %s: %w", partition, err)
+	}
+	if err := r.Client.DropPartition(ctx, r.CollectionName, partition); err != nil {
+		return fmt.Errorf("failed to drop partition %s: %w", partition, err)
+	}
+	r.partitions.Delete(partition)
+	return nil
+}
+
+// listPageSize 分页查询的每页条数，offset+limit 不能超过 Milvus 的 maxQueryResultWindow(默认16384)
+const listPageSize = 1000
+
+// FindDocumentByHash 以文档首个分块记录的文件哈希查找文档
+func (r *MilvusDocumentRepository) FindDocumentByHash(ctx context.Context, kbID string, fileHash string) (*document.DocumentInfo, error) {
+	partition, exists, err := r.existingPartition(ctx, kbID)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	expr := fmt.Sprintf(`%s["file_hash"] == %s && %s["chunk_index"] == 0`,
+		fieldMetadata, strconv.Quote(fileHash), fieldMetadata)
+	rs, err := r.Client.Query(ctx, r.CollectionName, []string{partition}, expr,
+		[]string{fieldID, fieldMetadata}, client.WithLimit(1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query document by hash: %w", err)
+	}
+	chunks, err := documentsFromResultSet(rs, rs.Len(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 || chunks[0].Metadata.DocumentID == "" {
+		return nil, nil
+	}
+	return document.InfoFromChunk(chunks[0]), nil
+}
+
+// ListDocuments 以每个文档的首个分块汇总文档信息
+func (r *MilvusDocumentRepository) ListDocuments(ctx context.Context, kbID string) ([]*document.DocumentInfo, error) {
+	partition, exists, err := r.existingPartition(ctx, kbID)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	expr := fmt.Sprintf(`%s["document_id"] != "" && %s["chunk_index"] == 0`, fieldMetadata, fieldMetadata)
+	var infos []*document.DocumentInfo
+	for offset := 0; ; offset += listPageSize {
+		rs, err := r.Client.Query(ctx, r.CollectionName, []string{partition}, expr,
+			[]string{fieldID, fieldMetadata},
+			client.WithOffset(int64(offset)), client.WithLimit(listPageSize))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list documents: %w", err)
+		}
+		chunks, err := documentsFromResultSet(rs, rs.Len(), nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, chunk := range chunks {
+			infos = append(infos, document.InfoFromChunk(chunk))
+		}
+		if len(chunks) < listPageSize {
+			break
+		}
+	}
+
+	sort.SliceStable(infos, func(i, j int) bool { return infos[i].UploadTime.Before(infos[j].UploadTime) })
+	return infos, nil
+}
+
+// FindChunks 查询文档的全部分块
+func (r *MilvusDocumentRepository) FindChunks(ctx context.Context, documentID string) ([]*document.Document, error) {
+	rs, err := r.Client.Query(ctx, r.CollectionName, nil, documentExpr(documentID),
+		[]string{fieldID, fieldContent, fieldMetadata, fieldVector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chunks of document %s: %w", documentID, err)
+	}
+
+	chunks, err := documentsFromResultSet(rs, rs.Len(), nil)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(chunks, func(i, j int) bool {
+		return chunks[i].Metadata.ChunkIndex < chunks[j].Metadata.ChunkIndex
+	})
+	return chunks, nil
+}
+
+// ReplaceDocument 先写入新分块再删除旧分块
+// Milvus 不支持事务，写入失败时旧分块保持不变；删除失败时重试替换即可清理残留
+func (r *MilvusDocumentRepository) ReplaceDocument(ctx context.Context, documentID string, chunks []*document.Document) error {
+	if len(chunks) == 0 {
+		return fmt.Errorf("replacement for document %s has no chunks", documentID)
+	}
+
+	oldIDs, err := r.chunkIDs(ctx, documentID)
+	if err != nil {
+		return err
+	}
+	if err := r.StoreBatch(ctx, chunks); err != nil {
+		return err
+	}
+
+	keep := make(map[string]bool, len(chunks))
+	for _, chunk := range chunks {
+		keep[chunk.ID] = true
+	}
+	stale := oldIDs[:0]
+	for _, id := range oldIDs {
+		if !keep[id] {
+			stale = append(stale, id)
+		}
+	}
+	return r.deleteIDs(ctx, stale)
+}
+
+func (r *MilvusDocumentRepository) DeleteDocument(ctx context.Context, documentID string) error {
+	ids, err := r.chunkIDs(ctx, documentID)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return document.ErrNotFound
+	}
+	return r.deleteIDs(ctx, ids)
+}
+
+func (r *MilvusDocumentRepository) chunkIDs(ctx context.Context, documentID string) ([]string, error) {
+	rs, err := r.Client.Query(ctx, r.CollectionName, nil, documentExpr(documentID), []string{fieldID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chunks of document %s: %w", documentID, err)
+	}
+
+	col := rs.GetColumn(fieldID)
+	if col == nil {
+		return nil, nil
+	}
+	ids := make([]string, 0, col.Len())
+	for i := 0; i < col.Len(); i++ {
+		id, err := col.GetAsString(i)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (r *MilvusDocumentRepository) deleteIDs(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	quoted := make([]string, len(ids))
+	for i, id := range ids {
+		quoted[i] = strconv.Quote(id)
+	}
+	expr := fmt.Sprintf("%s in [%s]", fieldID, strings.Join(quoted, ","))
+	if err := r.Client.Delete(ctx, r.CollectionName, "", expr); err != nil {
+		return fmt.Errorf("failed to delete chunks: %w", err)
+	}
+	return nil
+}
+
+func documentExpr(documentID string) string {
+	return fmt.Sprintf(`%s["document_id"] == %s`, fieldMetadata, strconv.Quote(documentID))
 }
 
-func (r *MilvusDocumentRepository) Search(ctx context.Context, embedding []float32, topK int) ([]*document.Document, error) {
-	// Implementation for searching documents in Milvus
-	return nil, nil
+// documentsFromResultSet 将 Milvus 返回的列数据还原为文档
+// ids 为空时从结果集的 id 列读取主键
+func documentsFromResultSet(rs client.ResultSet, count int, ids entity.Column) ([]*document.Document, error) {
+	if ids == nil {
+		ids = rs.GetColumn(fieldID)
+	}
+	contentCol := rs.GetColumn(fieldContent)
+	metadataCol := rs.GetColumn(fieldMetadata)
+	vectorCol, _ := rs.GetColumn(fieldVector).(*entity.ColumnFloatVector)
+
+	docs := make([]*document.Document, 0, count)
+	for i := 0; i < count; i++ {
+		doc := &document.Document{}
+
+		if ids != nil {
+			id, err := ids.GetAsString(i)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get id: %w", err)
+			}
+			doc.ID = id
+		}
+
+		if contentCol != nil {
+			content, err := contentCol.GetAsString(i)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get content: %w", err)
+			}
+			doc.Content = content
+		}
+
+		if metadataCol != nil {
+			metadataStr, err := metadataCol.GetAsString(i)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get metadata: %w", err)
+			}
+			if metadataStr != "" {
+				if err := json.Unmarshal([]byte(metadataStr), &doc.Metadata); err != nil {
+					return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+				}
+			}
+		}
+
+		if vectorCol != nil && i < len(vectorCol.Data()) {
+			doc.Vector = vectorCol.Data()[i]
+		}
+
+		docs = append(docs, doc)
+	}
+
+	return docs, nil
 }
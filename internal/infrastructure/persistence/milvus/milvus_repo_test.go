@@ -0,0 +1,457 @@
+package persistence
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/milvus-io/milvus-sdk-go/v2/client"
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/document"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/document/documenttest"
+)
+
+// fakeRow 假 Milvus 中的一行数据
+type fakeRow struct {
+	partition string
+	content   string
+	metadata  []byte
+	vector    []float32
+}
+
+// fakeMilvus 在内存中实现仓库用到的 client.Client 方法，未实现的方法调用时 panic
+type fakeMilvus struct {
+	client.Client
+
+	partitions map[string]bool
+	rows       map[string]fakeRow
+	order      []string // 按写入顺序的主键
+
+	upserts       int
+	searchMetric  entity.MetricType
+	searchTopK    int
+	searchOutputs []string
+}
+
+func newFakeMilvus() *fakeMilvus {
+	return &fakeMilvus{
+		partitions: map[string]bool{defaultPartition: true},
+		rows:       make(map[string]fakeRow),
+	}
+}
+
+func (f *fakeMilvus) HasPartition(_ context.Context, _ string, partition string) (bool, error) {
+	return f.partitions[partition], nil
+}
+
+func (f *fakeMilvus) CreatePartition(_ context.Context, _ string, partition string, _ ...client.CreatePartitionOption) error {
+	f.partitions[partition] = true
+	return nil
+}
+
+func (f *fakeMilvus) LoadPartitions(_ context.Context, _ string, partitions []string, _ bool, _ ...client.LoadPartitionsOption) error {
+	for _, p := range partitions {
+		if !f.partitions[p] {
+			return fmt.Errorf("partition %s not found", p)
+		}
+	}
+	return nil
+}
+
+func (f *fakeMilvus) Upsert(_ context.Context, _ string, partition string, columns ...entity.Column) (entity.Column, error) {
+	f.upserts++
+	var ids, contents []string
+	var metadatas [][]byte
+	var vectors [][]float32
+	for _, col := range columns {
+		switch c := col.(type) {
+		case *entity.ColumnVarChar:
+			if c.Name() == fieldID {
+				ids = c.Data()
+			} else {
+				contents = c.Data()
+			}
+		case *entity.ColumnJSONBytes:
+			metadatas = c.Data()
+		case *entity.ColumnFloatVector:
+			vectors = c.Data()
+		default:
+			return nil, fmt.Errorf("unexpected column %s", col.Name())
+		}
+	}
+	for i, id := range ids {
+		if _, ok := f.rows[id]; !ok {
+			f.order = append(f.order, id)
+		}
+		f.rows[id] = fakeRow{partition: partition, content: contents[i], metadata: metadatas[i], vector: vectors[i]}
+	}
+	return entity.NewColumnVarChar(fieldID, ids), nil
+}
+
+// Query 按写入顺序返回指定分区(为空时为全部分区)内满足表达式的行，支持 WithOffset 和 WithLimit
+func (f *fakeMilvus) Query(_ context.Context, _ string, partitions []string, expr string, outputFields []string, opts ...client.SearchQueryOptionFunc) (client.ResultSet, error) {
+	ids, err := f.match(partitions, expr)
+	if err != nil {
+		return nil, err
+	}
+	var opt client.SearchQueryOption
+	for _, o := range opts {
+		o(&opt)
+	}
+	ids = ids[min(int(opt.Offset), len(ids)):]
+	if opt.Limit > 0 && int(opt.Limit) < len(ids) {
+		ids = ids[:opt.Limit]
+	}
+	return f.columns(ids, outputFields), nil
+}
+
+func (f *fakeMilvus) Delete(_ context.Context, _ string, partition string, expr string) error {
+	var partitions []string
+	if partition != "" {
+		partitions = []string{partition}
+	}
+	ids, err := f.match(partitions, expr)
+	if err != nil {
+		return err
+	}
+	f.remove(func(id string) bool { return slices.Contains(ids, id) })
+	return nil
+}
+
+func (f *fakeMilvus) ReleasePartitions(_ context.Context, _ string, partitions []string, _ ...client.ReleasePartitionsOption) error {
+	for _, p := range partitions {
+		if !f.partitions[p] {
+			return fmt.Errorf("partition %s not found", p)
+		}
+	}
+	return nil
+}
+
+func (f *fakeMilvus) DropPartition(_ context.Context, _ string, partition string, _ ...client.DropPartitionOption) error {
+	if !f.partitions[partition] {
+		return fmt.Errorf("partition %s not found", partition)
+	}
+	delete(f.partitions, partition)
+	f.remove(func(id string) bool { return f.rows[id].partition == partition })
+	return nil
+}
+
+func (f *fakeMilvus) remove(match func(id string) bool) {
+	order := f.order[:0]
+	for _, id := range f.order {
+		if match(id) {
+			delete(f.rows, id)
+			continue
+		}
+		order = append(order, id)
+	}
+	f.order = order
+}
+
+// match 返回分区内满足表达式的主键，表达式只支持仓库用到的形式：
+// 以 && 连接的 `id == "..."`、`id != "..."`、`id in ["...", ...]`、`metadata["key"] == 值` 和 `metadata["key"] != 值`
+func (f *fakeMilvus) match(partitions []string, expr string) ([]string, error) {
+	var ids []string
+	for _, id := range f.order {
+		row := f.rows[id]
+		if len(partitions) > 0 && !slices.Contains(partitions, row.partition) {
+			continue
+		}
+		ok, err := evalExpr(expr, id, row)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func evalExpr(expr, id string, row fakeRow) (bool, error) {
+	if expr == "" {
+		return true, nil
+	}
+	for _, clause := range strings.Split(expr, " && ") {
+		ok, err := evalClause(clause, id, row)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func evalClause(clause, id string, row fakeRow) (bool, error) {
+	if list, ok := strings.CutPrefix(clause, fieldID+" in "); ok {
+		var values []string
+		if err := json.Unmarshal([]byte(list), &values); err != nil {
+			return false, fmt.Errorf("unsupported expression %q", clause)
+		}
+		return slices.Contains(values, id), nil
+	}
+	for _, op := range []string{" == ", " != "} {
+		lhs, rhs, ok := strings.Cut(clause, op)
+		if !ok {
+			continue
+		}
+		var want any
+		if err := json.Unmarshal([]byte(rhs), &want); err != nil {
+			return false, fmt.Errorf("unsupported value in %q", clause)
+		}
+		var got any = id
+		if lhs != fieldID {
+			key, ok := strings.CutPrefix(lhs, fieldMetadata+"[")
+			if !ok {
+				return false, fmt.Errorf("unsupported field in %q", clause)
+			}
+			var meta map[string]any
+			if err := json.Unmarshal(row.metadata, &meta); err != nil {
+				return false, err
+			}
+			// 与 Milvus 一致，JSON 中不存在的键不满足任何比较
+			if got, ok = meta[strings.Trim(key, `"]`)]; !ok {
+				return false, nil
+			}
+		}
+		return (got == want) == (op == " == "), nil
+	}
+	return false, fmt.Errorf("unsupported expression %q", clause)
+}
+
+// Search 按写入顺序返回分区内的全部行，第 i 个结果的得分为 i+0.5
+func (f *fakeMilvus) Search(_ context.Context, _ string, partitions []string, _ string, outputFields []string,
+	_ []entity.Vector, _ string, metric entity.MetricType, topK int, _ entity.SearchParam, _ ...client.SearchQueryOptionFunc) ([]client.SearchResult, error) {
+	f.searchMetric, f.searchTopK, f.searchOutputs = metric, topK, outputFields
+
+	var ids []string
+	var scores []float32
+	for _, id := range f.order {
+		if f.rows[id].partition == partitions[0] && len(ids) < topK {
+			ids = append(ids, id)
+			scores = append(scores, float32(len(scores))+0.5)
+		}
+	}
+	return []client.SearchResult{{
+		ResultCount: len(ids),
+		IDs:         entity.NewColumnVarChar(fieldID, ids),
+		Fields:      f.columns(ids, outputFields),
+		Scores:      scores,
+	}}, nil
+}
+
+func (f *fakeMilvus) columns(ids []string, outputFields []string) client.ResultSet {
+	var contents []string
+	var metadatas [][]byte
+	var vectors [][]float32
+	for _, id := range ids {
+		row := f.rows[id]
+		contents = append(contents, row.content)
+		metadatas = append(metadatas, row.metadata)
+		vectors = append(vectors, row.vector)
+	}
+	var rs client.ResultSet
+	for _, field := range outputFields {
+		switch field {
+		case fieldID:
+			rs = append(rs, entity.NewColumnVarChar(fieldID, ids))
+		case fieldContent:
+			rs = append(rs, entity.NewColumnVarChar(fieldContent, contents))
+		case fieldMetadata:
+			rs = append(rs, entity.NewColumnJSONBytes(fieldMetadata, metadatas))
+		case fieldVector:
+			rs = append(rs, entity.NewColumnFloatVector(fieldVector, 3, vectors))
+		}
+	}
+	return rs
+}
+
+func newTestRepository() (*MilvusDocumentRepository, *fakeMilvus) {
+	fake := newFakeMilvus()
+	repo := NewMilvusDocumentRepository(&MilvusClient{
+		Client:         fake,
+		CollectionName: "documents",
+		Metric:         entity.COSINE,
+		Dimension:      3,
+	}, "documents")
+	return repo, fake
+}
+
+func TestRepositoryContract(t *testing.T) {
+	documenttest.TestRepository(t, func(t *testing.T) document.DocumentRepository {
+		repo, _ := newTestRepository()
+		return repo
+	})
+}
+
+func testChunk(id, kbID, content string, vector []float32) *document.Document {
+	return &document.Document{
+		ID:      id,
+		Content: content,
+		Vector:  vector,
+		Metadata: document.Metadata{
+			KnowledgeBaseID: kbID,
+			DocumentID:      "doc-1",
+			ChunkIndex:      1,
+			Filename:        "guide.pdf",
+			ContentType:     "application/pdf",
+			Provenance:      document.Provenance{PageStart: 2, PageEnd: 3},
+			Custom:          map[string]interface{}{"team": "search"},
+		},
+	}
+}
+
+func TestStoreBatchWritesColumns(t *testing.T) {
+	repo, fake := newTestRepository()
+	ctx := context.Background()
+
+	generated := testChunk("", "", "first chunk", []float32{0.1, 0.2, 0.3})
+	fixed := testChunk("fixed-id", "", "second chunk", []float32{0.4, 0.5, 0.6})
+	if err := repo.StoreBatch(ctx, []*document.Document{generated, fixed}); err != nil {
+		t.Fatalf("StoreBatch: %v", err)
+	}
+
+	if len(generated.ID) != 32 {
+		t.Fatalf("generated id %q, want 32 hex characters", generated.ID)
+	}
+	if _, err := hex.DecodeString(generated.ID); err != nil {
+		t.Fatalf("generated id %q is not hex: %v", generated.ID, err)
+	}
+	if generated.CreatedAt.IsZero() {
+		t.Error("CreatedAt was not set")
+	}
+	if fake.upserts != 1 {
+		t.Errorf("upserts = %d, want 1 batch", fake.upserts)
+	}
+
+	for _, doc := range []*document.Document{generated, fixed} {
+		row, ok := fake.rows[doc.ID]
+		if !ok {
+			t.Fatalf("row %s was not written", doc.ID)
+		}
+		if row.partition != defaultPartition {
+			t.Errorf("row %s partition = %q, want %q", doc.ID, row.partition, defaultPartition)
+		}
+		if row.content != doc.Content {
+			t.Errorf("row %s content = %q, want %q", doc.ID, row.content, doc.Content)
+		}
+		if !reflect.DeepEqual(row.vector, doc.Vector) {
+			t.Errorf("row %s vector = %v, want %v", doc.ID, row.vector, doc.Vector)
+		}
+		var meta document.Metadata
+		if err := json.Unmarshal(row.metadata, &meta); err != nil {
+			t.Fatalf("row %s metadata is not JSON: %v", doc.ID, err)
+		}
+		if meta.DocumentID != "doc-1" || meta.Filename != "guide.pdf" || meta.PageStart != 2 || meta.Custom["team"] != "search" {
+			t.Errorf("row %s metadata = %+v", doc.ID, meta)
+		}
+	}
+}
+
+func TestStoreCreatesKnowledgeBasePartition(t *testing.T) {
+	repo, fake := newTestRepository()
+
+	doc := testChunk("a", "team", "content", []float32{1, 0, 0})
+	if err := repo.Store(context.Background(), doc); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if !fake.partitions["kb_team"] {
+		t.Fatal("partition kb_team was not created")
+	}
+	if got := fake.rows["a"].partition; got != "kb_team" {
+		t.Errorf("partition = %q, want kb_team", got)
+	}
+}
+
+func TestStoreRejectsDimensionMismatch(t *testing.T) {
+	repo, fake := newTestRepository()
+
+	err := repo.Store(context.Background(), testChunk("a", "", "content", []float32{1, 0}))
+	if err == nil {
+		t.Fatal("expected dimension mismatch error")
+	}
+	if fake.upserts != 0 {
+		t.Errorf("upserts = %d, want 0", fake.upserts)
+	}
+}
+
+func TestFindByID(t *testing.T) {
+	repo, _ := newTestRepository()
+	ctx := context.Background()
+
+	stored := testChunk("chunk-1", "", "hello milvus", []float32{0.1, 0.2, 0.3})
+	if err := repo.Store(ctx, stored); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+
+	doc, err := repo.FindByID(ctx, "chunk-1")
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	if doc == nil {
+		t.Fatal("FindByID returned nil")
+	}
+	if doc.ID != "chunk-1" || doc.Content != "hello milvus" {
+		t.Errorf("doc = %q %q", doc.ID, doc.Content)
+	}
+	if !reflect.DeepEqual(doc.Vector, stored.Vector) {
+		t.Errorf("vector = %v, want %v", doc.Vector, stored.Vector)
+	}
+	if doc.Metadata.Filename != "guide.pdf" || doc.Metadata.PageEnd != 3 {
+		t.Errorf("metadata = %+v", doc.Metadata)
+	}
+
+	missing, err := repo.FindByID(ctx, "missing")
+	if err != nil || missing != nil {
+		t.Errorf("FindByID(missing) = %v, %v; want nil, nil", missing, err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	repo, fake := newTestRepository()
+	ctx := context.Background()
+
+	docs := []*document.Document{
+		testChunk("a", "team", "alpha", []float32{1, 0, 0}),
+		testChunk("b", "team", "beta", []float32{0, 1, 0}),
+		testChunk("c", "", "default kb", []float32{0, 0, 1}),
+	}
+	if err := repo.StoreBatch(ctx, docs); err != nil {
+		t.Fatalf("StoreBatch: %v", err)
+	}
+
+	results, err := repo.Search(ctx, "team", []float32{1, 0, 0}, 5)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	for i, want := range []struct {
+		id, content string
+		score       float64
+	}{{"a", "alpha", 0.5}, {"b", "beta", 1.5}} {
+		got := results[i]
+		if got.ID != want.id || got.Content != want.content || got.Score != want.score {
+			t.Errorf("result %d = %s %q %v, want %s %q %v", i, got.ID, got.Content, got.Score, want.id, want.content, want.score)
+		}
+		if got.Metadata.KnowledgeBaseID != "team" || got.Metadata.Custom["team"] != "search" {
+			t.Errorf("result %d metadata = %+v", i, got.Metadata)
+		}
+	}
+	if fake.searchMetric != entity.COSINE || fake.searchTopK != 5 {
+		t.Errorf("search metric/topK = %s/%d", fake.searchMetric, fake.searchTopK)
+	}
+
+	if _, err := repo.Search(ctx, "team", []float32{1, 0}, 5); err == nil {
+		t.Error("expected error for query vector dimension mismatch")
+	}
+	empty, err := repo.Search(ctx, "unknown", []float32{1, 0, 0}, 5)
+	if err != nil || len(empty) != 0 {
+		t.Errorf("Search(unknown kb) = %v, %v; want no results", empty, err)
+	}
+}
@@ -0,0 +1,157 @@
+package tokenizer
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// bpeCacheSize 缓存的词数上限，超过后清空
+const bpeCacheSize = 10000
+
+// gpt2Pattern GPT-2 的预切分规则(RE2 不支持环视，省略了 \s+(?!\S))
+var gpt2Pattern = regexp.MustCompile(`'s|'t|'re|'ve|'m|'ll|'d| ?\p{L}+| ?\p{N}+| ?[^\s\p{L}\p{N}]+|\s+`)
+
+// BPE 字节对编码分词器，支持 GPT-2 风格的 ByteLevel 和 SentencePiece 风格的 Metaspace 预切分
+type BPE struct {
+	vocab          map[string]int
+	ranks          map[[2]string]int
+	UnkToken       string
+	Lowercase      bool
+	ByteLevel      bool
+	AddPrefixSpace bool
+	Metaspace      string // 替换空格的字符，为空表示按空白切词
+
+	mu    sync.Mutex
+	cache map[string][]string
+}
+
+func NewBPE(vocab map[string]int, merges [][2]string) *BPE {
+	ranks := make(map[[2]string]int, len(merges))
+	for i, m := range merges {
+		ranks[m] = i
+	}
+	return &BPE{
+		vocab: vocab,
+		ranks: ranks,
+		cache: make(map[string][]string),
+	}
+}
+
+func (t *BPE) Tokenize(text string) []string {
+	var tokens []string
+	for _, word := range t.preTokenize(text) {
+		tokens = append(tokens, t.bpe(word)...)
+	}
+	return tokens
+}
+
+func (t *BPE) CountTokens(text string) int {
+	n := 0
+	for _, word := range t.preTokenize(text) {
+		n += len(t.bpe(word))
+	}
+	return n
+}
+
+// preTokenize 切分为待合并的词，ByteLevel 模式下每个字节映射为一个可见字符
+func (t *BPE) preTokenize(text string) []string {
+	if t.Lowercase {
+		text = strings.ToLower(text)
+	}
+
+	switch {
+	case t.ByteLevel:
+		if t.AddPrefixSpace && !strings.HasPrefix(text, " ") {
+			text = " " + text
+		}
+		words := gpt2Pattern.FindAllString(text, -1)
+		for i, w := range words {
+			var b strings.Builder
+			for j := 0; j < len(w); j++ {
+				b.WriteRune(byteToRune[w[j]])
+			}
+			words[i] = b.String()
+		}
+		return words
+
+	case t.Metaspace != "":
+		fields := strings.Fields(text)
+		for i, f := range fields {
+			fields[i] = t.Metaspace + f
+		}
+		return fields
+
+	default:
+		return strings.Fields(text)
+	}
+}
+
+// bpe 按合并规则的优先级反复合并相邻符号
+func (t *BPE) bpe(word string) []string {
+	t.mu.Lock()
+	cached, ok := t.cache[word]
+	t.mu.Unlock()
+	if ok {
+		return cached
+	}
+
+	symbols := make([]string, 0, utf8.RuneCountInString(word))
+	for _, r := range word {
+		symbols = append(symbols, string(r))
+	}
+	for len(symbols) > 1 {
+		best, bestRank := -1, 0
+		for i := 0; i < len(symbols)-1; i++ {
+			if rank, ok := t.ranks[[2]string{symbols[i], symbols[i+1]}]; ok && (best == -1 || rank < bestRank) {
+				best, bestRank = i, rank
+			}
+		}
+		if best == -1 {
+			break
+		}
+		pair := [2]string{symbols[best], symbols[best+1]}
+		merged := symbols[:0:0]
+		for i := 0; i < len(symbols); i++ {
+			if i < len(symbols)-1 && symbols[i] == pair[0] && symbols[i+1] == pair[1] {
+				merged = append(merged, pair[0]+pair[1])
+				i++
+			} else {
+				merged = append(merged, symbols[i])
+			}
+		}
+		symbols = merged
+	}
+
+	if t.UnkToken != "" {
+		for i, s := range symbols {
+			if _, ok := t.vocab[s]; !ok {
+				symbols[i] = t.UnkToken
+			}
+		}
+	}
+
+	t.mu.Lock()
+	if len(t.cache) >= bpeCacheSize {
+		t.cache = make(map[string][]string)
+	}
+	t.cache[word] = symbols
+	t.mu.Unlock()
+	return symbols
+}
+
+// byteToRune GPT-2 的字节到可见 Unicode 字符映射
+var byteToRune = func() [256]rune {
+	var table [256]rune
+	n := 0
+	for b := 0; b < 256; b++ {
+		if (b >= '!' && b <= '~') || (b >= 0xA1 && b <= 0xAC) || (b >= 0xAE && b <= 0xFF) {
+			table[b] = rune(b)
+		} else {
+			table[b] = rune(256 + n)
+			n++
+		}
+	}
+	return table
+}()
@@ -0,0 +1,214 @@
+package tokenizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Tokenizer 将文本切分为嵌入模型词表中的 token，用于按 token 数分块
+type Tokenizer interface {
+	Tokenize(text string) []string
+	CountTokens(text string) int
+}
+
+// Load 加载 Hugging Face 分词器
+// path 可以是 tokenizer.json、vocab.txt，或包含它们的模型目录(优先使用 tokenizer.json)
+func Load(path string) (Tokenizer, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tokenizer: %w", err)
+	}
+	if info.IsDir() {
+		for _, name := range []string{"tokenizer.json", "vocab.txt"} {
+			candidate := filepath.Join(path, name)
+			if _, err := os.Stat(candidate); err == nil {
+				return Load(candidate)
+			}
+		}
+		return nil, fmt.Errorf("no tokenizer.json or vocab.txt found in %s", path)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return loadTokenizerJSON(path)
+	}
+	return loadVocabFile(path)
+}
+
+// tokenizerFile tokenizer.json 中用到的字段
+type tokenizerFile struct {
+	Normalizer   *component `json:"normalizer"`
+	PreTokenizer *component `json:"pre_tokenizer"`
+	Model        struct {
+		Type                    string          `json:"type"`
+		Vocab                   json.RawMessage `json:"vocab"`
+		Merges                  json.RawMessage `json:"merges"`
+		UnkToken                *string         `json:"unk_token"`
+		ContinuingSubwordPrefix *string         `json:"continuing_subword_prefix"`
+		MaxInputCharsPerWord    int             `json:"max_input_chars_per_word"`
+	} `json:"model"`
+}
+
+// component normalizer 或 pre_tokenizer 配置，Sequence 类型包含子配置
+type component struct {
+	Type               string      `json:"type"`
+	Lowercase          *bool       `json:"lowercase"`
+	StripAccents       *bool       `json:"strip_accents"`
+	HandleChineseChars *bool       `json:"handle_chinese_chars"`
+	AddPrefixSpace     *bool       `json:"add_prefix_space"`
+	Replacement        string      `json:"replacement"`
+	Normalizers        []component `json:"normalizers"`
+	PreTokenizers      []component `json:"pretokenizers"`
+}
+
+// find 在自身及 Sequence 子配置中查找指定类型
+func (c *component) find(typ string) *component {
+	if c == nil {
+		return nil
+	}
+	if c.Type == typ {
+		return c
+	}
+	for _, children := range [][]component{c.Normalizers, c.PreTokenizers} {
+		for i := range children {
+			if found := children[i].find(typ); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+func loadTokenizerJSON(path string) (Tokenizer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tokenizer file: %w", err)
+	}
+	var file tokenizerFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse tokenizer file: %w", err)
+	}
+
+	var vocab map[string]int
+	if err := json.Unmarshal(file.Model.Vocab, &vocab); err != nil {
+		return nil, fmt.Errorf("failed to parse tokenizer vocab: %w", err)
+	}
+
+	switch file.Model.Type {
+	case "WordPiece":
+		t := NewWordPiece(vocab)
+		if file.Model.UnkToken != nil {
+			t.UnkToken = *file.Model.UnkToken
+		}
+		if file.Model.ContinuingSubwordPrefix != nil {
+			t.Prefix = *file.Model.ContinuingSubwordPrefix
+		}
+		if file.Model.MaxInputCharsPerWord > 0 {
+			t.MaxInputChars = file.Model.MaxInputCharsPerWord
+		}
+		if n := file.Normalizer.find("BertNormalizer"); n != nil {
+			if n.Lowercase != nil {
+				t.Lowercase = *n.Lowercase
+			}
+			// strip_accents 未设置时跟随 lowercase
+			t.StripAccents = t.Lowercase
+			if n.StripAccents != nil {
+				t.StripAccents = *n.StripAccents
+			}
+			if n.HandleChineseChars != nil {
+				t.ChineseChars = *n.HandleChineseChars
+			}
+		} else {
+			t.Lowercase = file.Normalizer.find("Lowercase") != nil
+			t.StripAccents = file.Normalizer.find("StripAccents") != nil
+		}
+		return t, nil
+
+	case "BPE":
+		merges, err := parseMerges(file.Model.Merges)
+		if err != nil {
+			return nil, err
+		}
+		t := NewBPE(vocab, merges)
+		if file.Model.UnkToken != nil {
+			t.UnkToken = *file.Model.UnkToken
+		}
+		t.Lowercase = file.Normalizer.find("Lowercase") != nil
+		if p := file.PreTokenizer.find("ByteLevel"); p != nil {
+			t.ByteLevel = true
+			t.AddPrefixSpace = p.AddPrefixSpace != nil && *p.AddPrefixSpace
+		} else if p := file.PreTokenizer.find("Metaspace"); p != nil {
+			t.Metaspace = p.Replacement
+			if t.Metaspace == "" {
+				t.Metaspace = "▁"
+			}
+		}
+		return t, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported tokenizer model type %q", file.Model.Type)
+	}
+}
+
+// parseMerges 兼容 "a b" 和 ["a", "b"] 两种格式
+func parseMerges(raw json.RawMessage) ([][2]string, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, fmt.Errorf("failed to parse tokenizer merges: %w", err)
+	}
+
+	merges := make([][2]string, 0, len(items))
+	for _, item := range items {
+		var pair []string
+		if err := json.Unmarshal(item, &pair); err != nil {
+			var s string
+			if err := json.Unmarshal(item, &s); err != nil {
+				return nil, fmt.Errorf("failed to parse tokenizer merge %s: %w", item, err)
+			}
+			pair = strings.SplitN(s, " ", 2)
+		}
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("invalid tokenizer merge %s", item)
+		}
+		merges = append(merges, [2]string{pair[0], pair[1]})
+	}
+	return merges, nil
+}
+
+// loadVocabFile 加载 BERT 的 vocab.txt，每行一个 token，行号即 ID
+// 同目录下 tokenizer_config.json 的 do_lower_case 决定是否转小写，默认转小写
+func loadVocabFile(path string) (Tokenizer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vocab file: %w", err)
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\r\n"), "\n")
+	vocab := make(map[string]int, len(lines))
+	for i, line := range lines {
+		vocab[strings.TrimRight(line, "\r")] = i
+	}
+
+	t := NewWordPiece(vocab)
+	if cfg, err := os.ReadFile(filepath.Join(filepath.Dir(path), "tokenizer_config.json")); err == nil {
+		var settings struct {
+			DoLowerCase  *bool `json:"do_lower_case"`
+			StripAccents *bool `json:"strip_accents"`
+		}
+		if err := json.Unmarshal(cfg, &settings); err != nil {
+			return nil, fmt.Errorf("failed to parse tokenizer config: %w", err)
+		}
+		if settings.DoLowerCase != nil {
+			t.Lowercase = *settings.DoLowerCase
+			t.StripAccents = t.Lowercase
+		}
+		if settings.StripAccents != nil {
+			t.StripAccents = *settings.StripAccents
+		}
+	}
+	return t, nil
+}
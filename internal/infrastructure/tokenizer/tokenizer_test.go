@@ -0,0 +1,142 @@
+package tokenizer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/document"
+)
+
+// modelDir 部署时随嵌入服务提供的 all-MiniLM-L6-v2 模型文件，与 bert-base-uncased 共用词表
+const modelDir = "../../../deployments/huggingface-server/models/all-MiniLM-L6-v2"
+
+func loadModel(t *testing.T, name string) Tokenizer {
+	t.Helper()
+	tok, err := Load(filepath.Join(modelDir, name))
+	if err != nil {
+		t.Fatalf("Load(%s): %v", name, err)
+	}
+	return tok
+}
+
+// miniLMCases 与 Hugging Face BertTokenizer 的输出一致
+var miniLMCases = []struct {
+	text   string
+	tokens []string
+}{
+	{"Hello, world!", []string{"hello", ",", "world", "!"}},
+	{"I have a new GPU!", []string{"i", "have", "a", "new", "gp", "##u", "!"}},
+	{"unaffable tokenization", []string{"una", "##ffa", "##ble", "token", "##ization"}},
+	{"Café Déjà vu", []string{"cafe", "de", "##ja", "vu"}},
+	{"Don't you love 🤗 Transformers?", []string{"don", "'", "t", "you", "love", "[UNK]", "transformers", "?"}},
+	// 每个汉字单独成词，词表中没有的汉字记为 [UNK]
+	{"中国人 世界", []string{"中", "国", "人", "世", "[UNK]"}},
+	{"  \t\n ", nil},
+}
+
+func TestLoadMiniLM(t *testing.T) {
+	// tokenizer.json、vocab.txt 和模型目录应得到相同的分词结果
+	for _, name := range []string{"tokenizer.json", "vocab.txt", ""} {
+		tok := loadModel(t, name)
+		wp, ok := tok.(*WordPiece)
+		if !ok {
+			t.Fatalf("Load(%q) = %T, want *WordPiece", name, tok)
+		}
+		if !wp.Lowercase || !wp.StripAccents || !wp.ChineseChars {
+			t.Errorf("Load(%q) settings = %+v", name, wp)
+		}
+		for _, tc := range miniLMCases {
+			if got := tok.Tokenize(tc.text); !reflect.DeepEqual(got, tc.tokens) {
+				t.Errorf("Load(%q).Tokenize(%q) = %q, want %q", name, tc.text, got, tc.tokens)
+			}
+			if got := tok.CountTokens(tc.text); got != len(tc.tokens) {
+				t.Errorf("Load(%q).CountTokens(%q) = %d, want %d", name, tc.text, got, len(tc.tokens))
+			}
+		}
+	}
+}
+
+func TestWordPieceLongWordIsUnknown(t *testing.T) {
+	tok := loadModel(t, "tokenizer.json")
+	long := strings.Repeat("a", 101)
+	if got := tok.Tokenize("ok " + long); !reflect.DeepEqual(got, []string{"ok", "[UNK]"}) {
+		t.Errorf("Tokenize = %q, want the over-long word as a single [UNK]", got)
+	}
+}
+
+func TestLoadByteLevelBPE(t *testing.T) {
+	// merges 同时使用 "a b" 和 ["a", "b"] 两种格式
+	const file = `{
+		"normalizer": {"type": "Lowercase"},
+		"pre_tokenizer": {"type": "ByteLevel", "add_prefix_space": false},
+		"model": {
+			"type": "BPE",
+			"vocab": {"hello": 0, "Ġworld": 1, "!": 2},
+			"merges": ["h e", "l l", ["he", "ll"], "hell o", ["Ġ", "w"], "o r", "Ġw or", "Ġwor l", "Ġworl d"]
+		}
+	}`
+	path := filepath.Join(t.TempDir(), "tokenizer.json")
+	if err := os.WriteFile(path, []byte(file), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tok, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := tok.Tokenize("Hello World!"); !reflect.DeepEqual(got, []string{"hello", "Ġworld", "!"}) {
+		t.Errorf("Tokenize = %q", got)
+	}
+	if got := tok.CountTokens("hello world world"); got != 3 {
+		t.Errorf("CountTokens = %d, want 3", got)
+	}
+}
+
+func TestSplitterCountsMiniLMTokens(t *testing.T) {
+	tok := loadModel(t, "tokenizer.json")
+	const size, overlap = 24, 4
+	s := document.NewTextSplitter(size, overlap)
+	s.Tokenizer = tok
+	s.Mode = document.SplitWords
+	text := "Retrieval augmented generation combines a search index with a language model. " +
+		"Documents are split into chunks, embedded, and stored in a vector database. " +
+		"At query time the closest chunks are retrieved and passed to the model as context. " +
+		"Chunk sizes are measured in model tokens so that every chunk fits the embedding window."
+
+	chunks, err := s.Split(text)
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	if len(chunks) < 4 {
+		t.Fatalf("got %d chunks, want several", len(chunks))
+	}
+	for i, c := range chunks {
+		c = strings.TrimSpace(c)
+		if n := tok.CountTokens(c); n > size {
+			t.Errorf("chunk %d %q has %d tokens, want at most %d", i, c, n, size)
+		}
+		// 按字节计数时分块不会超过24字节
+		if len(c) <= size {
+			t.Errorf("chunk %d %q is counted in bytes", i, c)
+		}
+		if i == 0 {
+			continue
+		}
+		// 重叠部分是上一个分块末尾的完整的词，不超过 overlap 个 token
+		prev := strings.Fields(strings.TrimSpace(chunks[i-1]))
+		words := strings.Fields(c)
+		n := 0
+		for n < len(words) && n < len(prev) && strings.Join(prev[len(prev)-n-1:], " ") != strings.Join(words[:n+1], " ") {
+			n++
+		}
+		if n == len(words) || n == len(prev) {
+			t.Errorf("chunk %d %q does not start with whole words from the end of %q", i, c, chunks[i-1])
+			continue
+		}
+		if got := tok.CountTokens(strings.Join(words[:n+1], " ")); got > overlap {
+			t.Errorf("chunk %d overlap %q has %d tokens, want at most %d", i, words[:n+1], got, overlap)
+		}
+	}
+}
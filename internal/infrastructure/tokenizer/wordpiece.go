@@ -0,0 +1,156 @@
+package tokenizer
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// WordPiece BERT 系列模型的分词器：先按空白和标点切词，再按最长匹配切分为子词
+type WordPiece struct {
+	vocab         map[string]int
+	UnkToken      string
+	Prefix        string // 非词首子词的前缀
+	MaxInputChars int    // 超过该长度的词直接记为 UnkToken
+	Lowercase     bool
+	StripAccents  bool
+	ChineseChars  bool // 每个汉字单独成词
+}
+
+func NewWordPiece(vocab map[string]int) *WordPiece {
+	return &WordPiece{
+		vocab:         vocab,
+		UnkToken:      "[UNK]",
+		Prefix:        "##",
+		MaxInputChars: 100,
+		Lowercase:     true,
+		StripAccents:  true,
+		ChineseChars:  true,
+	}
+}
+
+func (t *WordPiece) Tokenize(text string) []string {
+	var tokens []string
+	for _, word := range t.basicTokenize(text) {
+		tokens = append(tokens, t.wordPiece(word)...)
+	}
+	return tokens
+}
+
+func (t *WordPiece) CountTokens(text string) int {
+	n := 0
+	for _, word := range t.basicTokenize(text) {
+		n += len(t.wordPiece(word))
+	}
+	return n
+}
+
+// basicTokenize 清理控制字符、规范化大小写和重音，按空白、标点和汉字切词
+func (t *WordPiece) basicTokenize(text string) []string {
+	if t.Lowercase {
+		text = strings.ToLower(text)
+	}
+	if t.StripAccents {
+		text = stripAccents(text)
+	}
+
+	var words []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			words = append(words, word.String())
+			word.Reset()
+		}
+	}
+	for _, r := range text {
+		switch {
+		case r == 0 || r == unicode.ReplacementChar || isControl(r):
+			continue
+		case unicode.IsSpace(r):
+			flush()
+		case isPunctuation(r) || (t.ChineseChars && isChinese(r)):
+			flush()
+			words = append(words, string(r))
+		default:
+			word.WriteRune(r)
+		}
+	}
+	flush()
+	return words
+}
+
+// wordPiece 贪心地取词表中最长的前缀，无法切分时返回 UnkToken
+func (t *WordPiece) wordPiece(word string) []string {
+	if len([]rune(word)) > t.MaxInputChars {
+		return []string{t.UnkToken}
+	}
+
+	var tokens []string
+	for start := 0; start < len(word); {
+		end := len(word)
+		var match string
+		for end > start {
+			piece := word[start:end]
+			if start > 0 {
+				piece = t.Prefix + piece
+			}
+			if _, ok := t.vocab[piece]; ok {
+				match = piece
+				break
+			}
+			// 回退一个字符，保持在 UTF-8 字符边界上
+			end--
+			for end > start && !isRuneStart(word[end]) {
+				end--
+			}
+		}
+		if match == "" {
+			return []string{t.UnkToken}
+		}
+		tokens = append(tokens, match)
+		start = end
+	}
+	return tokens
+}
+
+func stripAccents(text string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(text) {
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func isControl(r rune) bool {
+	if r == '\t' || r == '\n' || r == '\r' {
+		return false
+	}
+	return unicode.In(r, unicode.Cc, unicode.Cf)
+}
+
+// isPunctuation 与 BERT 一致，所有非字母数字的 ASCII 符号也视为标点
+func isPunctuation(r rune) bool {
+	if (r >= 33 && r <= 47) || (r >= 58 && r <= 64) || (r >= 91 && r <= 96) || (r >= 123 && r <= 126) {
+		return true
+	}
+	return unicode.IsPunct(r)
+}
+
+// isChinese CJK 统一表意文字区块
+func isChinese(r rune) bool {
+	return (r >= 0x4E00 && r <= 0x9FFF) ||
+		(r >= 0x3400 && r <= 0x4DBF) ||
+		(r >= 0x20000 && r <= 0x2A6DF) ||
+		(r >= 0x2A700 && r <= 0x2B73F) ||
+		(r >= 0x2B740 && r <= 0x2B81F) ||
+		(r >= 0x2B820 && r <= 0x2CEAF) ||
+		(r >= 0xF900 && r <= 0xFAFF) ||
+		(r >= 0x2F800 && r <= 0x2FA1F)
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/application/commands"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/application/queries"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/application/services"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/infrastructure/logger"
+)
+
+// DocumentHandler 提供已上传文档的查询、替换与删除接口
+type DocumentHandler struct {
+	ingestion     *services.IngestionService
+	deleteHandler *commands.DeleteDocumentHandler
+	queryHandler  *queries.DocumentQueryHandler
+	limits        UploadLimits
+}
+
+func NewDocumentHandler(
+	ingestion *services.IngestionService,
+	del *commands.DeleteDocumentHandler,
+	query *queries.DocumentQueryHandler,
+	limits UploadLimits,
+) *DocumentHandler {
+	return &DocumentHandler{
+		ingestion:     ingestion,
+		deleteHandler: del,
+		queryHandler:  query,
+		limits:        limits,
+	}
+}
+
+// List 列出知识库内的文档，知识库由路径或 kb_id 查询参数指定
+func (h *DocumentHandler) List(w http.ResponseWriter, r *http.Request) {
+	kbID := knowledgeBaseID(r, r.URL.Query().Get("kb_id"))
+	infos, err := h.queryHandler.List(r.Context(), kbID)
+	if err != nil {
+		writeDocumentError(w, "Failed to list documents", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"documents": infos,
+	})
+}
+
+// Get 返回文档信息及其全部分块
+func (h *DocumentHandler) Get(w http.ResponseWriter, r *http.Request) {
+	detail, err := h.queryHandler.Get(r.Context(), mux.Vars(r)["docID"])
+	if err != nil {
+		writeDocumentError(w, "Failed to get document", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(detail)
+}
+
+// Replace 重新上传文件，后台任务完成后替换文档的全部分块
+func (h *DocumentHandler) Replace(w http.ResponseWriter, r *http.Request) {
+	cmd, err := readUploadCommand(w, r, h.limits)
+	if err != nil {
+		writeUploadError(w, err)
+		return
+	}
+	cmd.DocumentID = mux.Vars(r)["docID"]
+	cmd.Replace = true
+
+	j, err := h.ingestion.Submit(r.Context(), cmd)
+	if err != nil {
+		removeUpload(cmd.FilePath)
+		writeDocumentError(w, "Failed to replace document", err)
+		return
+	}
+	writeJobAccepted(w, j)
+}
+
+// Delete 删除文档及其全部向量
+func (h *DocumentHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.deleteHandler.Handle(r.Context(), mux.Vars(r)["docID"]); err != nil {
+		logger.Errorf("Failed to delete document: %v", err)
+		writeDocumentError(w, "Failed to delete document", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/application/services"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/job"
+)
+
+// JobView 任务状态及耗时
+type JobView struct {
+	*job.Job
+	Timing JobTiming `json:"timing"`
+}
+
+// JobTiming 排队及处理耗时(毫秒)，未结束的任务按当前时间计算
+type JobTiming struct {
+	QueuedMs     int64 `json:"queued_ms"`
+	ProcessingMs int64 `json:"processing_ms"`
+}
+
+func newJobView(j *job.Job) JobView {
+	now := time.Now()
+	end := now
+	if j.FinishedAt != nil {
+		end = *j.FinishedAt
+	}
+
+	var timing JobTiming
+	if j.StartedAt != nil {
+		timing.QueuedMs = j.StartedAt.Sub(j.CreatedAt).Milliseconds()
+		timing.ProcessingMs = end.Sub(*j.StartedAt).Milliseconds()
+	} else {
+		timing.QueuedMs = end.Sub(j.CreatedAt).Milliseconds()
+	}
+	return JobView{Job: j, Timing: timing}
+}
+
+// writeJobAccepted 返回 202 及任务状态地址
+func writeJobAccepted(w http.ResponseWriter, j *job.Job) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/jobs/"+j.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(newJobView(j))
+}
+
+// JobHandler 提供入库任务的查询与取消接口
+type JobHandler struct {
+	ingestion *services.IngestionService
+}
+
+func NewJobHandler(ingestion *services.IngestionService) *JobHandler {
+	return &JobHandler{ingestion: ingestion}
+}
+
+func (h *JobHandler) List(w http.ResponseWriter, r *http.Request) {
+	jobs, err := h.ingestion.List(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to list jobs: %v", err), jobErrorStatus(err))
+		return
+	}
+
+	views := make([]JobView, 0, len(jobs))
+	for _, j := range jobs {
+		views = append(views, newJobView(j))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jobs": views,
+	})
+}
+
+func (h *JobHandler) Get(w http.ResponseWriter, r *http.Request) {
+	j, err := h.ingestion.Get(r.Context(), mux.Vars(r)["jobID"])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get job: %v", err), jobErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newJobView(j))
+}
+
+// Cancel 取消排队中或运行中的任务，运行中的任务在当前阶段中断后变为 cancelled
+func (h *JobHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	j, err := h.ingestion.Cancel(r.Context(), mux.Vars(r)["jobID"])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to cancel job: %v", err), jobErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(newJobView(j))
+}
+
+// jobErrorStatus 将任务错误映射为 HTTP 状态码
+func jobErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, job.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrJobFinished):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/application/services"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/document"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/knowledge"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/infrastructure/logger"
+)
+
+// KnowledgeBaseRequest 创建或修改知识库的请求体
+type KnowledgeBaseRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Splitter    string `json:"splitter"` // fixed 或 semantic，为空时创建使用 fixed、修改保留原值
+}
+
+// KnowledgeBaseHandler 提供知识库的增删改查接口
+type KnowledgeBaseHandler struct {
+	service *services.KnowledgeService
+}
+
+func NewKnowledgeBaseHandler(service *services.KnowledgeService) *KnowledgeBaseHandler {
+	return &KnowledgeBaseHandler{service: service}
+}
+
+func (h *KnowledgeBaseHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req KnowledgeBaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	kb, err := h.service.Create(r.Context(), req.Name, req.Description, req.Splitter)
+	if err != nil {
+		logger.Errorf("Failed to create knowledge base: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to create knowledge base: %v", err), knowledgeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(kb)
+}
+
+func (h *KnowledgeBaseHandler) List(w http.ResponseWriter, r *http.Request) {
+	bases, err := h.service.List(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to list knowledge bases: %v", err), knowledgeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"knowledge_bases": bases,
+	})
+}
+
+func (h *KnowledgeBaseHandler) Get(w http.ResponseWriter, r *http.Request) {
+	kb, err := h.service.Get(r.Context(), mux.Vars(r)["kbID"])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get knowledge base: %v", err), knowledgeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(kb)
+}
+
+func (h *KnowledgeBaseHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var req KnowledgeBaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	kb, err := h.service.Update(r.Context(), mux.Vars(r)["kbID"], req.Name, req.Description, req.Splitter)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to update knowledge base: %v", err), knowledgeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(kb)
+}
+
+// Delete 删除知识库及其全部向量
+func (h *KnowledgeBaseHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.Delete(r.Context(), mux.Vars(r)["kbID"]); err != nil {
+		logger.Errorf("Failed to delete knowledge base: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to delete knowledge base: %v", err), knowledgeErrorStatus(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// knowledgeErrorStatus 将知识库错误映射为 HTTP 状态码
+func knowledgeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, knowledge.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, knowledge.ErrDuplicateName):
+		return http.StatusConflict
+	case errors.Is(err, knowledge.ErrDefaultImmutable), errors.Is(err, services.ErrInvalidName),
+		errors.Is(err, document.ErrUnsupportedSplitter):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -3,65 +3,71 @@ package handler
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/application/commands"
 	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/application/queries"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/application/services"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/document"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/knowledge"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/domain/llm"
 	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/infrastructure/logger"
 )
 
 type KnowledgeHandler struct {
-	uploadHandler *commands.UploadDocumentHandler
-	queryHandler  *queries.QueryKnowledgeHandler
+	ingestion    *services.IngestionService
+	queryHandler *queries.QueryKnowledgeHandler
+	limits       UploadLimits
 }
 
-func NewKnowledgeHandler(upload *commands.UploadDocumentHandler, query *queries.QueryKnowledgeHandler) *KnowledgeHandler {
+func NewKnowledgeHandler(ingestion *services.IngestionService, query *queries.QueryKnowledgeHandler, limits UploadLimits) *KnowledgeHandler {
 	return &KnowledgeHandler{
-		uploadHandler: upload,
-		queryHandler:  query,
+		ingestion:    ingestion,
+		queryHandler: query,
+		limits:       limits,
 	}
 }
 
 func (h *KnowledgeHandler) UploadDocument(w http.ResponseWriter, r *http.Request) {
-	// 解析请求体
-	r.ParseMultipartForm(10 << 20)
-
-	// 获取文件句柄
-	file, handler, err := r.FormFile("file")
+	cmd, err := readUploadCommand(w, r, h.limits)
 	if err != nil {
-		logger.Errorf("Error retrieving the file: %v", err)
-		http.Error(w, "Error retrieving the file", http.StatusBadRequest)
+		writeUploadError(w, err)
 		return
 	}
-	defer file.Close()
+	cmd.KnowledgeBaseID = knowledgeBaseID(r, cmd.KnowledgeBaseID)
 
-	logger.Infof("Uploaded File: %+v\n", handler.Filename)
-	logger.Infof("File Size: %d\n", handler.Size)
-	logger.Infof("MIME Header: %v\n", handler.Header)
-	fileContent, err := io.ReadAll(file)
-	if err != nil {
-		logger.Errorf("Failed to read file: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to read file: %v", err), http.StatusInternalServerError)
+	// 归档展开为多个文件，每个文件一个入库任务
+	if contentType, err := document.DetectFileType(cmd.FilePath); err == nil && document.IsArchiveType(contentType) {
+		h.uploadArchive(w, r, cmd)
 		return
 	}
-	logger.Infof("File content read successfully, size: %d bytes", len(fileContent))
-	// 转换为 UploadDocumentCommand
-	cmd := commands.UploadDocumentCommand{
-		FileContent: fileContent,
-		Filename:    handler.Filename,
-	}
-	// 调用 uploadHandler.Handle()
-	ctx := r.Context()
-	if err := h.uploadHandler.Handle(ctx, cmd); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to upload document: %v", err), http.StatusInternalServerError)
+
+	// 提交后台入库任务，处理进度通过 /api/jobs/{id} 查询
+	j, err := h.ingestion.Submit(r.Context(), cmd)
+	if err != nil {
+		removeUpload(cmd.FilePath)
+		writeDocumentError(w, "Failed to upload document", err)
 		return
 	}
+	writeJobAccepted(w, j)
+}
 
-	// 返回成功响应
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Document uploaded successfully"))
+// uploadArchive 展开归档并返回 202 及每个文件的入库任务或未入库的原因
+func (h *KnowledgeHandler) uploadArchive(w http.ResponseWriter, r *http.Request, cmd commands.UploadDocumentCommand) {
+	result, err := h.ingestion.SubmitArchive(r.Context(), cmd)
+	if err != nil {
+		removeUpload(cmd.FilePath)
+		writeDocumentError(w, "Failed to upload archive", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(result)
 }
 
 func (h *KnowledgeHandler) QueryKnowledge(w http.ResponseWriter, r *http.Request) {
@@ -74,12 +80,13 @@ func (h *KnowledgeHandler) QueryKnowledge(w http.ResponseWriter, r *http.Request
 		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
 		return
 	}
+	req.KnowledgeBaseID = knowledgeBaseID(r, req.KnowledgeBaseID)
 
 	// 调用 queryHandler.Handle()
 	ctx := r.Context()
 	result, err := h.queryHandler.Handle(ctx, req)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to query knowledge: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Failed to query knowledge: %v", err), queryErrorStatus(err))
 		return
 	}
 
@@ -87,3 +94,125 @@ func (h *KnowledgeHandler) QueryKnowledge(w http.ResponseWriter, r *http.Request
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(result)
 }
+
+// QueryKnowledgeStream 以 SSE 流式返回查询结果
+// 事件顺序: sources -> token(多次) -> done，出错时发送 error 事件
+func (h *KnowledgeHandler) QueryKnowledgeStream(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+
+	var req queries.QueryKnowledgeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Errorf("JSON decode error: %v", err)
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	req.KnowledgeBaseID = knowledgeBaseID(r, req.KnowledgeBaseID)
+
+	// 客户端断开时 r.Context() 被取消，上游 LLM 请求随之中断
+	ctx := r.Context()
+	stream, err := h.queryHandler.HandleStream(ctx, req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to query knowledge: %v", err), queryErrorStatus(err))
+		return
+	}
+
+	sse := newSSEWriter(w)
+	if err := sse.Event("sources", stream.Sources); err != nil {
+		logger.Warnf("Failed to write sources event: %v", err)
+		return
+	}
+	retrievalTime := time.Since(startTime)
+
+	var firstTokenTime time.Duration
+	for chunk := range stream.Chunks {
+		switch {
+		case chunk.Err != nil:
+			logger.Errorf("LLM stream error: %v", chunk.Err)
+			sse.Event("error", map[string]string{"error": chunk.Err.Error()})
+			return
+		case chunk.Done:
+			sse.Event("done", map[string]interface{}{
+				"model":         stream.Model,
+				"finish_reason": chunk.FinishReason,
+				"usage":         chunk.Usage,
+				"timing": map[string]int64{
+					"retrieval_ms":   retrievalTime.Milliseconds(),
+					"first_token_ms": firstTokenTime.Milliseconds(),
+					"total_ms":       time.Since(startTime).Milliseconds(),
+				},
+			})
+			return
+		default:
+			if firstTokenTime == 0 {
+				firstTokenTime = time.Since(startTime)
+			}
+			if err := sse.Event("token", map[string]string{"content": chunk.Content}); err != nil {
+				logger.Warnf("Client disconnected during stream: %v", err)
+				return
+			}
+		}
+	}
+
+	if ctx.Err() != nil {
+		logger.Infof("Stream cancelled by client: %v", ctx.Err())
+	}
+}
+
+// ListModels 返回可用的大模型列表
+func (h *KnowledgeHandler) ListModels(w http.ResponseWriter, r *http.Request) {
+	models, defaultModel := h.queryHandler.Models()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"default": defaultModel,
+		"models":  models,
+	})
+}
+
+// knowledgeBaseID 路径中的知识库ID优先于请求参数
+func knowledgeBaseID(r *http.Request, fallback string) string {
+	if kbID := mux.Vars(r)["kbID"]; kbID != "" {
+		return kbID
+	}
+	return fallback
+}
+
+// documentErrorStatus 将文档相关错误映射为 HTTP 状态码
+func documentErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, document.ErrNotFound), errors.Is(err, knowledge.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, document.ErrUnsupportedMediaType):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, document.ErrLimitExceeded):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, services.ErrQueueFull):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeDocumentError 以 JSON 返回文档相关错误，状态码由 documentErrorStatus 决定
+func writeDocumentError(w http.ResponseWriter, action string, err error) {
+	writeJSONError(w, documentErrorStatus(err), map[string]interface{}{
+		"error": fmt.Sprintf("%s: %v", action, err),
+	})
+}
+
+// queryErrorStatus 将查询错误映射为 HTTP 状态码
+func queryErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, knowledge.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, llm.ErrModelNotFound):
+		return http.StatusBadRequest
+	case errors.Is(err, llm.ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, llm.ErrContextLengthExceeded):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, llm.ErrUnauthorized):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
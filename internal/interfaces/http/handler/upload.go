@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/application/commands"
+	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/infrastructure/logger"
+)
+
+const (
+	// formOverhead multipart 边界和普通表单字段允许的额外字节数
+	formOverhead = 1 << 20
+	// maxFieldSize 单个普通表单字段的最大字节数
+	maxFieldSize = 64 << 10
+)
+
+// UploadLimits 上传文件大小限制，PerExtension 的键为小写带点的扩展名
+type UploadLimits struct {
+	MaxBytes     int64
+	PerExtension map[string]int64
+}
+
+// forExtension 返回指定扩展名的大小上限，未单独配置时使用 MaxBytes
+func (l UploadLimits) forExtension(ext string) int64 {
+	if limit, ok := l.PerExtension[strings.ToLower(ext)]; ok {
+		return limit
+	}
+	return l.MaxBytes
+}
+
+// maxBody 请求体上限：所有限制中的最大值加上表单开销
+func (l UploadLimits) maxBody() int64 {
+	max := l.MaxBytes
+	for _, limit := range l.PerExtension {
+		if limit > max {
+			max = limit
+		}
+	}
+	return max + formOverhead
+}
+
+// uploadTooLargeError 上传内容超出大小限制
+type uploadTooLargeError struct {
+	MaxBytes int64
+}
+
+func (e *uploadTooLargeError) Error() string {
+	return fmt.Sprintf("upload exceeds the maximum size of %d bytes", e.MaxBytes)
+}
+
+// readUploadCommand 以流的方式读取 multipart 表单，文件内容写入临时文件而不是缓存在内存中
+// 成功时由调用方负责处理 cmd.FilePath 指向的临时文件
+func readUploadCommand(w http.ResponseWriter, r *http.Request, limits UploadLimits) (commands.UploadDocumentCommand, error) {
+	var cmd commands.UploadDocumentCommand
+
+	maxBody := limits.maxBody()
+	if r.ContentLength > maxBody {
+		return cmd, &uploadTooLargeError{MaxBytes: maxBody - formOverhead}
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return cmd, fmt.Errorf("failed to read multipart form: %w", err)
+	}
+
+	fields := make(map[string]string)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			removeUpload(cmd.FilePath)
+			return commands.UploadDocumentCommand{}, uploadReadError(err, maxBody)
+		}
+
+		switch {
+		case part.FormName() == "file" && part.FileName() != "":
+			if cmd.FilePath != "" {
+				part.Close()
+				removeUpload(cmd.FilePath)
+				return commands.UploadDocumentCommand{}, fmt.Errorf("only one file may be uploaded per request")
+			}
+			cmd.Filename = filepath.Base(part.FileName())
+			cmd.ContentType = part.Header.Get("Content-Type")
+			cmd.FilePath, err = saveUploadPart(part, cmd.Filename, limits.forExtension(filepath.Ext(cmd.Filename)))
+		default:
+			var value []byte
+			value, err = io.ReadAll(io.LimitReader(part, maxFieldSize))
+			fields[part.FormName()] = string(value)
+		}
+		part.Close()
+		if err != nil {
+			removeUpload(cmd.FilePath)
+			return commands.UploadDocumentCommand{}, uploadReadError(err, maxBody)
+		}
+	}
+
+	if cmd.FilePath == "" {
+		return commands.UploadDocumentCommand{}, fmt.Errorf("failed to retrieve the file: %w", http.ErrMissingFile)
+	}
+	logger.Infof("Uploaded File: %s", cmd.Filename)
+
+	// 表单字段优先，其次是查询参数
+	field := func(name string) string {
+		if v, ok := fields[name]; ok {
+			return v
+		}
+		return r.URL.Query().Get(name)
+	}
+	cmd.KnowledgeBaseID = field("kb_id")
+	// 上传者优先取认证网关注入的请求头
+	cmd.UserID = r.Header.Get("X-User-ID")
+	if cmd.UserID == "" {
+		cmd.UserID = field("user_id")
+	}
+	return cmd, nil
+}
+
+// saveUploadPart 将文件内容写入临时文件，超过 limit 时删除并返回 uploadTooLargeError
+func saveUploadPart(part io.Reader, filename string, limit int64) (string, error) {
+	// 保留原扩展名，供解析器识别
+	tmp, err := os.CreateTemp("", "upload-*"+strings.ToLower(filepath.Ext(filename)))
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+
+	n, err := io.Copy(tmp, io.LimitReader(part, limit+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && n > limit {
+		err = &uploadTooLargeError{MaxBytes: limit}
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	logger.Infof("File content saved successfully, size: %d bytes", n)
+	return tmp.Name(), nil
+}
+
+// uploadReadError 将 http.MaxBytesReader 的错误转换为 uploadTooLargeError
+func uploadReadError(err error, maxBody int64) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return &uploadTooLargeError{MaxBytes: maxBody - formOverhead}
+	}
+	var tooLarge *uploadTooLargeError
+	if errors.As(err, &tooLarge) {
+		return err
+	}
+	return fmt.Errorf("failed to read file: %w", err)
+}
+
+// writeUploadError 超出大小限制时返回 413 和上限字节数，其他情况返回 400
+func writeUploadError(w http.ResponseWriter, err error) {
+	logger.Errorf("Error retrieving the file: %v", err)
+
+	var tooLarge *uploadTooLargeError
+	if !errors.As(err, &tooLarge) {
+		writeJSONError(w, http.StatusBadRequest, map[string]interface{}{
+			"error": fmt.Sprintf("Invalid upload: %v", err),
+		})
+		return
+	}
+	// 请求体未读完，告知客户端关闭连接
+	w.Header().Set("Connection", "close")
+	writeJSONError(w, http.StatusRequestEntityTooLarge, map[string]interface{}{
+		"error":     tooLarge.Error(),
+		"max_bytes": tooLarge.MaxBytes,
+	})
+}
+
+// writeJSONError 以 JSON 返回错误，body 至少包含 "error" 字段
+func writeJSONError(w http.ResponseWriter, status int, body map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func removeUpload(path string) {
+	if path == "" {
+		return
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		logger.Warnf("Failed to remove temp upload: %v", err)
+	}
+}
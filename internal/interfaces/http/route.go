@@ -7,13 +7,13 @@ import (
 	"github.com/redhander/AIKnowledgeBaseMiddleware/internal/interfaces/http/middleware"
 )
 
-func NewRouter(kh *handler.KnowledgeHandler, logger logger.Logger) *mux.Router {
+func NewRouter(kh *handler.KnowledgeHandler, kbh *handler.KnowledgeBaseHandler, dh *handler.DocumentHandler, jh *handler.JobHandler, logger logger.Logger) *mux.Router {
 	r := mux.NewRouter()
 
 	// ✅ 先注册中间件
 	r.Use(middleware.CORS(
 		[]string{"http://localhost:5173"},
-		[]string{"POST", "OPTIONS"},
+		[]string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		[]string{"Content-Type", "Authorization"},
 	))
 	r.Use(middleware.Logging(logger))
@@ -21,7 +21,29 @@ func NewRouter(kh *handler.KnowledgeHandler, logger logger.Logger) *mux.Router {
 
 	// ✅ 再注册路由
 	r.HandleFunc("/api/documents", kh.UploadDocument).Methods("POST")
+	r.HandleFunc("/api/documents", dh.List).Methods("GET")
+	r.HandleFunc("/api/documents/{docID}", dh.Get).Methods("GET")
+	r.HandleFunc("/api/documents/{docID}", dh.Replace).Methods("PUT")
+	r.HandleFunc("/api/documents/{docID}", dh.Delete).Methods("DELETE")
 	r.HandleFunc("/api/query", kh.QueryKnowledge).Methods("POST")
+	r.HandleFunc("/api/query/stream", kh.QueryKnowledgeStream).Methods("POST")
+	r.HandleFunc("/api/models", kh.ListModels).Methods("GET")
+
+	// 上传在后台任务中处理，通过任务接口查询进度
+	r.HandleFunc("/api/jobs", jh.List).Methods("GET")
+	r.HandleFunc("/api/jobs/{jobID}", jh.Get).Methods("GET")
+	r.HandleFunc("/api/jobs/{jobID}/cancel", jh.Cancel).Methods("POST")
+
+	// 知识库管理，未指定知识库的上传和查询使用默认知识库
+	r.HandleFunc("/api/knowledge-bases", kbh.Create).Methods("POST")
+	r.HandleFunc("/api/knowledge-bases", kbh.List).Methods("GET")
+	r.HandleFunc("/api/knowledge-bases/{kbID}", kbh.Get).Methods("GET")
+	r.HandleFunc("/api/knowledge-bases/{kbID}", kbh.Update).Methods("PUT")
+	r.HandleFunc("/api/knowledge-bases/{kbID}", kbh.Delete).Methods("DELETE")
+	r.HandleFunc("/api/knowledge-bases/{kbID}/documents", kh.UploadDocument).Methods("POST")
+	r.HandleFunc("/api/knowledge-bases/{kbID}/documents", dh.List).Methods("GET")
+	r.HandleFunc("/api/knowledge-bases/{kbID}/query", kh.QueryKnowledge).Methods("POST")
+	r.HandleFunc("/api/knowledge-bases/{kbID}/query/stream", kh.QueryKnowledgeStream).Methods("POST")
 
 	return r
 }